@@ -27,6 +27,7 @@ import (
 
 type ReclaimedResourceOptions struct {
 	EnableReclaim                 bool
+	DisableReclaimNUMAs           []int
 	ReservedResourceForReport     general.ResourceList
 	MinReclaimedResourceForReport general.ResourceList
 	ReservedResourceForAllocate   general.ResourceList
@@ -56,6 +57,8 @@ func (o *ReclaimedResourceOptions) AddFlags(fss *cliflag.NamedFlagSets) {
 
 	fs.BoolVar(&o.EnableReclaim, "enable-reclaim", o.EnableReclaim,
 		"show whether enable reclaim resource from shared and agent resource")
+	fs.IntSliceVar(&o.DisableReclaimNUMAs, "disable-reclaim-numas", o.DisableReclaimNUMAs,
+		"numa nodes on which reclaim is disabled even if reclaim is enabled")
 	fs.Var(&o.ReservedResourceForReport, "reserved-resource-for-report",
 		"reserved reclaimed resource report to cnr")
 	fs.Var(&o.MinReclaimedResourceForReport, "min-reclaimed-resource-for-report",
@@ -67,6 +70,7 @@ func (o *ReclaimedResourceOptions) AddFlags(fss *cliflag.NamedFlagSets) {
 // ApplyTo fills up config with options
 func (o *ReclaimedResourceOptions) ApplyTo(c *adminqos.ReclaimedResourceConfiguration) error {
 	c.SetEnableReclaim(o.EnableReclaim)
+	c.SetDisableReclaimNUMAs(o.DisableReclaimNUMAs)
 	c.SetReservedResourceForReport(v1.ResourceList(o.ReservedResourceForReport))
 	c.SetMinReclaimedResourceForReport(v1.ResourceList(o.MinReclaimedResourceForReport))
 	c.SetReservedResourceForAllocate(v1.ResourceList(o.ReservedResourceForAllocate))
@@ -29,6 +29,8 @@ const (
 	defaultCustomNodeResourceCacheTTL     = 15 * time.Second
 	defaultCustomNodeConfigCacheTTL       = 15 * time.Second
 	defaultServiceProfileCacheTTL         = 15 * time.Second
+	defaultServiceProfileNegativeCacheTTL = 1 * time.Minute
+	defaultServiceProfileReconcilePeriod  = 5 * time.Minute
 	defaultConfigCacheTTL                 = 15 * time.Second
 	defaultConfigSkipFailedInitialization = true
 	defaultConfigCheckpointGraceTime      = 2 * time.Hour
@@ -51,6 +53,12 @@ type MetaServerOptions struct {
 	CNRCacheTTL                    time.Duration
 	CustomNodeConfigCacheTTL       time.Duration
 	ServiceProfileCacheTTL         time.Duration
+	ServiceProfileCacheTTLJitter   float64
+	ServiceProfileNegativeCacheTTL time.Duration
+	ServiceProfileOverrideDir      string
+	ServiceProfileReconcilePeriod  time.Duration
+	ServiceProfilePrefetch         bool
+	ServiceProfileEnableInformer   bool
 	ConfigCacheTTL                 time.Duration
 	ConfigDisableDynamic           bool
 	ConfigSkipFailedInitialization bool
@@ -72,6 +80,8 @@ func NewMetaServerOptions() *MetaServerOptions {
 		CNRCacheTTL:                    defaultCustomNodeResourceCacheTTL,
 		CustomNodeConfigCacheTTL:       defaultCustomNodeConfigCacheTTL,
 		ServiceProfileCacheTTL:         defaultServiceProfileCacheTTL,
+		ServiceProfileNegativeCacheTTL: defaultServiceProfileNegativeCacheTTL,
+		ServiceProfileReconcilePeriod:  defaultServiceProfileReconcilePeriod,
 		ConfigCacheTTL:                 defaultConfigCacheTTL,
 		ConfigSkipFailedInitialization: defaultConfigSkipFailedInitialization,
 		ConfigCheckpointGraceTime:      defaultConfigCheckpointGraceTime,
@@ -95,6 +105,20 @@ func (o *MetaServerOptions) AddFlags(fss *cliflag.NamedFlagSets) {
 		"The ttl of custom node config fetcher cache remote cnc")
 	fs.DurationVar(&o.ServiceProfileCacheTTL, "service-profile-cache-ttl", o.ServiceProfileCacheTTL,
 		"The ttl of service profile manager cache remote spd")
+	fs.Float64Var(&o.ServiceProfileCacheTTLJitter, "service-profile-cache-ttl-jitter", o.ServiceProfileCacheTTLJitter,
+		"The fraction of random jitter applied to the ttl of each cached spd, e.g. 0.1 means the ttl varies "+
+			"within +/-10% to spread remote refreshes; zero means disabled")
+	fs.DurationVar(&o.ServiceProfileNegativeCacheTTL, "service-profile-negative-cache-ttl", o.ServiceProfileNegativeCacheTTL,
+		"The ttl of service profile manager cache the result that remote spd doesn't exist; zero means disabled")
+	fs.StringVar(&o.ServiceProfileOverrideDir, "service-profile-override-directory", o.ServiceProfileOverrideDir,
+		"The directory of local spd files overriding remote spd, and spd of namespace/name will be "+
+			"overridden by file <directory>/<namespace>/<name>.yaml if it exists; empty means disabled")
+	fs.DurationVar(&o.ServiceProfileReconcilePeriod, "service-profile-reconcile-period", o.ServiceProfileReconcilePeriod,
+		"The period of service profile manager to reconcile spd cache with cnc target list; zero means disabled")
+	fs.BoolVar(&o.ServiceProfilePrefetch, "service-profile-prefetch", o.ServiceProfilePrefetch,
+		"Whether prefetch spd newly appeared in cnc target list when reconciling spd cache")
+	fs.BoolVar(&o.ServiceProfileEnableInformer, "service-profile-enable-informer", o.ServiceProfileEnableInformer,
+		"Whether watch spd by informer to update spd cache on events instead of checking cnc target config on each get")
 	fs.DurationVar(&o.ConfigCacheTTL, "config-cache-ttl", o.ConfigCacheTTL,
 		"The ttl of katalyst custom config loader cache remote config")
 	fs.BoolVar(&o.ConfigDisableDynamic, "config-disable-dynamic", o.ConfigDisableDynamic,
@@ -124,6 +148,12 @@ func (o *MetaServerOptions) ApplyTo(c *global.MetaServerConfiguration) error {
 	c.CNRCacheTTL = o.CNRCacheTTL
 	c.CustomNodeConfigCacheTTL = o.CustomNodeConfigCacheTTL
 	c.ServiceProfileCacheTTL = o.ServiceProfileCacheTTL
+	c.ServiceProfileCacheTTLJitter = o.ServiceProfileCacheTTLJitter
+	c.ServiceProfileNegativeCacheTTL = o.ServiceProfileNegativeCacheTTL
+	c.ServiceProfileOverrideDir = o.ServiceProfileOverrideDir
+	c.ServiceProfileReconcilePeriod = o.ServiceProfileReconcilePeriod
+	c.ServiceProfilePrefetch = o.ServiceProfilePrefetch
+	c.ServiceProfileEnableInformer = o.ServiceProfileEnableInformer
 	c.ConfigCacheTTL = o.ConfigCacheTTL
 	c.ConfigDisableDynamic = o.ConfigDisableDynamic
 	c.ConfigSkipFailedInitialization = o.ConfigSkipFailedInitialization
@@ -17,6 +17,7 @@ limitations under the License.
 package metacache
 
 import (
+	"fmt"
 	"time"
 
 	cliflag "k8s.io/component-base/cli/flag"
@@ -25,18 +26,33 @@ import (
 )
 
 const (
-	defaultMetaCacheSyncPeriod = 5
+	defaultMetaCacheSyncPeriod   = 5
+	defaultReclaimPoolNamePrefix = "reclaim"
+	defaultCheckpointCodec       = "json"
+	defaultAsyncStoreInterval    = 0
+	defaultValidationPolicy      = metacache.CheckpointValidationPolicyDropInvalid
+	defaultContainerSampleCap    = 0
 )
 
 // MetaCachePluginOptions holds the configurations for metacache plugin.
 type MetaCachePluginOptions struct {
-	SyncPeriod time.Duration
+	SyncPeriod            time.Duration
+	ReclaimPoolNamePrefix string
+	CheckpointCodec       string
+	AsyncStoreInterval    time.Duration
+	ValidationPolicy      string
+	ContainerSampleCap    int
 }
 
 // NewMetaCachePluginOptions creates a new Options with a default config.
 func NewMetaCachePluginOptions() *MetaCachePluginOptions {
 	return &MetaCachePluginOptions{
-		SyncPeriod: defaultMetaCacheSyncPeriod * time.Second,
+		SyncPeriod:            defaultMetaCacheSyncPeriod * time.Second,
+		ReclaimPoolNamePrefix: defaultReclaimPoolNamePrefix,
+		CheckpointCodec:       defaultCheckpointCodec,
+		AsyncStoreInterval:    defaultAsyncStoreInterval,
+		ValidationPolicy:      defaultValidationPolicy,
+		ContainerSampleCap:    defaultContainerSampleCap,
 	}
 }
 
@@ -45,10 +61,31 @@ func (o *MetaCachePluginOptions) AddFlags(fss *cliflag.NamedFlagSets) {
 	fs := fss.FlagSet("meta_cache_plugin")
 
 	fs.DurationVar(&o.SyncPeriod, "metacache-sync-period", o.SyncPeriod, "Period for metacache plugin to sync")
+	fs.StringVar(&o.ReclaimPoolNamePrefix, "metacache-reclaim-pool-name-prefix", o.ReclaimPoolNamePrefix,
+		"Name prefix of pools whose cpus are reclaimable")
+	fs.StringVar(&o.CheckpointCodec, "metacache-checkpoint-codec", o.CheckpointCodec,
+		"Codec to marshal metacache entries into checkpoints, json or gob")
+	fs.DurationVar(&o.AsyncStoreInterval, "metacache-async-store-interval", o.AsyncStoreInterval,
+		"Interval to store metacache checkpoints in background with mutations coalesced, if zero means storing on each mutation")
+	fs.StringVar(&o.ValidationPolicy, "metacache-checkpoint-validation-policy", o.ValidationPolicy,
+		"Policy to handle restored entries not matching machine topology, drop-invalid or reject-all")
+	fs.IntVar(&o.ContainerSampleCap, "metacache-container-sample-capacity", o.ContainerSampleCap,
+		"Max number of in-memory samples kept for each container, if zero means container samples are disabled")
 }
 
 // ApplyTo fills up config with options
 func (o *MetaCachePluginOptions) ApplyTo(c *metacache.MetaCachePluginConfiguration) error {
+	switch o.ValidationPolicy {
+	case metacache.CheckpointValidationPolicyDropInvalid, metacache.CheckpointValidationPolicyRejectAll:
+	default:
+		return fmt.Errorf("unknown checkpoint validation policy %v", o.ValidationPolicy)
+	}
+
 	c.SyncPeriod = o.SyncPeriod
+	c.ReclaimPoolNamePrefix = o.ReclaimPoolNamePrefix
+	c.CheckpointCodec = o.CheckpointCodec
+	c.AsyncStoreInterval = o.AsyncStoreInterval
+	c.CheckpointValidationPolicy = o.ValidationPolicy
+	c.ContainerSampleCapacity = o.ContainerSampleCap
 	return nil
 }
@@ -27,6 +27,10 @@ const (
 	defaultReclaimedCPUMaxCoreUtilization      = 0
 	defaultReclaimedCPUMaxOversoldRate         = 1.2
 	defaultReclaimedCPUMaxHeadroomCapacityRate = 1.
+
+	defaultReclaimedCPUTargetCoreUtilizationIndicator = ""
+	defaultReclaimedCPUHeadroomSmoothingAlpha         = 1.
+	defaultReclaimedCPUMinHeadroom                    = 0
 )
 
 type PolicyUtilizationOptions struct {
@@ -34,6 +38,10 @@ type PolicyUtilizationOptions struct {
 	ReclaimedCPUMaxCoreUtilization      float64
 	ReclaimedCPUMaxOversoldRate         float64
 	ReclaimedCPUMaxHeadroomCapacityRate float64
+
+	ReclaimedCPUTargetCoreUtilizationIndicator string
+	ReclaimedCPUHeadroomSmoothingAlpha         float64
+	ReclaimedCPUMinHeadroom                    float64
 }
 
 func NewPolicyUtilizationOptions() *PolicyUtilizationOptions {
@@ -42,6 +50,10 @@ func NewPolicyUtilizationOptions() *PolicyUtilizationOptions {
 		ReclaimedCPUMaxCoreUtilization:      defaultReclaimedCPUMaxCoreUtilization,
 		ReclaimedCPUMaxOversoldRate:         defaultReclaimedCPUMaxOversoldRate,
 		ReclaimedCPUMaxHeadroomCapacityRate: defaultReclaimedCPUMaxHeadroomCapacityRate,
+
+		ReclaimedCPUTargetCoreUtilizationIndicator: defaultReclaimedCPUTargetCoreUtilizationIndicator,
+		ReclaimedCPUHeadroomSmoothingAlpha:         defaultReclaimedCPUHeadroomSmoothingAlpha,
+		ReclaimedCPUMinHeadroom:                    defaultReclaimedCPUMinHeadroom,
 	}
 }
 
@@ -55,6 +67,13 @@ func (o *PolicyUtilizationOptions) AddFlags(fs *pflag.FlagSet) {
 		"the maximum oversold ratio of reclaimed_cores cpu reported to actual supply")
 	fs.Float64Var(&o.ReclaimedCPUMaxHeadroomCapacityRate, "cpu-headroom-policy-utilization-max-headroom-capacity-rate", o.ReclaimedCPUMaxHeadroomCapacityRate,
 		"the maximum rate of cpu headroom to node cpu capacity, if zero means no upper limit")
+	fs.StringVar(&o.ReclaimedCPUTargetCoreUtilizationIndicator, "cpu-headroom-policy-utilization-target-core-utilization-indicator",
+		o.ReclaimedCPUTargetCoreUtilizationIndicator,
+		"the spd indicator of target core utilization of reclaimed_cpu pool tolerated by pods, the minimum of it and the static target wins, if empty means disabled")
+	fs.Float64Var(&o.ReclaimedCPUHeadroomSmoothingAlpha, "cpu-headroom-policy-utilization-smoothing-alpha", o.ReclaimedCPUHeadroomSmoothingAlpha,
+		"the weight of the latest headroom in exponential smoothing, smaller value makes headroom change slower, if one means no smoothing")
+	fs.Float64Var(&o.ReclaimedCPUMinHeadroom, "cpu-headroom-policy-utilization-min-headroom", o.ReclaimedCPUMinHeadroom,
+		"the floor of cpu headroom, cores if not less than one, otherwise the fraction of node cpu capacity, if zero means no lower limit")
 }
 
 func (o *PolicyUtilizationOptions) ApplyTo(c *headroom.PolicyUtilizationConfiguration) error {
@@ -62,5 +81,8 @@ func (o *PolicyUtilizationOptions) ApplyTo(c *headroom.PolicyUtilizationConfigur
 	c.ReclaimedCPUMaxCoreUtilization = o.ReclaimedCPUMaxCoreUtilization
 	c.ReclaimedCPUMaxOversoldRate = o.ReclaimedCPUMaxOversoldRate
 	c.ReclaimedCPUMaxHeadroomCapacityRate = o.ReclaimedCPUMaxHeadroomCapacityRate
+	c.ReclaimedCPUTargetCoreUtilizationIndicator = o.ReclaimedCPUTargetCoreUtilizationIndicator
+	c.ReclaimedCPUHeadroomSmoothingAlpha = o.ReclaimedCPUHeadroomSmoothingAlpha
+	c.ReclaimedCPUMinHeadroom = o.ReclaimedCPUMinHeadroom
 	return nil
 }
@@ -24,21 +24,25 @@ import (
 )
 
 type MemoryHeadroomPolicyOptions struct {
-	MemoryPolicyCanonicalOptions *MemoryPolicyCanonicalOptions
+	MemoryPolicyCanonicalOptions   *MemoryPolicyCanonicalOptions
+	MemoryPolicyUtilizationOptions *MemoryPolicyUtilizationOptions
 }
 
 func NewMemoryHeadroomPolicyOptions() *MemoryHeadroomPolicyOptions {
 	return &MemoryHeadroomPolicyOptions{
-		MemoryPolicyCanonicalOptions: NewMemoryPolicyCanonicalOptions(),
+		MemoryPolicyCanonicalOptions:   NewMemoryPolicyCanonicalOptions(),
+		MemoryPolicyUtilizationOptions: NewMemoryPolicyUtilizationOptions(),
 	}
 }
 
 func (o *MemoryHeadroomPolicyOptions) AddFlags(fs *pflag.FlagSet) {
 	o.MemoryPolicyCanonicalOptions.AddFlags(fs)
+	o.MemoryPolicyUtilizationOptions.AddFlags(fs)
 }
 
 func (o *MemoryHeadroomPolicyOptions) ApplyTo(c *headroom.MemoryHeadroomPolicyConfiguration) error {
 	var errList []error
 	errList = append(errList, o.MemoryPolicyCanonicalOptions.ApplyTo(c.MemoryPolicyCanonicalConfiguration))
+	errList = append(errList, o.MemoryPolicyUtilizationOptions.ApplyTo(c.MemoryPolicyUtilizationConfiguration))
 	return errors.NewAggregate(errList)
 }
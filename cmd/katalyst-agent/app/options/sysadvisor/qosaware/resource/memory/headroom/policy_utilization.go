@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package headroom
+
+import (
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/katalyst-core/pkg/config/agent/sysadvisor/qosaware/resource/memory/headroom"
+)
+
+const (
+	defaultReclaimedMemoryTargetUtilization       = 0.8
+	defaultReclaimedMemoryMaxUtilization          = 0
+	defaultReclaimedMemoryMaxOversoldRate         = 1.
+	defaultReclaimedMemoryMaxHeadroomCapacityRate = 1.
+)
+
+type MemoryPolicyUtilizationOptions struct {
+	ReclaimedMemoryTargetUtilization       float64
+	ReclaimedMemoryMaxUtilization          float64
+	ReclaimedMemoryMaxOversoldRate         float64
+	ReclaimedMemoryMaxHeadroomCapacityRate float64
+}
+
+func NewMemoryPolicyUtilizationOptions() *MemoryPolicyUtilizationOptions {
+	return &MemoryPolicyUtilizationOptions{
+		ReclaimedMemoryTargetUtilization:       defaultReclaimedMemoryTargetUtilization,
+		ReclaimedMemoryMaxUtilization:          defaultReclaimedMemoryMaxUtilization,
+		ReclaimedMemoryMaxOversoldRate:         defaultReclaimedMemoryMaxOversoldRate,
+		ReclaimedMemoryMaxHeadroomCapacityRate: defaultReclaimedMemoryMaxHeadroomCapacityRate,
+	}
+}
+
+// AddFlags adds flags to the specified FlagSet.
+func (o *MemoryPolicyUtilizationOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.Float64Var(&o.ReclaimedMemoryTargetUtilization, "memory-headroom-policy-utilization-target-utilization", o.ReclaimedMemoryTargetUtilization,
+		"the target memory utilization of each numa node")
+	fs.Float64Var(&o.ReclaimedMemoryMaxUtilization, "memory-headroom-policy-utilization-max-utilization", o.ReclaimedMemoryMaxUtilization,
+		"the maximum memory utilization of each numa node, if zero means no upper limit")
+	fs.Float64Var(&o.ReclaimedMemoryMaxOversoldRate, "memory-headroom-policy-utilization-max-oversold-ratio", o.ReclaimedMemoryMaxOversoldRate,
+		"the maximum oversold ratio of reclaimed_cores memory reported to allocatable memory")
+	fs.Float64Var(&o.ReclaimedMemoryMaxHeadroomCapacityRate, "memory-headroom-policy-utilization-max-headroom-capacity-rate", o.ReclaimedMemoryMaxHeadroomCapacityRate,
+		"the maximum rate of memory headroom to node memory capacity, if zero means no upper limit")
+}
+
+func (o *MemoryPolicyUtilizationOptions) ApplyTo(c *headroom.MemoryPolicyUtilizationConfiguration) error {
+	c.ReclaimedMemoryTargetUtilization = o.ReclaimedMemoryTargetUtilization
+	c.ReclaimedMemoryMaxUtilization = o.ReclaimedMemoryMaxUtilization
+	c.ReclaimedMemoryMaxOversoldRate = o.ReclaimedMemoryMaxOversoldRate
+	c.ReclaimedMemoryMaxHeadroomCapacityRate = o.ReclaimedMemoryMaxHeadroomCapacityRate
+	return nil
+}
@@ -2187,11 +2187,21 @@ func (p *DynamicPolicy) checkCPUSet() {
 				continue
 			}
 
+			actualCPUSet, err := machine.ParseWithStride(cpusetStats.CPUs)
+			if err != nil {
+				klog.Errorf("[CPUDynamicPolicy.checkCPUSet] parse CPUSet of pod: %s container: name(%s), id(%s) failed with error: %v",
+					podUID, containerName, containerId, err)
+
+				_ = p.emitter.StoreInt64(util.MetricNameRealStateInvalid, 1, metrics.MetricTypeNameRaw, tags...)
+
+				continue
+			}
+
 			if actualCPUSets[podUID] == nil {
 				actualCPUSets[podUID] = make(map[string]machine.CPUSet)
 			}
 
-			actualCPUSets[podUID][containerName] = machine.MustParse(cpusetStats.CPUs)
+			actualCPUSets[podUID][containerName] = actualCPUSet
 
 			klog.Infof("[CPUDynamicPolicy.checkCPUSet] pod: %s/%s, container: %s, state CPUSet: %s, actual CPUSet: %s",
 				allocationInfo.PodNamespace, allocationInfo.PodName,
@@ -18,16 +18,140 @@ package metacache
 
 import (
 	"encoding/json"
+	"fmt"
+	"sync"
 
 	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
 	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager/checksum"
 
+	v1types "github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/metacache/internal/v1/types"
 	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
 )
 
 var _ checkpointmanager.Checkpoint = &MetaCacheCheckpoint{}
 
+// RestoreTransformer is used to normalize or migrate entries of a restored checkpoint
+// before they are loaded into metacache, e.g. recompute a field or drop deprecated ones
+type RestoreTransformer func(checkpoint *MetaCacheCheckpoint) error
+
+var (
+	restoreTransformers     []RestoreTransformer
+	restoreTransformersLock sync.RWMutex
+)
+
+// RegisterRestoreTransformer registers a transformer to be invoked during restoreState;
+// transformers are chained and invoked in the order of registration
+func RegisterRestoreTransformer(transformer RestoreTransformer) {
+	restoreTransformersLock.Lock()
+	defer restoreTransformersLock.Unlock()
+
+	restoreTransformers = append(restoreTransformers, transformer)
+}
+
+func getRestoreTransformers() []RestoreTransformer {
+	restoreTransformersLock.RLock()
+	defer restoreTransformersLock.RUnlock()
+
+	return append([]RestoreTransformer{}, restoreTransformers...)
+}
+
+const (
+	// checkpointSchemaVersionV1 is the schema version of checkpoints stored before
+	// schema versioning was introduced, which have no schema version field
+	checkpointSchemaVersionV1 = 1
+	checkpointSchemaVersionV2 = 2
+
+	currentCheckpointSchemaVersion = checkpointSchemaVersionV2
+)
+
+// CheckpointMigration is used to upgrade entries of the old checkpoint into the new one,
+// whose schema version is exactly one greater than the old one
+type CheckpointMigration func(old, new *MetaCacheCheckpoint) error
+
+var (
+	checkpointMigrations = map[int]CheckpointMigration{
+		checkpointSchemaVersionV1: migrateCheckpointV1ToV2,
+	}
+	checkpointMigrationsLock sync.RWMutex
+)
+
+// RegisterCheckpointMigration registers the migration from fromVersion to fromVersion+1,
+// and it overrides the existing one of the same version if any
+func RegisterCheckpointMigration(fromVersion int, migration CheckpointMigration) {
+	checkpointMigrationsLock.Lock()
+	defer checkpointMigrationsLock.Unlock()
+
+	checkpointMigrations[fromVersion] = migration
+}
+
+func getCheckpointMigration(fromVersion int) (CheckpointMigration, bool) {
+	checkpointMigrationsLock.RLock()
+	defer checkpointMigrationsLock.RUnlock()
+
+	migration, ok := checkpointMigrations[fromVersion]
+	return migration, ok
+}
+
+// migrateCheckpointV1ToV2 keeps entries as they are, since v2 only introduces schema version
+func migrateCheckpointV1ToV2(old, new *MetaCacheCheckpoint) error {
+	new.PodEntries = old.PodEntries
+	new.PoolEntries = old.PoolEntries
+	new.RegionEntries = old.RegionEntries
+	return nil
+}
+
+// migrateCheckpoint upgrades the checkpoint to current schema version step by step,
+// and returns error if there is no migration path or any of the migrations fails
+func migrateCheckpoint(checkpoint *MetaCacheCheckpoint) (*MetaCacheCheckpoint, error) {
+	version := checkpoint.getSchemaVersion()
+	if version > currentCheckpointSchemaVersion {
+		return nil, fmt.Errorf("schema version %v is newer than %v", version, currentCheckpointSchemaVersion)
+	}
+
+	for ; version < currentCheckpointSchemaVersion; version++ {
+		migration, ok := getCheckpointMigration(version)
+		if !ok {
+			return nil, fmt.Errorf("no migration from schema version %v to %v", version, version+1)
+		}
+
+		next := NewMetaCacheCheckpoint()
+		next.SchemaVersion = version + 1
+		if err := migration(checkpoint, next); err != nil {
+			return nil, fmt.Errorf("migrate from schema version %v to %v failed: %v", version, version+1, err)
+		}
+		checkpoint = next
+	}
+	return checkpoint, nil
+}
+
+// entryGroup is a group of entries stored in a separate checkpoint
+type entryGroup int
+
+const (
+	entryGroupPods entryGroup = iota
+	entryGroupPools
+	entryGroupRegions
+)
+
+var allEntryGroups = []entryGroup{entryGroupPods, entryGroupPools, entryGroupRegions}
+
+func (g entryGroup) String() string {
+	switch g {
+	case entryGroupPods:
+		return "pods"
+	case entryGroupPools:
+		return "pools"
+	case entryGroupRegions:
+		return "regions"
+	default:
+		return "unknown"
+	}
+}
+
+// MetaCacheCheckpoint is the legacy single checkpoint of all entries, and it's
+// only used to assemble entry groups now
 type MetaCacheCheckpoint struct {
+	SchemaVersion int                 `json:"schema_version,omitempty"`
 	PodEntries    types.PodEntries    `json:"pod_entries"`
 	PoolEntries   types.PoolEntries   `json:"pool_entries"`
 	RegionEntries types.RegionEntries `json:"region_entries"`
@@ -36,6 +160,7 @@ type MetaCacheCheckpoint struct {
 
 func NewMetaCacheCheckpoint() *MetaCacheCheckpoint {
 	return &MetaCacheCheckpoint{
+		SchemaVersion: currentCheckpointSchemaVersion,
 		PodEntries:    make(types.PodEntries),
 		PoolEntries:   make(types.PoolEntries),
 		RegionEntries: make(types.RegionEntries),
@@ -46,7 +171,7 @@ func NewMetaCacheCheckpoint() *MetaCacheCheckpoint {
 func (cp *MetaCacheCheckpoint) MarshalCheckpoint() ([]byte, error) {
 	// make sure checksum wasn't set before so it doesn't affect output checksum
 	cp.Checksum = 0
-	cp.Checksum = checksum.New(cp)
+	cp.Checksum = checksum.New(cp.checksumObject())
 	return json.Marshal(*cp)
 }
 
@@ -59,7 +184,148 @@ func (cp *MetaCacheCheckpoint) UnmarshalCheckpoint(blob []byte) error {
 func (cp *MetaCacheCheckpoint) VerifyChecksum() error {
 	ck := cp.Checksum
 	cp.Checksum = 0
-	err := ck.Verify(cp)
+	err := ck.Verify(cp.checksumObject())
 	cp.Checksum = ck
 	return err
 }
+
+// checksumObject returns the object to calculate checksum against. checksum of v1 checkpoints
+// was calculated without schema version, and since type names and all fields are hashed as well,
+// the layout of v1 is reproduced here with the same names, including entries whose types have
+// changed since v1, to keep them verifiable
+func (cp *MetaCacheCheckpoint) checksumObject() interface{} {
+	if cp.getSchemaVersion() > checkpointSchemaVersionV1 {
+		return cp
+	}
+
+	type MetaCacheCheckpoint struct {
+		PodEntries    types.PodEntries
+		PoolEntries   v1types.PoolEntries
+		RegionEntries types.RegionEntries
+		Checksum      checksum.Checksum
+	}
+	return &MetaCacheCheckpoint{
+		PodEntries:    cp.PodEntries,
+		PoolEntries:   v1types.NewPoolEntries(cp.PoolEntries),
+		RegionEntries: cp.RegionEntries,
+		Checksum:      cp.Checksum,
+	}
+}
+
+func (cp *MetaCacheCheckpoint) getSchemaVersion() int {
+	if cp.SchemaVersion == 0 {
+		return checkpointSchemaVersionV1
+	}
+	return cp.SchemaVersion
+}
+
+// describeEntries returns the names and sizes of non-empty entries, which is used for logging
+func (cp *MetaCacheCheckpoint) describeEntries() []string {
+	var entries []string
+	if len(cp.PodEntries) > 0 {
+		entries = append(entries, fmt.Sprintf("pod_entries(%v)", len(cp.PodEntries)))
+	}
+	if len(cp.PoolEntries) > 0 {
+		entries = append(entries, fmt.Sprintf("pool_entries(%v)", len(cp.PoolEntries)))
+	}
+	if len(cp.RegionEntries) > 0 {
+		entries = append(entries, fmt.Sprintf("region_entries(%v)", len(cp.RegionEntries)))
+	}
+	return entries
+}
+
+// groupEntries returns the pointer to entries of the given group
+func (cp *MetaCacheCheckpoint) groupEntries(group entryGroup) interface{} {
+	switch group {
+	case entryGroupPods:
+		return &cp.PodEntries
+	case entryGroupPools:
+		return &cp.PoolEntries
+	case entryGroupRegions:
+		return &cp.RegionEntries
+	default:
+		return nil
+	}
+}
+
+// copyGroupEntries copies entries of the given group from another checkpoint
+func (cp *MetaCacheCheckpoint) copyGroupEntries(group entryGroup, from *MetaCacheCheckpoint) {
+	switch group {
+	case entryGroupPods:
+		cp.PodEntries = from.PodEntries
+	case entryGroupPools:
+		cp.PoolEntries = from.PoolEntries
+	case entryGroupRegions:
+		cp.RegionEntries = from.RegionEntries
+	}
+}
+
+var _ checkpointmanager.Checkpoint = &entryGroupCheckpoint{}
+
+// entryGroupCheckpoint stores encoded entries of an entry group. entries encoded by json codec
+// are stored inline in Entries to keep compatible with checkpoints stored before codecs were
+// introduced, and entries encoded by other codecs are stored in Data
+type entryGroupCheckpoint struct {
+	SchemaVersion int               `json:"schema_version,omitempty"`
+	Codec         string            `json:"codec,omitempty"`
+	Entries       json.RawMessage   `json:"entries,omitempty"`
+	Data          []byte            `json:"data,omitempty"`
+	Checksum      checksum.Checksum `json:"checksum"`
+}
+
+func newEntryGroupCheckpoint(entries interface{}, codec CheckpointCodec) (*entryGroupCheckpoint, error) {
+	data, err := codec.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &entryGroupCheckpoint{SchemaVersion: currentCheckpointSchemaVersion}
+	if codec.Name() == CheckpointCodecJSON {
+		cp.Entries = data
+	} else {
+		cp.Codec = codec.Name()
+		cp.Data = data
+	}
+	return cp, nil
+}
+
+// codecName returns the name of codec used to encode entries
+func (cp *entryGroupCheckpoint) codecName() string {
+	if cp.Codec == "" {
+		return CheckpointCodecJSON
+	}
+	return cp.Codec
+}
+
+func (cp *entryGroupCheckpoint) payload() []byte {
+	if cp.codecName() == CheckpointCodecJSON {
+		return cp.Entries
+	}
+	return cp.Data
+}
+
+// decodeEntries decodes entries with the codec they were encoded by
+func (cp *entryGroupCheckpoint) decodeEntries(entries interface{}) error {
+	codec, err := GetCheckpointCodec(cp.Codec)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(cp.payload(), entries)
+}
+
+// MarshalCheckpoint returns marshaled checkpoint
+func (cp *entryGroupCheckpoint) MarshalCheckpoint() ([]byte, error) {
+	cp.Checksum = 0
+	cp.Checksum = checksum.New(string(cp.payload()))
+	return json.Marshal(*cp)
+}
+
+// UnmarshalCheckpoint tries to unmarshal passed bytes to checkpoint
+func (cp *entryGroupCheckpoint) UnmarshalCheckpoint(blob []byte) error {
+	return json.Unmarshal(blob, cp)
+}
+
+// VerifyChecksum verifies that current checksum of checkpoint is valid
+func (cp *entryGroupCheckpoint) VerifyChecksum() error {
+	return cp.Checksum.Verify(string(cp.payload()))
+}
@@ -17,9 +17,12 @@ limitations under the License.
 package metacache
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
@@ -75,3 +78,24 @@ func TestCheckpoint(t *testing.T) {
 	assert.NoError(t, err)
 
 }
+
+func TestVerifyChecksumOfV1Checkpoint(t *testing.T) {
+	// the checkpoint file is produced by metacache before schema versioning was introduced,
+	// and its checksum must be verifiable no matter how the types of entries evolve
+	blob, err := ioutil.ReadFile(filepath.Join("testdata", "checkpoint_v1"))
+	require.NoError(t, err)
+
+	cp := NewMetaCacheCheckpoint()
+	cp.SchemaVersion = 0
+	require.NoError(t, cp.UnmarshalCheckpoint(blob))
+	assert.Equal(t, checkpointSchemaVersionV1, cp.getSchemaVersion())
+	assert.NoError(t, cp.VerifyChecksum())
+
+	poolInfo, ok := cp.PoolEntries["share"]
+	require.True(t, ok)
+	assert.Equal(t, machine.MustParse("0-1"), poolInfo.TopologyAwareAssignments[0])
+
+	// checksum is still verified against the layout of v1 after entries are changed
+	poolInfo.PoolName = "reclaim"
+	assert.Error(t, cp.VerifyChecksum())
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	CheckpointCodecJSON = "json"
+	CheckpointCodecGob  = "gob"
+)
+
+// CheckpointCodec controls how entries are marshalled into checkpoints. the checkpoint
+// manager still stores checkpoints as json, and checksum is calculated against the
+// marshalled entries, so codecs only affect the cost of encoding entries
+type CheckpointCodec interface {
+	// Name returns the name of codec, which is recorded in checkpoints
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var checkpointCodecs = map[string]CheckpointCodec{
+	CheckpointCodecJSON: jsonCheckpointCodec{},
+	CheckpointCodecGob:  gobCheckpointCodec{},
+}
+
+// GetCheckpointCodec returns the codec by name, and empty name refers to json codec
+// since checkpoints stored before codecs were introduced have no codec name
+func GetCheckpointCodec(name string) (CheckpointCodec, error) {
+	if name == "" {
+		name = CheckpointCodecJSON
+	}
+
+	codec, ok := checkpointCodecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown checkpoint codec %v", name)
+	}
+	return codec, nil
+}
+
+type jsonCheckpointCodec struct{}
+
+func (jsonCheckpointCodec) Name() string {
+	return CheckpointCodecJSON
+}
+
+func (jsonCheckpointCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCheckpointCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// gobCheckpointCodec is much cheaper than json for large entries, but notice that
+// gob fails to encode maps with nil values, so nil pool info is never stored in metacache
+type gobCheckpointCodec struct{}
+
+func (gobCheckpointCodec) Name() string {
+	return CheckpointCodecGob
+}
+
+func (gobCheckpointCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCheckpointCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
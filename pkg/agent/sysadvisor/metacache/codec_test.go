@@ -0,0 +1,206 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
+)
+
+func generateTestPodEntries(podNum int) types.PodEntries {
+	podEntries := make(types.PodEntries)
+	for i := 0; i < podNum; i++ {
+		podUID := fmt.Sprintf("pod-%d", i)
+		podEntries[podUID] = types.ContainerEntries{
+			"c1": &types.ContainerInfo{
+				PodUID:        podUID,
+				PodNamespace:  "default",
+				PodName:       podUID,
+				ContainerName: "c1",
+				Labels:        map[string]string{"app": podUID},
+				Annotations:   map[string]string{"katalyst.kubewharf.io/qos_level": "shared_cores"},
+				QoSLevel:      "shared_cores",
+				CPURequest:    4,
+				MemoryRequest: 8 << 30,
+				OwnerPoolName: "share",
+				TopologyAwareAssignments: types.TopologyAwareAssignment{
+					0: machine.MustParse("0-23"),
+					1: machine.MustParse("24-47"),
+				},
+				OriginalTopologyAwareAssignments: types.TopologyAwareAssignment{
+					0: machine.MustParse("0-23"),
+					1: machine.MustParse("24-47"),
+				},
+				RegionNames: sets.NewString("share"),
+			},
+		}
+	}
+	return podEntries
+}
+
+func TestCheckpointCodec(t *testing.T) {
+	for _, name := range []string{CheckpointCodecJSON, CheckpointCodecGob} {
+		t.Run(name, func(t *testing.T) {
+			codec, err := GetCheckpointCodec(name)
+			require.NoError(t, err)
+
+			podEntries := generateTestPodEntries(3)
+			groupCheckpoint, err := newEntryGroupCheckpoint(&podEntries, codec)
+			require.NoError(t, err)
+			blob, err := groupCheckpoint.MarshalCheckpoint()
+			require.NoError(t, err)
+
+			restored := &entryGroupCheckpoint{}
+			require.NoError(t, restored.UnmarshalCheckpoint(blob))
+			require.NoError(t, restored.VerifyChecksum())
+			assert.Equal(t, name, restored.codecName())
+
+			restoredPodEntries := make(types.PodEntries)
+			require.NoError(t, restored.decodeEntries(&restoredPodEntries))
+			assert.Equal(t, podEntries, restoredPodEntries)
+		})
+	}
+
+	_, err := GetCheckpointCodec("unknown")
+	assert.Error(t, err)
+}
+
+func TestRestoreWithCheckpointCodec(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	conf.SysAdvisorPluginsConfiguration.MetaCachePluginConfiguration.CheckpointCodec = CheckpointCodecGob
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, mc.SetContainerInfo("pod-0", "c1", generateTestPodEntries(1)["pod-0"]["c1"]))
+	require.NoError(t, mc.SetPoolInfo("share", &types.PoolInfo{
+		PoolName:                 "share",
+		TopologyAwareAssignments: types.TopologyAwareAssignment{0: machine.MustParse("0-3")},
+		RegionNames:              sets.NewString("share"),
+	}))
+
+	// entries stored by gob codec are loadable after switching back to json codec
+	conf.SysAdvisorPluginsConfiguration.MetaCachePluginConfiguration.CheckpointCodec = CheckpointCodecJSON
+	mc2, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	ci, ok := mc2.GetContainerInfo("pod-0", "c1")
+	require.True(t, ok)
+	assert.Equal(t, "0-23", ci.TopologyAwareAssignments[0].String())
+	pi, ok := mc2.GetPoolInfo("share")
+	require.True(t, ok)
+	assert.Equal(t, "0-3", pi.TopologyAwareAssignments[0].String())
+
+	// and they are re-encoded by json codec
+	_, outdated, err := mc2.loadCheckpoint()
+	require.NoError(t, err)
+	assert.False(t, outdated)
+}
+
+func TestStoreNilPoolInfoWithGobCodec(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	conf.SysAdvisorPluginsConfiguration.MetaCachePluginConfiguration.CheckpointCodec = CheckpointCodecGob
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, mc.SetPoolInfo("share", &types.PoolInfo{PoolName: "share"}))
+
+	// nil pool info deletes the pool instead of being stored
+	require.NoError(t, mc.SetPoolInfo("share", nil))
+	_, ok := mc.GetPoolInfo("share")
+	assert.False(t, ok)
+	require.NoError(t, mc.SetPoolInfo("reserve", nil))
+	_, ok = mc.GetPoolInfo("reserve")
+	assert.False(t, ok)
+
+	// pools are still stored by gob codec afterwards
+	require.NoError(t, mc.SetPoolInfo("reclaim", &types.PoolInfo{PoolName: "reclaim"}))
+	mc2, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	_, ok = mc2.GetPoolInfo("reclaim")
+	assert.True(t, ok)
+	_, ok = mc2.GetPoolInfo("share")
+	assert.False(t, ok)
+}
+
+func TestStoreNilRegionInfoWithGobCodec(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	conf.SysAdvisorPluginsConfiguration.MetaCachePluginConfiguration.CheckpointCodec = CheckpointCodecGob
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, mc.SetRegionInfo("share-0", &types.RegionInfo{RegionType: types.QoSRegionTypeShare}))
+
+	// nil region info deletes the region instead of being stored
+	require.NoError(t, mc.SetRegionInfo("share-0", nil))
+	_, ok := mc.GetRegionInfo("share-0")
+	assert.False(t, ok)
+	require.NoError(t, mc.SetRegionInfo("share-1", nil))
+	_, ok = mc.GetRegionInfo("share-1")
+	assert.False(t, ok)
+
+	// regions with nil info are dropped when updating region entries
+	require.NoError(t, mc.UpdateRegionEntries(types.RegionEntries{
+		"share-2": nil,
+		"share-3": {RegionType: types.QoSRegionTypeShare},
+	}))
+	_, ok = mc.GetRegionInfo("share-2")
+	assert.False(t, ok)
+
+	// regions are still stored by gob codec afterwards
+	require.NoError(t, mc.SetRegionInfo("share-4", &types.RegionInfo{RegionType: types.QoSRegionTypeShare}))
+	mc2, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	_, ok = mc2.GetRegionInfo("share-4")
+	assert.True(t, ok)
+	_, ok = mc2.GetRegionInfo("share-0")
+	assert.False(t, ok)
+}
+
+func BenchmarkCheckpointCodec(b *testing.B) {
+	podEntries := generateTestPodEntries(5000)
+	for _, name := range []string{CheckpointCodecJSON, CheckpointCodecGob} {
+		codec, err := GetCheckpointCodec(name)
+		require.NoError(b, err)
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := codec.Marshal(&podEntries); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kubewharf/katalyst-core/pkg/agent/qrm-plugins/cpu/dynamicpolicy/state"
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
+)
+
+// InconsistencyKind is the kind of inconsistency between container allocations and pool assignments
+type InconsistencyKind string
+
+const (
+	// InconsistencyKindPoolNotFound means the owner pool of container doesn't exist
+	InconsistencyKindPoolNotFound InconsistencyKind = "PoolNotFound"
+	// InconsistencyKindOrphanCPUs means container claims cpus not assigned to its owner pool
+	InconsistencyKindOrphanCPUs InconsistencyKind = "OrphanCPUs"
+	// InconsistencyKindOverlap means dedicated container claims cpus assigned to a pool
+	InconsistencyKindOverlap InconsistencyKind = "Overlap"
+)
+
+// InconsistencyReport describes an inconsistency of container allocation against pool assignments
+type InconsistencyReport struct {
+	Kind          InconsistencyKind
+	PodUID        string
+	ContainerName string
+	PoolName      string
+	// CPUs is the set of cpus in conflict, and it's empty if the pool is not found
+	CPUs machine.CPUSet
+}
+
+func (r InconsistencyReport) String() string {
+	return fmt.Sprintf("%v: container %v/%v, pool %v, cpus %v", r.Kind, r.PodUID, r.ContainerName, r.PoolName, r.CPUs.String())
+}
+
+// CheckConsistency cross-checks cpus allocated to containers against assignments of pools, and
+// reports containers whose owner pool doesn't exist, claiming cpus not assigned to its owner pool,
+// or dedicated containers claiming cpus assigned to any pool. it doesn't mutate any entry.
+func (mc *MetaCacheImp) CheckConsistency() []InconsistencyReport {
+	mc.rLockAllPodShards()
+	mc.poolMutex.RLock()
+	mc.regionMutex.RLock()
+	defer func() {
+		mc.regionMutex.RUnlock()
+		mc.poolMutex.RUnlock()
+		mc.rUnlockAllPodShards()
+	}()
+
+	return checkConsistency(mc.getPodEntriesWithoutLock(), mc.poolEntries)
+}
+
+func checkConsistency(podEntries types.PodEntries, poolEntries types.PoolEntries) []InconsistencyReport {
+	poolCPUs := make(map[string]machine.CPUSet, len(poolEntries))
+	for poolName, poolInfo := range poolEntries {
+		poolCPUs[poolName] = poolInfo.TopologyAwareAssignments.MergeCPUSet()
+	}
+
+	var reports []InconsistencyReport
+	for podUID, containerEntries := range podEntries {
+		for containerName, ci := range containerEntries {
+			// containers not allocated yet have no owner pool
+			if ci == nil || ci.OwnerPoolName == "" {
+				continue
+			}
+
+			cpus := ci.TopologyAwareAssignments.MergeCPUSet()
+			// dedicated pool doesn't exist in pool entries, and its cpus are exclusive to the container
+			if ci.OwnerPoolName == state.PoolNameDedicated {
+				for poolName, assigned := range poolCPUs {
+					if overlap := cpus.Intersection(assigned); !overlap.IsEmpty() {
+						reports = append(reports, InconsistencyReport{
+							Kind:          InconsistencyKindOverlap,
+							PodUID:        podUID,
+							ContainerName: containerName,
+							PoolName:      poolName,
+							CPUs:          overlap,
+						})
+					}
+				}
+				continue
+			}
+
+			assigned, ok := poolCPUs[ci.OwnerPoolName]
+			if !ok {
+				reports = append(reports, InconsistencyReport{
+					Kind:          InconsistencyKindPoolNotFound,
+					PodUID:        podUID,
+					ContainerName: containerName,
+					PoolName:      ci.OwnerPoolName,
+					CPUs:          machine.NewCPUSet(),
+				})
+				continue
+			}
+
+			if orphan := cpus.Difference(assigned); !orphan.IsEmpty() {
+				reports = append(reports, InconsistencyReport{
+					Kind:          InconsistencyKindOrphanCPUs,
+					PodUID:        podUID,
+					ContainerName: containerName,
+					PoolName:      ci.OwnerPoolName,
+					CPUs:          orphan,
+				})
+			}
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].PodUID != reports[j].PodUID {
+			return reports[i].PodUID < reports[j].PodUID
+		}
+		if reports[i].ContainerName != reports[j].ContainerName {
+			return reports[i].ContainerName < reports[j].ContainerName
+		}
+		return reports[i].PoolName < reports[j].PoolName
+	})
+	return reports
+}
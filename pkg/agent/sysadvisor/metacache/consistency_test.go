@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubewharf/katalyst-core/pkg/agent/qrm-plugins/cpu/dynamicpolicy/state"
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, mc.SetPoolInfo(state.PoolNameShare, &types.PoolInfo{
+		PoolName: state.PoolNameShare,
+		TopologyAwareAssignments: types.TopologyAwareAssignment{
+			0: machine.NewCPUSet(0, 1, 2, 3),
+		},
+	}))
+	require.NoError(t, mc.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+		PoolName: state.PoolNameReclaim,
+		TopologyAwareAssignments: types.TopologyAwareAssignment{
+			1: machine.NewCPUSet(8, 9),
+		},
+	}))
+
+	containers := []*types.ContainerInfo{
+		// consistent with share pool
+		{
+			PodUID: "pod-0", ContainerName: "c1", OwnerPoolName: state.PoolNameShare,
+			TopologyAwareAssignments: types.TopologyAwareAssignment{0: machine.NewCPUSet(0, 1, 2, 3)},
+		},
+		// claims cpus out of share pool
+		{
+			PodUID: "pod-1", ContainerName: "c1", OwnerPoolName: state.PoolNameShare,
+			TopologyAwareAssignments: types.TopologyAwareAssignment{0: machine.NewCPUSet(2, 3, 4)},
+		},
+		// owner pool doesn't exist
+		{
+			PodUID: "pod-2", ContainerName: "c1", OwnerPoolName: "absent",
+			TopologyAwareAssignments: types.TopologyAwareAssignment{0: machine.NewCPUSet(0)},
+		},
+		// dedicated cpus overlap with reclaim pool
+		{
+			PodUID: "pod-3", ContainerName: "c1", OwnerPoolName: state.PoolNameDedicated,
+			TopologyAwareAssignments: types.TopologyAwareAssignment{1: machine.NewCPUSet(9, 10, 11)},
+		},
+		// dedicated cpus exclusive to container
+		{
+			PodUID: "pod-4", ContainerName: "c1", OwnerPoolName: state.PoolNameDedicated,
+			TopologyAwareAssignments: types.TopologyAwareAssignment{1: machine.NewCPUSet(12, 13)},
+		},
+		// not allocated yet
+		{
+			PodUID: "pod-5", ContainerName: "c1",
+		},
+	}
+	for _, ci := range containers {
+		require.NoError(t, mc.AddContainer(ci.PodUID, ci.ContainerName, ci))
+	}
+
+	reports := mc.CheckConsistency()
+	require.Len(t, reports, 3)
+
+	assert.Equal(t, InconsistencyKindOrphanCPUs, reports[0].Kind)
+	assert.Equal(t, "pod-1", reports[0].PodUID)
+	assert.Equal(t, state.PoolNameShare, reports[0].PoolName)
+	assert.Equal(t, "4", reports[0].CPUs.String())
+
+	assert.Equal(t, InconsistencyKindPoolNotFound, reports[1].Kind)
+	assert.Equal(t, "pod-2", reports[1].PodUID)
+	assert.Equal(t, "absent", reports[1].PoolName)
+	assert.True(t, reports[1].CPUs.IsEmpty())
+
+	assert.Equal(t, InconsistencyKindOverlap, reports[2].Kind)
+	assert.Equal(t, "pod-3", reports[2].PodUID)
+	assert.Equal(t, state.PoolNameReclaim, reports[2].PoolName)
+	assert.Equal(t, "9", reports[2].CPUs.String())
+
+	// checking consistency doesn't mutate entries
+	ci, ok := mc.GetContainerInfo("pod-1", "c1")
+	require.True(t, ok)
+	assert.Equal(t, "2-4", ci.TopologyAwareAssignments[0].String())
+	pool, ok := mc.GetPoolInfo(state.PoolNameShare)
+	require.True(t, ok)
+	assert.Equal(t, "0-3", pool.TopologyAwareAssignments[0].String())
+
+	// inconsistency is resolved once pool assignments are aligned
+	require.NoError(t, mc.DeleteContainer("pod-2", "c1"))
+	require.NoError(t, mc.SetPoolInfo(state.PoolNameShare, &types.PoolInfo{
+		PoolName: state.PoolNameShare,
+		TopologyAwareAssignments: types.TopologyAwareAssignment{
+			0: machine.NewCPUSet(0, 1, 2, 3, 4),
+		},
+	}))
+	require.NoError(t, mc.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+		PoolName: state.PoolNameReclaim,
+		TopologyAwareAssignments: types.TopologyAwareAssignment{
+			1: machine.NewCPUSet(8),
+		},
+	}))
+	assert.Empty(t, mc.CheckConsistency())
+}
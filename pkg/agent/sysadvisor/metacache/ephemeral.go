@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"fmt"
+)
+
+// ephemeralEntries stores transient values keyed by pod uid, container name and key
+type ephemeralEntries map[string]map[string]map[string]interface{}
+
+// SetContainerEphemeral attaches a transient value to an existing container. ephemeral values are
+// only kept in memory, so they are never stored into checkpoints or trigger storing state, and they
+// are cleaned up once the container is deleted
+func (mc *MetaCacheImp) SetContainerEphemeral(podUID string, containerName string, key string, value interface{}) error {
+	shard := mc.getPodShard(podUID)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	// hold the shard lock so that the container won't be deleted before the value is set
+	if _, ok := shard.entries[podUID][containerName]; !ok {
+		return fmt.Errorf("container %v/%v not found", podUID, containerName)
+	}
+
+	mc.ephemeralMutex.Lock()
+	defer mc.ephemeralMutex.Unlock()
+
+	podEphemerals, ok := mc.ephemeralEntries[podUID]
+	if !ok {
+		podEphemerals = make(map[string]map[string]interface{})
+		mc.ephemeralEntries[podUID] = podEphemerals
+	}
+	containerEphemerals, ok := podEphemerals[containerName]
+	if !ok {
+		containerEphemerals = make(map[string]interface{})
+		podEphemerals[containerName] = containerEphemerals
+	}
+	containerEphemerals[key] = value
+	return nil
+}
+
+// GetContainerEphemeral returns the transient value of a container, and notice that
+// the value isn't deep copied, so callers should not mutate it in place
+func (mc *MetaCacheImp) GetContainerEphemeral(podUID string, containerName string, key string) (interface{}, bool) {
+	mc.ephemeralMutex.RLock()
+	defer mc.ephemeralMutex.RUnlock()
+
+	value, ok := mc.ephemeralEntries[podUID][containerName][key]
+	return value, ok
+}
+
+// cleanupContainerEphemeral is registered as a container observer to clean up
+// ephemeral values of deleted containers
+func (mc *MetaCacheImp) cleanupContainerEphemeral(event ContainerEvent) {
+	if event.Kind != EventKindDeleted {
+		return
+	}
+
+	mc.ephemeralMutex.Lock()
+	defer mc.ephemeralMutex.Unlock()
+
+	podEphemerals, ok := mc.ephemeralEntries[event.PodUID]
+	if !ok {
+		return
+	}
+	delete(podEphemerals, event.ContainerName)
+	if len(podEphemerals) == 0 {
+		delete(mc.ephemeralEntries, event.PodUID)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+)
+
+// metaCacheExportVersion is the format version of exported state, and it should be
+// bumped whenever the exported format is changed incompatibly
+const metaCacheExportVersion = 1
+
+// metaCacheExport is the portable form of metacache state, which is always encoded
+// as json regardless of the checkpoint codec, and map keys are sorted by encoding/json
+type metaCacheExport struct {
+	Version       int                 `json:"version"`
+	PodEntries    types.PodEntries    `json:"podEntries"`
+	PoolEntries   types.PoolEntries   `json:"poolEntries"`
+	RegionEntries types.RegionEntries `json:"regionEntries"`
+}
+
+// Export writes a consistent view of pod, pool and region entries to w as json,
+// which is decoupled from the checkpoint format and can be attached to support bundles
+func (mc *MetaCacheImp) Export(w io.Writer) error {
+	snapshot := mc.Snapshot()
+	export := &metaCacheExport{
+		Version:       metaCacheExportVersion,
+		PodEntries:    snapshot.podEntries,
+		PoolEntries:   snapshot.poolEntries,
+		RegionEntries: snapshot.regionEntries,
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return fmt.Errorf("encode metacache state failed: %v", err)
+	}
+	return nil
+}
+
+// Import reads state written by Export from r, and replaces all pod, pool and region
+// entries with it after validation. entries are swapped with all locks held, so readers
+// never observe a mixed state, and the imported state is stored into checkpoints.
+func (mc *MetaCacheImp) Import(r io.Reader) error {
+	export := &metaCacheExport{}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(export); err != nil {
+		return fmt.Errorf("decode metacache state failed: %v", err)
+	}
+	if err := export.validate(); err != nil {
+		return fmt.Errorf("invalid metacache state: %v", err)
+	}
+
+	if export.PodEntries == nil {
+		export.PodEntries = make(types.PodEntries)
+	}
+	if export.PoolEntries == nil {
+		export.PoolEntries = make(types.PoolEntries)
+	}
+	if export.RegionEntries == nil {
+		export.RegionEntries = make(types.RegionEntries)
+	}
+
+	events := &metaEvents{}
+
+	mc.lockAllPodShards()
+	mc.poolMutex.Lock()
+	mc.regionMutex.Lock()
+
+	addPodEntriesEvents(mc.getPodEntriesWithoutLock(), export.PodEntries, events)
+	addPoolEntriesEvents(mc.poolEntries, export.PoolEntries, events)
+
+	for _, shard := range mc.podShards {
+		shard.entries = make(types.PodEntries)
+	}
+	for podUID, podInfo := range export.PodEntries {
+		mc.getPodShard(podUID).entries[podUID] = podInfo
+	}
+	mc.poolEntries = export.PoolEntries
+	mc.regionEntries = export.RegionEntries
+
+	mc.regionMutex.Unlock()
+	mc.poolMutex.Unlock()
+	mc.unlockAllPodShards()
+
+	klog.Infof("[metacache] imported state with %v pods, %v pools and %v regions",
+		len(export.PodEntries), len(export.PoolEntries), len(export.RegionEntries))
+
+	err := mc.storeState()
+	mc.notifyObservers(events)
+	return err
+}
+
+// validate checks that the imported state is supported and self-consistent,
+// i.e. entries are not nil and their names are the same as the keys
+func (e *metaCacheExport) validate() error {
+	if e.Version != metaCacheExportVersion {
+		return fmt.Errorf("unsupported version %v, expected %v", e.Version, metaCacheExportVersion)
+	}
+
+	var errList []error
+	for podUID, podInfo := range e.PodEntries {
+		if podUID == "" {
+			errList = append(errList, fmt.Errorf("pod with empty uid"))
+			continue
+		}
+		for containerName, containerInfo := range podInfo {
+			if containerInfo == nil {
+				errList = append(errList, fmt.Errorf("pod %v container %v is nil", podUID, containerName))
+			} else if containerInfo.PodUID != podUID || containerInfo.ContainerName != containerName {
+				errList = append(errList, fmt.Errorf("pod %v container %v mismatches info of pod %v container %v",
+					podUID, containerName, containerInfo.PodUID, containerInfo.ContainerName))
+			}
+		}
+	}
+
+	for poolName, poolInfo := range e.PoolEntries {
+		if poolInfo == nil {
+			errList = append(errList, fmt.Errorf("pool %v is nil", poolName))
+		} else if poolInfo.PoolName != poolName {
+			errList = append(errList, fmt.Errorf("pool %v mismatches info of pool %v", poolName, poolInfo.PoolName))
+		}
+	}
+
+	for regionName, regionInfo := range e.RegionEntries {
+		if regionInfo == nil {
+			errList = append(errList, fmt.Errorf("region %v is nil", regionName))
+		}
+	}
+	return utilerrors.NewAggregate(errList)
+}
+
+// addPodEntriesEvents collects container events of replacing oldEntries with newEntries
+func addPodEntriesEvents(oldEntries, newEntries types.PodEntries, events *metaEvents) {
+	for podUID, podInfo := range oldEntries {
+		for containerName, containerInfo := range podInfo {
+			if _, ok := newEntries[podUID][containerName]; !ok {
+				events.addContainerEvent(podUID, containerName, EventKindDeleted, containerInfo)
+			}
+		}
+	}
+
+	for podUID, podInfo := range newEntries {
+		for containerName, containerInfo := range podInfo {
+			oldContainerInfo, ok := oldEntries[podUID][containerName]
+			if !ok {
+				events.addContainerEvent(podUID, containerName, EventKindAdded, containerInfo)
+			} else if !oldContainerInfo.Equal(containerInfo) {
+				events.addContainerEvent(podUID, containerName, EventKindUpdated, containerInfo)
+			}
+		}
+	}
+}
+
+// addPoolEntriesEvents collects pool events of replacing oldEntries with newEntries
+func addPoolEntriesEvents(oldEntries, newEntries types.PoolEntries, events *metaEvents) {
+	for poolName, poolInfo := range oldEntries {
+		if _, ok := newEntries[poolName]; !ok {
+			events.addPoolEvent(poolName, EventKindDeleted, poolInfo)
+		}
+	}
+
+	for poolName, poolInfo := range newEntries {
+		oldPoolInfo, ok := oldEntries[poolName]
+		if !ok {
+			events.addPoolEvent(poolName, EventKindAdded, poolInfo)
+		} else if !oldPoolInfo.Equal(poolInfo) {
+			events.addPoolEvent(poolName, EventKindUpdated, poolInfo)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
+)
+
+func TestExportImport(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(srcDir)
+	dstDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dstDir)
+
+	src, err := NewMetaCacheImp(generateTestConfiguration(t, srcDir), metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, src.SetPoolInfo("share", &types.PoolInfo{
+		PoolName:                 "share",
+		TopologyAwareAssignments: types.TopologyAwareAssignment{0: machine.NewCPUSet(0, 1, 2, 3)},
+	}))
+	require.NoError(t, src.SetContainerInfo("pod-0", "c1", &types.ContainerInfo{
+		PodUID: "pod-0", ContainerName: "c1", OwnerPoolName: "share", CPURequest: 2,
+		TopologyAwareAssignments: types.TopologyAwareAssignment{0: machine.NewCPUSet(0, 1, 2, 3)},
+	}))
+	require.NoError(t, src.SetRegionInfo("share", &types.RegionInfo{
+		RegionType: types.QoSRegionTypeShare, BindingNumas: machine.NewCPUSet(0), Headroom: 2,
+	}))
+
+	dstConf := generateTestConfiguration(t, dstDir)
+	dst, err := NewMetaCacheImp(dstConf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, dst.SetContainerInfo("pod-1", "c1", &types.ContainerInfo{PodUID: "pod-1", ContainerName: "c1"}))
+
+	var events []ContainerEvent
+	dst.RegisterContainerObserver(func(event ContainerEvent) {
+		events = append(events, event)
+	})
+
+	exported := &bytes.Buffer{}
+	require.NoError(t, src.Export(exported))
+	require.NoError(t, dst.Import(bytes.NewReader(exported.Bytes())))
+
+	// the imported state is exported identically
+	reExported := &bytes.Buffer{}
+	require.NoError(t, dst.Export(reExported))
+	assert.Equal(t, exported.String(), reExported.String())
+
+	_, ok := dst.GetContainerInfo("pod-1", "c1")
+	assert.False(t, ok)
+	require.Len(t, events, 2)
+	kinds := map[string]EventKind{}
+	for _, event := range events {
+		kinds[event.PodUID] = event.Kind
+	}
+	assert.Equal(t, map[string]EventKind{"pod-0": EventKindAdded, "pod-1": EventKindDeleted}, kinds)
+
+	// the imported state is stored into checkpoints
+	restored, err := NewMetaCacheImp(dstConf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	containerInfo, ok := restored.GetContainerInfo("pod-0", "c1")
+	require.True(t, ok)
+	assert.Equal(t, 2.0, containerInfo.CPURequest)
+	headroom, ok := restored.GetRegionHeadroom("share")
+	require.True(t, ok)
+	assert.Equal(t, 2.0, headroom)
+}
+
+func TestImportInvalid(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	mc, err := NewMetaCacheImp(generateTestConfiguration(t, stateFileDir), metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, mc.SetPoolInfo("share", &types.PoolInfo{PoolName: "share"}))
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "malformed json", input: `{"version": 1,`},
+		{name: "unknown field", input: `{"version": 1, "unknown": {}}`},
+		{name: "unsupported version", input: `{"version": 100}`},
+		{name: "mismatched container", input: `{"version": 1, "podEntries": {"pod-0": {"c1": {"PodUID": "pod-1", "ContainerName": "c1"}}}}`},
+		{name: "mismatched pool", input: `{"version": 1, "poolEntries": {"share": {"PoolName": "reclaim"}}}`},
+		{name: "nil region", input: `{"version": 1, "regionEntries": {"share": null}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, mc.Import(strings.NewReader(tt.input)))
+
+			// the state is unchanged if import fails
+			_, ok := mc.GetPoolInfo("share")
+			assert.True(t, ok)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package types freezes the layout of entries in checkpoints of schema version v1. checksum
+// of those checkpoints is calculated against the printed form of entries, which contains both
+// type names and all of the fields, so the types here must be kept as they were in v1, with
+// the same package and type names, no matter how the current types evolve.
+package types
+
+import (
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+)
+
+// PoolInfo is the layout of types.PoolInfo in v1, which has no Revision
+type PoolInfo struct {
+	PoolName                         string
+	TopologyAwareAssignments         types.TopologyAwareAssignment
+	OriginalTopologyAwareAssignments types.TopologyAwareAssignment
+	RegionNames                      sets.String
+}
+
+// PoolEntries is the layout of types.PoolEntries in v1
+type PoolEntries map[string]*PoolInfo
+
+// NewPoolEntries converts current pool entries into the layout of v1
+func NewPoolEntries(entries types.PoolEntries) PoolEntries {
+	if entries == nil {
+		return nil
+	}
+
+	v1Entries := make(PoolEntries, len(entries))
+	for poolName, poolInfo := range entries {
+		if poolInfo == nil {
+			v1Entries[poolName] = nil
+			continue
+		}
+		v1Entries[poolName] = &PoolInfo{
+			PoolName:                         poolInfo.PoolName,
+			TopologyAwareAssignments:         poolInfo.TopologyAwareAssignments,
+			OriginalTopologyAwareAssignments: poolInfo.OriginalTopologyAwareAssignments,
+			RegionNames:                      poolInfo.RegionNames,
+		}
+	}
+	return v1Entries
+}
@@ -17,11 +17,15 @@ limitations under the License.
 package metacache
 
 import (
+	"context"
 	"fmt"
-	"reflect"
+	"hash/fnv"
+	"strings"
 	"sync"
 	"time"
 
+	"go.uber.org/atomic"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
@@ -29,7 +33,10 @@ import (
 
 	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
 	"github.com/kubewharf/katalyst-core/pkg/config"
+	"github.com/kubewharf/katalyst-core/pkg/consts"
 	"github.com/kubewharf/katalyst-core/pkg/metaserver/agent/metric"
+	"github.com/kubewharf/katalyst-core/pkg/metrics"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
 	"github.com/kubewharf/katalyst-core/pkg/util/machine"
 )
 
@@ -43,6 +50,29 @@ import (
 const (
 	stateFileName             string = "sys_advisor_state"
 	storeStateWarningDuration        = 2 * time.Second
+
+	// podShardNum is the number of shards pod entries are split into, so that
+	// operations on pods in different shards don't contend with each other
+	podShardNum = 32
+)
+
+const (
+	metricMetaCacheEntryCount           = "metacache_entry_count"
+	metricMetaCacheStoreStateDuration   = "metacache_store_state_duration"
+	metricMetaCacheRestoreStateDuration = "metacache_restore_state_duration"
+	metricMetaCacheRequestChanged       = "metacache_container_request_changed"
+
+	metricTagKeyEntryGroup = "group"
+	metricTagKeyStatus     = "status"
+	metricTagKeyPodUID     = "podUID"
+	metricTagKeyContainer  = "containerName"
+	metricTagKeyResource   = "resource"
+
+	metricTagValueStatusSuccess = "success"
+	metricTagValueStatusFailure = "failure"
+
+	metricTagValueResourceCPU    = "cpu"
+	metricTagValueResourceMemory = "memory"
 )
 
 // MetaReader provides a standard interface to refer to metadata type
@@ -51,20 +81,41 @@ type MetaReader interface {
 	GetContainerEntries(podUID string) (types.ContainerEntries, bool)
 	// GetContainerInfo returns a ContainerInfo copy keyed by pod uid and container name
 	GetContainerInfo(podUID string, containerName string) (*types.ContainerInfo, bool)
+	// GetContainerInfos returns ContainerInfo copies of the given containers in a pod keyed by
+	// container name, and absent containers are omitted, so it's empty if the pod is absent
+	GetContainerInfos(podUID string, containerNames []string) (map[string]*types.ContainerInfo, error)
 	// GetContainerMetric returns the metric value of a container
 	GetContainerMetric(podUID string, containerName string, metricName string) (float64, error)
+	// GetContainerMetricWithTime returns the metric value of a container along with the time
+	// when metrics of the container were last updated by metrics fetcher
+	GetContainerMetricWithTime(podUID string, containerName string, metricName string) (float64, time.Time, error)
+	// GetFreshContainerMetric returns the metric value of a container, and returns error
+	// if metrics of the container were last updated more than maxAge ago
+	GetFreshContainerMetric(podUID string, containerName string, metricName string, maxAge time.Duration) (float64, error)
 	// RangeContainer applies a function to every podUID, containerName, containerInfo set
 	RangeContainer(f func(podUID string, containerName string, containerInfo *types.ContainerInfo) bool)
+	// GetContainersByQoSLevel returns ContainerInfo copies of all containers with the given qos level
+	GetContainersByQoSLevel(qosLevel string) []*types.ContainerInfo
+	// GetContainerCountByQoSLevel returns the number of containers with the given qos level
+	GetContainerCountByQoSLevel(qosLevel string) int
 
-	// GetPoolInfo returns a PoolInfo copy by pool name
+	// GetPoolInfo returns a PoolInfo copy by pool name, with its current revision
 	GetPoolInfo(poolName string) (*types.PoolInfo, bool)
 	// GetPoolSize returns the size of pool as integer
 	GetPoolSize(poolName string) (int, bool)
+	// GetReclaimableCPUs returns the total and per numa number of cpus in reclaim pools
+	GetReclaimableCPUs() (int, map[int]int, error)
+	// RangePoolInfo applies a function to every poolName, poolInfo set
+	RangePoolInfo(f func(poolName string, poolInfo *types.PoolInfo) bool)
 
 	// GetRegionInfo returns a RegionInfo copy by region name
 	GetRegionInfo(regionName string) (*types.RegionInfo, bool)
 	// RangeRegionInfo applies a function to every regionName, regionInfo set
 	RangeRegionInfo(f func(regionName string, regionInfo *types.RegionInfo) bool)
+	// GetRegionProvision returns a copy of the provisioned control knobs of region by region name
+	GetRegionProvision(regionName string) (types.ControlKnob, bool)
+	// GetRegionHeadroom returns the headroom of region by region name
+	GetRegionHeadroom(regionName string) (float64, bool)
 }
 
 // RawMetaWriter provides a standard interface to modify raw metadata (generated by other agents) in local cache
@@ -72,6 +123,9 @@ type RawMetaWriter interface {
 	// AddContainer adds a container keyed by pod uid and container name. For repeatedly added
 	// container, only mutable metadata will be updated, i.e. request quantity changed by vpa
 	AddContainer(podUID string, containerName string, containerInfo *types.ContainerInfo) error
+	// AddContainerWithResult works the same as AddContainer, and created is true only
+	// if the container is newly added instead of having its mutable metadata updated
+	AddContainerWithResult(podUID string, containerName string, containerInfo *types.ContainerInfo) (created bool, err error)
 	// SetContainerInfo updates ContainerInfo keyed by pod uid and container name
 	SetContainerInfo(podUID string, containerName string, containerInfo *types.ContainerInfo) error
 	// RangeAndUpdateContainer applies a function to every podUID, containerName, containerInfo set.
@@ -86,9 +140,15 @@ type RawMetaWriter interface {
 	DeleteContainer(podUID string, containerName string) error
 	// RemovePod deletes a PodInfo keyed by pod uid. Repeatedly remove will be ignored.
 	RemovePod(podUID string) error
+	// GCPodEntries deletes PodEntries of pods not existing on node
+	GCPodEntries(livingPodUIDSet sets.String) error
 
-	// SetPoolInfo stores a PoolInfo by pool name
+	// SetPoolInfo stores a PoolInfo by pool name, and nil PoolInfo deletes the pool
 	SetPoolInfo(poolName string, poolInfo *types.PoolInfo) error
+	// SetPoolInfoIfVersion stores a PoolInfo by pool name only if the current revision of the pool
+	// equals to expectedRV, and it returns false without any change if not. expectedRV of zero
+	// means the pool is expected to be absent.
+	SetPoolInfoIfVersion(poolName string, expectedRV int64, poolInfo *types.PoolInfo) (bool, error)
 	// DeletePool deletes a PoolInfo keyed by pool name
 	DeletePool(poolName string) error
 	// GCPoolEntries deletes GCPoolEntries not existing on node
@@ -97,22 +157,43 @@ type RawMetaWriter interface {
 
 // AdvisorMetaWriter provides a standard interface to modify advised metadata (generated by sysadvisor)
 type AdvisorMetaWriter interface {
+	// UpdateRegionEntries replaces all region entries with the given ones
 	UpdateRegionEntries(entries types.RegionEntries) error
+	// SetRegionInfo stores a RegionInfo by region name, and keeps the other regions untouched
+	SetRegionInfo(regionName string, regionInfo *types.RegionInfo) error
 }
 
-type MetaCache interface {
-	MetaReader
+// MetaWriter provides a standard interface to modify both raw and advised metadata
+type MetaWriter interface {
 	RawMetaWriter
 	AdvisorMetaWriter
 }
 
+type MetaCache interface {
+	MetaReader
+	MetaWriter
+
+	// Transaction applies all mutations made by f in a batch with state stored only once,
+	// and all mutations are rolled back if f returns error
+	Transaction(f func(writer MetaWriter) error) error
+
+	// SetContainerEphemeral attaches an in-memory only value to a container by key
+	SetContainerEphemeral(podUID string, containerName string, key string, value interface{}) error
+	// GetContainerEphemeral returns the in-memory only value of a container by key
+	GetContainerEphemeral(podUID string, containerName string, key string) (interface{}, bool)
+
+	// AppendContainerSample appends an in-memory only sample into the bounded history of a container
+	AppendContainerSample(podUID string, containerName string, sample ContainerSample) error
+	// GetContainerSamples returns the sample history of a container from the oldest to the newest
+	GetContainerSamples(podUID string, containerName string) []ContainerSample
+}
+
 // MetaCacheImp stores metadata and info of pod, node, pool, subnuma etc. as a cache,
 // and synchronizes data to sysadvisor state file. It is thread-safe to read and write.
 // Deep copy logic is performed during accessing metacache entries instead of directly
 // return pointer of each struct to avoid mis-overwrite.
 type MetaCacheImp struct {
-	podEntries types.PodEntries
-	podMutex   sync.RWMutex
+	podShards [podShardNum]*podShard
 
 	poolEntries types.PoolEntries
 	poolMutex   sync.RWMutex
@@ -120,30 +201,90 @@ type MetaCacheImp struct {
 	regionEntries types.RegionEntries
 	regionMutex   sync.RWMutex
 
+	// storeMutex serializes storeState, and each store takes a snapshot of
+	// all dirty entry groups, so the latest store always wins
+	storeMutex        sync.Mutex
+	dirtyGroups       map[entryGroup]bool
+	dirtyMutex        sync.Mutex
 	checkpointManager checkpointmanager.CheckpointManager
 	checkpointName    string
+	checkpointCodec   CheckpointCodec
+
+	// checkpointValidationPolicy decides how to handle invalid entries found by ValidateState
+	checkpointValidationPolicy string
 
+	// asyncStoreInterval is the interval to flush dirty entry groups in background if positive,
+	// and mutations only mark entry groups dirty instead of storing state while asyncStoring
+	asyncStoreInterval time.Duration
+	asyncStoring       atomic.Bool
+
+	emitter        metrics.MetricEmitter
 	metricsFetcher metric.MetricsFetcher
+
+	reclaimPoolNamePrefix string
+
+	containerObservers []ContainerObserver
+	poolObservers      []PoolObserver
+	observerMutex      sync.RWMutex
+
+	// ephemeralEntries is guarded by a dedicated mutex to avoid contending with entries
+	ephemeralEntries ephemeralEntries
+	ephemeralMutex   sync.RWMutex
+
+	// sampleEntries keeps at most containerSampleCapacity samples for each container
+	sampleEntries           sampleEntries
+	sampleMutex             sync.RWMutex
+	containerSampleCapacity int
 }
 
 var _ MetaCache = &MetaCacheImp{}
 
+// podShard holds pod entries of pods hashed into it
+type podShard struct {
+	mutex   sync.RWMutex
+	entries types.PodEntries
+}
+
 // NewMetaCacheImp returns the single instance of MetaCacheImp
-func NewMetaCacheImp(conf *config.Configuration, metricsFetcher metric.MetricsFetcher) (*MetaCacheImp, error) {
+func NewMetaCacheImp(conf *config.Configuration, emitterPool metricspool.MetricsEmitterPool,
+	metricsFetcher metric.MetricsFetcher) (*MetaCacheImp, error) {
 	stateFileDir := conf.GenericSysAdvisorConfiguration.StateFileDirectory
 	checkpointManager, err := checkpointmanager.NewCheckpointManager(stateFileDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize checkpoint manager: %v", err)
 	}
 
+	metaCacheConf := conf.SysAdvisorPluginsConfiguration.MetaCachePluginConfiguration
+	checkpointCodec, err := GetCheckpointCodec(metaCacheConf.CheckpointCodec)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkValidationPolicy(metaCacheConf.CheckpointValidationPolicy); err != nil {
+		return nil, err
+	}
+
 	mc := &MetaCacheImp{
-		podEntries:        make(types.PodEntries),
-		poolEntries:       make(types.PoolEntries),
-		regionEntries:     make(types.RegionEntries),
-		checkpointManager: checkpointManager,
-		checkpointName:    stateFileName,
-		metricsFetcher:    metricsFetcher,
+		poolEntries:        make(types.PoolEntries),
+		regionEntries:      make(types.RegionEntries),
+		dirtyGroups:        make(map[entryGroup]bool),
+		ephemeralEntries:   make(ephemeralEntries),
+		sampleEntries:      make(sampleEntries),
+		checkpointManager:  checkpointManager,
+		checkpointName:     stateFileName,
+		checkpointCodec:    checkpointCodec,
+		asyncStoreInterval: metaCacheConf.AsyncStoreInterval,
+		emitter:            emitterPool.GetDefaultMetricsEmitter().WithTags("advisor-metacache"),
+		metricsFetcher:     metricsFetcher,
+
+		checkpointValidationPolicy: metaCacheConf.CheckpointValidationPolicy,
+		reclaimPoolNamePrefix:      metaCacheConf.ReclaimPoolNamePrefix,
+		containerSampleCapacity:    metaCacheConf.ContainerSampleCapacity,
 	}
+	for i := range mc.podShards {
+		mc.podShards[i] = &podShard{entries: make(types.PodEntries)}
+	}
+	mc.RegisterContainerObserver(mc.cleanupContainerEphemeral)
+	mc.RegisterContainerObserver(mc.cleanupContainerSamples)
 
 	// Restore from checkpoint before any function call to metacache api
 	if err := mc.restoreState(); err != nil {
@@ -153,23 +294,54 @@ func NewMetaCacheImp(conf *config.Configuration, metricsFetcher metric.MetricsFe
 	return mc, nil
 }
 
+// Run flushes dirty entry groups in background periodically if async store is enabled, and
+// it blocks until ctx is done, with the remaining dirty entry groups flushed synchronously
+func (mc *MetaCacheImp) Run(ctx context.Context) {
+	if mc.asyncStoreInterval <= 0 {
+		return
+	}
+
+	mc.asyncStoring.Store(true)
+	klog.Infof("[metacache] async store started with interval %v", mc.asyncStoreInterval)
+
+	ticker := time.NewTicker(mc.asyncStoreInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// mutations are coalesced into one store of all dirty entry groups
+			_ = mc.storeStateSelective()
+		case <-ctx.Done():
+			// mutations after this point store state by themselves
+			mc.asyncStoring.Store(false)
+			if err := mc.storeStateSelective(); err != nil {
+				klog.Errorf("[metacache] flush state on stop failed: %v", err)
+			}
+			klog.Infof("[metacache] async store stopped")
+			return
+		}
+	}
+}
+
 /*
 	standard implementation for metaReader
 */
 
 func (mc *MetaCacheImp) GetContainerEntries(podUID string) (types.ContainerEntries, bool) {
-	mc.podMutex.RLock()
-	defer mc.podMutex.RUnlock()
+	shard := mc.getPodShard(podUID)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
 
-	v, ok := mc.podEntries[podUID]
+	v, ok := shard.entries[podUID]
 	return v.Clone(), ok
 }
 
 func (mc *MetaCacheImp) GetContainerInfo(podUID string, containerName string) (*types.ContainerInfo, bool) {
-	mc.podMutex.RLock()
-	defer mc.podMutex.RUnlock()
+	shard := mc.getPodShard(podUID)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
 
-	podInfo, ok := mc.podEntries[podUID]
+	podInfo, ok := shard.entries[podUID]
 	if !ok {
 		return nil, false
 	}
@@ -178,12 +350,20 @@ func (mc *MetaCacheImp) GetContainerInfo(podUID string, containerName string) (*
 	return containerInfo.Clone(), ok
 }
 
+func (mc *MetaCacheImp) GetContainerInfos(podUID string, containerNames []string) (map[string]*types.ContainerInfo, error) {
+	shard := mc.getPodShard(podUID)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	return getContainerInfos(shard.entries, podUID, containerNames), nil
+}
+
 // RangeContainer should deepcopy so that pod and container entries will not be overwritten.
 func (mc *MetaCacheImp) RangeContainer(f func(podUID string, containerName string, containerInfo *types.ContainerInfo) bool) {
-	mc.podMutex.RLock()
-	defer mc.podMutex.RUnlock()
+	mc.rLockAllPodShards()
+	defer mc.rUnlockAllPodShards()
 
-	for podUID, podInfo := range mc.podEntries.Clone() {
+	for podUID, podInfo := range mc.getPodEntriesWithoutLock().Clone() {
 		for containerName, containerInfo := range podInfo {
 			if !f(podUID, containerName, containerInfo) {
 				break
@@ -192,10 +372,33 @@ func (mc *MetaCacheImp) RangeContainer(f func(podUID string, containerName strin
 	}
 }
 
+// GetContainersByQoSLevel only deepcopies the matched containers, so it's cheaper than RangeContainer
+func (mc *MetaCacheImp) GetContainersByQoSLevel(qosLevel string) []*types.ContainerInfo {
+	mc.rLockAllPodShards()
+	defer mc.rUnlockAllPodShards()
+
+	return getContainersByQoSLevel(mc.getPodEntriesWithoutLock(), qosLevel)
+}
+
+func (mc *MetaCacheImp) GetContainerCountByQoSLevel(qosLevel string) int {
+	mc.rLockAllPodShards()
+	defer mc.rUnlockAllPodShards()
+
+	return getContainerCountByQoSLevel(mc.getPodEntriesWithoutLock(), qosLevel)
+}
+
 func (mc *MetaCacheImp) GetContainerMetric(podUID string, containerName string, metricName string) (float64, error) {
 	return mc.metricsFetcher.GetContainerMetric(podUID, containerName, metricName)
 }
 
+func (mc *MetaCacheImp) GetContainerMetricWithTime(podUID string, containerName string, metricName string) (float64, time.Time, error) {
+	return getContainerMetricWithTime(mc.metricsFetcher, podUID, containerName, metricName)
+}
+
+func (mc *MetaCacheImp) GetFreshContainerMetric(podUID string, containerName string, metricName string, maxAge time.Duration) (float64, error) {
+	return getFreshContainerMetric(mc.metricsFetcher, podUID, containerName, metricName, maxAge)
+}
+
 func (mc *MetaCacheImp) GetPoolInfo(poolName string) (*types.PoolInfo, bool) {
 	mc.poolMutex.RLock()
 	defer mc.poolMutex.RUnlock()
@@ -215,6 +418,26 @@ func (mc *MetaCacheImp) GetPoolSize(poolName string) (int, bool) {
 	return machine.CountCPUAssignmentCPUs(pi.TopologyAwareAssignments), true
 }
 
+// GetReclaimableCPUs sums up cpus of all pools with reclaim pool name prefix,
+// and returns error if no such pool exists
+func (mc *MetaCacheImp) GetReclaimableCPUs() (int, map[int]int, error) {
+	mc.poolMutex.RLock()
+	defer mc.poolMutex.RUnlock()
+
+	return getReclaimableCPUs(mc.poolEntries, mc.reclaimPoolNamePrefix)
+}
+
+func (mc *MetaCacheImp) RangePoolInfo(f func(poolName string, poolInfo *types.PoolInfo) bool) {
+	mc.poolMutex.RLock()
+	defer mc.poolMutex.RUnlock()
+
+	for poolName, poolInfo := range mc.poolEntries.Clone() {
+		if !f(poolName, poolInfo) {
+			break
+		}
+	}
+}
+
 func (mc *MetaCacheImp) GetRegionInfo(regionName string) (*types.RegionInfo, bool) {
 	mc.regionMutex.RLock()
 	defer mc.regionMutex.RUnlock()
@@ -235,89 +458,535 @@ func (mc *MetaCacheImp) RangeRegionInfo(f func(regionName string, regionInfo *ty
 	}
 }
 
+func (mc *MetaCacheImp) GetRegionProvision(regionName string) (types.ControlKnob, bool) {
+	mc.regionMutex.RLock()
+	defer mc.regionMutex.RUnlock()
+
+	regionInfo, ok := mc.regionEntries[regionName]
+	if !ok || regionInfo == nil {
+		return nil, false
+	}
+	return regionInfo.ControlKnobMap.Clone(), true
+}
+
+func (mc *MetaCacheImp) GetRegionHeadroom(regionName string) (float64, bool) {
+	mc.regionMutex.RLock()
+	defer mc.regionMutex.RUnlock()
+
+	regionInfo, ok := mc.regionEntries[regionName]
+	if !ok || regionInfo == nil {
+		return 0, false
+	}
+	return regionInfo.Headroom, true
+}
+
 /*
 	standard implementation for RawMetaWriter
 */
 
+// [notice]
+// writers below only hold locks while mutating entries, and storeState is called after
+// locks are released, since it needs to take a snapshot across all pod shards and pools
+
 func (mc *MetaCacheImp) AddContainer(podUID string, containerName string, containerInfo *types.ContainerInfo) error {
-	mc.podMutex.Lock()
-	defer mc.podMutex.Unlock()
-	if podInfo, ok := mc.podEntries[podUID]; ok {
-		if ci, ok := podInfo[containerName]; ok {
-			ci.UpdateMeta(containerInfo)
-			return nil
+	_, err := mc.AddContainerWithResult(podUID, containerName, containerInfo)
+	return err
+}
+
+func (mc *MetaCacheImp) AddContainerWithResult(podUID string, containerName string, containerInfo *types.ContainerInfo) (bool, error) {
+	events := &metaEvents{}
+	shard := mc.getPodShard(podUID)
+	shard.mutex.Lock()
+	created := shard.addContainer(podUID, containerName, containerInfo, events)
+	shard.mutex.Unlock()
+
+	err := mc.storeStateIfChanged(created, entryGroupPods)
+	mc.notifyObservers(events)
+	return created, err
+}
+
+func (mc *MetaCacheImp) SetContainerInfo(podUID string, containerName string, containerInfo *types.ContainerInfo) error {
+	events := &metaEvents{}
+	shard := mc.getPodShard(podUID)
+	shard.mutex.Lock()
+	changed := shard.setContainerInfo(podUID, containerName, containerInfo, events)
+	shard.mutex.Unlock()
+
+	err := mc.storeStateIfChanged(changed, entryGroupPods)
+	mc.notifyObservers(events)
+	return err
+}
+
+func (mc *MetaCacheImp) DeleteContainer(podUID string, containerName string) error {
+	events := &metaEvents{}
+	shard := mc.getPodShard(podUID)
+	shard.mutex.Lock()
+	changed := shard.deleteContainer(podUID, containerName, events)
+	shard.mutex.Unlock()
+
+	err := mc.storeStateIfChanged(changed, entryGroupPods)
+	mc.notifyObservers(events)
+	return err
+}
+
+func (mc *MetaCacheImp) RangeAndDeleteContainer(f func(containerInfo *types.ContainerInfo) bool) {
+	events := &metaEvents{}
+	mc.lockAllPodShards()
+	changed := mc.rangeAndDeleteContainer(f, events)
+	mc.unlockAllPodShards()
+
+	if err := mc.storeStateIfChanged(changed, entryGroupPods); err != nil {
+		klog.Errorf("[metacache] store state after range and delete container err %v", err)
+	}
+	mc.notifyObservers(events)
+}
+
+func (mc *MetaCacheImp) RangeAndUpdateContainer(f func(podUID string, containerName string, containerInfo *types.ContainerInfo) bool) {
+	events := &metaEvents{}
+	mc.lockAllPodShards()
+	changed := mc.rangeAndUpdateContainer(f, events)
+	mc.unlockAllPodShards()
+
+	_ = mc.storeStateIfChanged(changed, entryGroupPods)
+	mc.notifyObservers(events)
+}
+
+func (mc *MetaCacheImp) RemovePod(podUID string) error {
+	events := &metaEvents{}
+	shard := mc.getPodShard(podUID)
+	shard.mutex.Lock()
+	changed := shard.removePod(podUID, events)
+	shard.mutex.Unlock()
+
+	err := mc.storeStateIfChanged(changed, entryGroupPods)
+	mc.notifyObservers(events)
+	return err
+}
+
+func (mc *MetaCacheImp) SetPoolInfo(poolName string, poolInfo *types.PoolInfo) error {
+	events := &metaEvents{}
+	mc.poolMutex.Lock()
+	changed := mc.setPoolInfo(poolName, poolInfo, events)
+	mc.poolMutex.Unlock()
+
+	err := mc.storeStateIfChanged(changed, entryGroupPools)
+	mc.notifyObservers(events)
+	return err
+}
+
+func (mc *MetaCacheImp) SetPoolInfoIfVersion(poolName string, expectedRV int64, poolInfo *types.PoolInfo) (bool, error) {
+	events := &metaEvents{}
+	mc.poolMutex.Lock()
+	if mc.getPoolRevision(poolName) != expectedRV {
+		mc.poolMutex.Unlock()
+		return false, nil
+	}
+	changed := mc.setPoolInfo(poolName, poolInfo, events)
+	mc.poolMutex.Unlock()
+
+	err := mc.storeStateIfChanged(changed, entryGroupPools)
+	mc.notifyObservers(events)
+	return true, err
+}
+
+func (mc *MetaCacheImp) DeletePool(poolName string) error {
+	events := &metaEvents{}
+	mc.poolMutex.Lock()
+	changed := mc.deletePool(poolName, events)
+	mc.poolMutex.Unlock()
+
+	err := mc.storeStateIfChanged(changed, entryGroupPools)
+	mc.notifyObservers(events)
+	return err
+}
+
+func (mc *MetaCacheImp) GCPodEntries(livingPodUIDSet sets.String) error {
+	events := &metaEvents{}
+	mc.lockAllPodShards()
+	changed := mc.gcPodEntries(livingPodUIDSet, events)
+	mc.unlockAllPodShards()
+
+	err := mc.storeStateIfChanged(changed, entryGroupPods)
+	mc.notifyObservers(events)
+	return err
+}
+
+func (mc *MetaCacheImp) GCPoolEntries(livingPoolNameSet sets.String) error {
+	events := &metaEvents{}
+	mc.poolMutex.Lock()
+	changed := mc.gcPoolEntries(livingPoolNameSet, events)
+	mc.poolMutex.Unlock()
+
+	err := mc.storeStateIfChanged(changed, entryGroupPools)
+	mc.notifyObservers(events)
+	return err
+}
+
+/*
+	standard implementation for AdvisorMetaWriter
+*/
+
+func (mc *MetaCacheImp) UpdateRegionEntries(entries types.RegionEntries) error {
+	mc.regionMutex.Lock()
+	changed := mc.updateRegionEntries(entries)
+	mc.regionMutex.Unlock()
+
+	return mc.storeStateIfChanged(changed, entryGroupRegions)
+}
+
+func (mc *MetaCacheImp) SetRegionInfo(regionName string, regionInfo *types.RegionInfo) error {
+	mc.regionMutex.Lock()
+	changed := mc.setRegionInfo(regionName, regionInfo)
+	mc.regionMutex.Unlock()
+
+	return mc.storeStateIfChanged(changed, entryGroupRegions)
+}
+
+/*
+	implementation for batch writing
+*/
+
+// Transaction applies all mutations made by f in a batch, and stores state only once
+// at the end. all locks are held during f, so readers never observe a half-applied batch,
+// and in-memory entries touched by f are rolled back if f returns error. the writer passed
+// to f must not be used after f returns, and f must not call any method of metacache directly.
+func (mc *MetaCacheImp) Transaction(f func(writer MetaWriter) error) error {
+	mc.lockAllPodShards()
+	mc.poolMutex.Lock()
+	mc.regionMutex.Lock()
+
+	txn := &metaCacheTxn{
+		mc:            mc,
+		dirtyGroups:   make(map[entryGroup]bool),
+		events:        &metaEvents{},
+		podUndoLog:    make(map[string]podUndoRecord),
+		poolUndoLog:   make(map[string]poolUndoRecord),
+		regionUndoLog: make(map[string]regionUndoRecord),
+	}
+
+	err := f(txn)
+	if err != nil {
+		txn.rollback()
+	}
+
+	mc.regionMutex.Unlock()
+	mc.poolMutex.Unlock()
+	mc.unlockAllPodShards()
+
+	if err != nil {
+		klog.Errorf("[metacache] transaction failed and rolled back: %v", err)
+		return err
+	}
+	dirtyGroups := make([]entryGroup, 0, len(txn.dirtyGroups))
+	for _, group := range allEntryGroups {
+		if txn.dirtyGroups[group] {
+			dirtyGroups = append(dirtyGroups, group)
 		}
 	}
+	err = mc.storeStateIfChanged(len(dirtyGroups) > 0, dirtyGroups...)
+	mc.notifyObservers(txn.events)
+	return err
+}
+
+// podUndoRecord is the original container entries of a pod before it's touched by a
+// transaction, and ok is false if the pod was absent
+type podUndoRecord struct {
+	entries types.ContainerEntries
+	ok      bool
+}
+
+// poolUndoRecord is the original info of a pool before it's touched by a transaction,
+// and ok is false if the pool was absent
+type poolUndoRecord struct {
+	poolInfo *types.PoolInfo
+	ok       bool
+}
+
+// regionUndoRecord is the original info of a region before it's touched by a transaction,
+// and ok is false if the region was absent
+type regionUndoRecord struct {
+	regionInfo *types.RegionInfo
+	ok         bool
+}
+
+// metaCacheTxn implements MetaWriter without locking and storing state, and it records
+// an undo log of the original entries the first time each of them is touched, so that
+// only the touched entries are restored on rollback
+type metaCacheTxn struct {
+	mc          *MetaCacheImp
+	dirtyGroups map[entryGroup]bool
+	events      *metaEvents
+
+	podUndoLog    map[string]podUndoRecord
+	poolUndoLog   map[string]poolUndoRecord
+	regionUndoLog map[string]regionUndoRecord
+}
+
+var _ MetaWriter = &metaCacheTxn{}
+
+// recordPod records the original container entries of the pod if it's not recorded yet,
+// and they are cloned since container info may be updated in place
+func (t *metaCacheTxn) recordPod(podUID string) {
+	if _, ok := t.podUndoLog[podUID]; ok {
+		return
+	}
+	entries, ok := t.mc.getPodShard(podUID).entries[podUID]
+	t.podUndoLog[podUID] = podUndoRecord{entries: entries.Clone(), ok: ok}
+}
 
-	return mc.setContainerInfo(podUID, containerName, containerInfo)
+// recordPool records the original info of the pool if it's not recorded yet
+func (t *metaCacheTxn) recordPool(poolName string) {
+	if _, ok := t.poolUndoLog[poolName]; ok {
+		return
+	}
+	poolInfo, ok := t.mc.poolEntries[poolName]
+	t.poolUndoLog[poolName] = poolUndoRecord{poolInfo: poolInfo.Clone(), ok: ok}
 }
 
-func (mc *MetaCacheImp) SetContainerInfo(podUID string, containerName string, containerInfo *types.ContainerInfo) error {
-	mc.podMutex.Lock()
-	defer mc.podMutex.Unlock()
+// recordRegion records the original info of the region if it's not recorded yet
+func (t *metaCacheTxn) recordRegion(regionName string) {
+	if _, ok := t.regionUndoLog[regionName]; ok {
+		return
+	}
+	regionInfo, ok := t.mc.regionEntries[regionName]
+	t.regionUndoLog[regionName] = regionUndoRecord{regionInfo: regionInfo.Clone(), ok: ok}
+}
+
+// rollback restores the entries recorded in undo logs
+func (t *metaCacheTxn) rollback() {
+	for podUID, record := range t.podUndoLog {
+		shard := t.mc.getPodShard(podUID)
+		if record.ok {
+			shard.entries[podUID] = record.entries
+		} else {
+			delete(shard.entries, podUID)
+		}
+	}
+	for poolName, record := range t.poolUndoLog {
+		if record.ok {
+			t.mc.poolEntries[poolName] = record.poolInfo
+		} else {
+			delete(t.mc.poolEntries, poolName)
+		}
+	}
+	for regionName, record := range t.regionUndoLog {
+		if !record.ok {
+			delete(t.mc.regionEntries, regionName)
+			continue
+		}
+		if t.mc.regionEntries == nil {
+			t.mc.regionEntries = make(types.RegionEntries)
+		}
+		t.mc.regionEntries[regionName] = record.regionInfo
+	}
+}
+
+func (t *metaCacheTxn) AddContainer(podUID string, containerName string, containerInfo *types.ContainerInfo) error {
+	_, err := t.AddContainerWithResult(podUID, containerName, containerInfo)
+	return err
+}
+
+func (t *metaCacheTxn) AddContainerWithResult(podUID string, containerName string, containerInfo *types.ContainerInfo) (bool, error) {
+	t.recordPod(podUID)
+	created := t.mc.getPodShard(podUID).addContainer(podUID, containerName, containerInfo, t.events)
+	t.markDirty(created, entryGroupPods)
+	return created, nil
+}
+
+func (t *metaCacheTxn) SetContainerInfo(podUID string, containerName string, containerInfo *types.ContainerInfo) error {
+	t.recordPod(podUID)
+	t.markDirty(t.mc.getPodShard(podUID).setContainerInfo(podUID, containerName, containerInfo, t.events), entryGroupPods)
+	return nil
+}
+
+func (t *metaCacheTxn) RangeAndUpdateContainer(f func(podUID string, containerName string, containerInfo *types.ContainerInfo) bool) {
+	// container info may be updated in place by f, so each pod is recorded before f visits it
+	t.markDirty(t.mc.rangeAndUpdateContainer(func(podUID string, containerName string, containerInfo *types.ContainerInfo) bool {
+		t.recordPod(podUID)
+		return f(podUID, containerName, containerInfo)
+	}, t.events), entryGroupPods)
+}
+
+func (t *metaCacheTxn) RangeAndDeleteContainer(f func(containerInfo *types.ContainerInfo) bool) {
+	t.markDirty(t.mc.rangeAndDeleteContainer(func(containerInfo *types.ContainerInfo) bool {
+		if !f(containerInfo) {
+			return false
+		}
+		t.recordPod(containerInfo.PodUID)
+		return true
+	}, t.events), entryGroupPods)
+}
+
+func (t *metaCacheTxn) DeleteContainer(podUID string, containerName string) error {
+	t.recordPod(podUID)
+	t.markDirty(t.mc.getPodShard(podUID).deleteContainer(podUID, containerName, t.events), entryGroupPods)
+	return nil
+}
+
+func (t *metaCacheTxn) RemovePod(podUID string) error {
+	t.recordPod(podUID)
+	t.markDirty(t.mc.getPodShard(podUID).removePod(podUID, t.events), entryGroupPods)
+	return nil
+}
+
+func (t *metaCacheTxn) SetPoolInfo(poolName string, poolInfo *types.PoolInfo) error {
+	t.recordPool(poolName)
+	t.markDirty(t.mc.setPoolInfo(poolName, poolInfo, t.events), entryGroupPools)
+	return nil
+}
+
+func (t *metaCacheTxn) SetPoolInfoIfVersion(poolName string, expectedRV int64, poolInfo *types.PoolInfo) (bool, error) {
+	if t.mc.getPoolRevision(poolName) != expectedRV {
+		return false, nil
+	}
+	t.recordPool(poolName)
+	t.markDirty(t.mc.setPoolInfo(poolName, poolInfo, t.events), entryGroupPools)
+	return true, nil
+}
+
+func (t *metaCacheTxn) DeletePool(poolName string) error {
+	t.recordPool(poolName)
+	t.markDirty(t.mc.deletePool(poolName, t.events), entryGroupPools)
+	return nil
+}
+
+func (t *metaCacheTxn) GCPodEntries(livingPodUIDSet sets.String) error {
+	for _, shard := range t.mc.podShards {
+		for podUID := range shard.entries {
+			if !livingPodUIDSet.Has(podUID) {
+				t.recordPod(podUID)
+			}
+		}
+	}
+	t.markDirty(t.mc.gcPodEntries(livingPodUIDSet, t.events), entryGroupPods)
+	return nil
+}
+
+func (t *metaCacheTxn) GCPoolEntries(livingPoolNameSet sets.String) error {
+	for poolName := range t.mc.poolEntries {
+		if !livingPoolNameSet.Has(poolName) {
+			t.recordPool(poolName)
+		}
+	}
+	t.markDirty(t.mc.gcPoolEntries(livingPoolNameSet, t.events), entryGroupPools)
+	return nil
+}
+
+func (t *metaCacheTxn) UpdateRegionEntries(entries types.RegionEntries) error {
+	for regionName := range t.mc.regionEntries {
+		t.recordRegion(regionName)
+	}
+	for regionName := range entries {
+		t.recordRegion(regionName)
+	}
+	t.markDirty(t.mc.updateRegionEntries(entries), entryGroupRegions)
+	return nil
+}
+
+func (t *metaCacheTxn) SetRegionInfo(regionName string, regionInfo *types.RegionInfo) error {
+	t.recordRegion(regionName)
+	t.markDirty(t.mc.setRegionInfo(regionName, regionInfo), entryGroupRegions)
+	return nil
+}
+
+func (t *metaCacheTxn) markDirty(changed bool, group entryGroup) {
+	if changed {
+		t.dirtyGroups[group] = true
+	}
+}
+
+/*
+	internal implementation without locking, the caller should hold the corresponding locks,
+	and the returned bool indicates whether entries are changed
+*/
 
-	return mc.setContainerInfo(podUID, containerName, containerInfo)
+// addContainer returns true only if the container is newly added, since updating
+// mutable metadata of existing container doesn't need to be stored
+func (s *podShard) addContainer(podUID string, containerName string, containerInfo *types.ContainerInfo, events *metaEvents) bool {
+	if podInfo, ok := s.entries[podUID]; ok {
+		if ci, ok := podInfo[containerName]; ok {
+			oldInfo := ci.Clone()
+			if ci.UpdateMeta(containerInfo) {
+				events.addRequestChange(podUID, containerName, oldInfo, ci)
+				events.addContainerEvent(podUID, containerName, EventKindUpdated, ci)
+			}
+			return false
+		}
+	}
+	return s.setContainerInfo(podUID, containerName, containerInfo, events)
 }
 
-func (mc *MetaCacheImp) setContainerInfo(podUID string, containerName string, containerInfo *types.ContainerInfo) error {
-	podInfo, ok := mc.podEntries[podUID]
+func (s *podShard) setContainerInfo(podUID string, containerName string, containerInfo *types.ContainerInfo, events *metaEvents) bool {
+	podInfo, ok := s.entries[podUID]
 	if !ok {
-		mc.podEntries[podUID] = make(types.ContainerEntries)
-		podInfo = mc.podEntries[podUID]
+		s.entries[podUID] = make(types.ContainerEntries)
+		podInfo = s.entries[podUID]
 	}
-	if reflect.DeepEqual(podInfo[containerName], containerInfo) {
-		return nil
+	oldContainerInfo, ok := podInfo[containerName]
+	if oldContainerInfo.Equal(containerInfo) {
+		return false
 	}
 	podInfo[containerName] = containerInfo
-	return mc.storeState()
+
+	kind := EventKindAdded
+	if ok {
+		kind = EventKindUpdated
+	}
+	events.addContainerEvent(podUID, containerName, kind, containerInfo)
+	return true
 }
 
-func (mc *MetaCacheImp) deleteContainer(podUID string, containerName string) error {
-	podInfo, ok := mc.podEntries[podUID]
+func (s *podShard) deleteContainer(podUID string, containerName string, events *metaEvents) bool {
+	podInfo, ok := s.entries[podUID]
 	if !ok {
-		return nil
+		return false
 	}
-	_, ok = podInfo[containerName]
+	containerInfo, ok := podInfo[containerName]
 	if !ok {
-		return nil
+		return false
 	}
 	delete(podInfo, containerName)
 	if len(podInfo) == 0 {
-		delete(mc.podEntries, podUID)
+		delete(s.entries, podUID)
 	}
 
-	return mc.storeState()
+	events.addContainerEvent(podUID, containerName, EventKindDeleted, containerInfo)
+	return true
 }
 
-func (mc *MetaCacheImp) DeleteContainer(podUID string, containerName string) error {
-	mc.podMutex.Lock()
-	defer mc.podMutex.Unlock()
+func (s *podShard) removePod(podUID string, events *metaEvents) bool {
+	podInfo, ok := s.entries[podUID]
+	if !ok {
+		return false
+	}
+	delete(s.entries, podUID)
 
-	return mc.deleteContainer(podUID, containerName)
+	for containerName, containerInfo := range podInfo {
+		events.addContainerEvent(podUID, containerName, EventKindDeleted, containerInfo)
+	}
+	return true
 }
 
-func (mc *MetaCacheImp) RangeAndDeleteContainer(f func(containerInfo *types.ContainerInfo) bool) {
-	mc.podMutex.Lock()
-	defer mc.podMutex.Unlock()
-
-	for _, podInfo := range mc.podEntries {
-		for _, containerInfo := range podInfo {
-			if f(containerInfo) {
-				if err := mc.deleteContainer(containerInfo.PodUID, containerInfo.ContainerName); err != nil {
-					klog.Errorf("deleteContainer [%v/%v] err %v", containerInfo.PodUID, containerInfo.ContainerName, err)
+func (mc *MetaCacheImp) rangeAndDeleteContainer(f func(containerInfo *types.ContainerInfo) bool, events *metaEvents) bool {
+	changed := false
+	for _, shard := range mc.podShards {
+		for _, podInfo := range shard.entries {
+			for _, containerInfo := range podInfo {
+				if f(containerInfo) {
+					changed = shard.deleteContainer(containerInfo.PodUID, containerInfo.ContainerName, events) || changed
 				}
 			}
 		}
 	}
+	return changed
 }
 
-func (mc *MetaCacheImp) RangeAndUpdateContainer(f func(podUID string, containerName string, containerInfo *types.ContainerInfo) bool) {
-	mc.podMutex.Lock()
-	defer mc.podMutex.Unlock()
-
-	oldPodEntries := mc.podEntries.Clone()
+func (mc *MetaCacheImp) rangeAndUpdateContainer(f func(podUID string, containerName string, containerInfo *types.ContainerInfo) bool,
+	events *metaEvents) bool {
+	podEntries := mc.getPodEntriesWithoutLock()
+	oldPodEntries := podEntries.Clone()
 
-	for podUID, podInfo := range mc.podEntries {
+	for podUID, podInfo := range podEntries {
 		for containerName, containerInfo := range podInfo {
 			if !f(podUID, containerName, containerInfo) {
 				break
@@ -325,127 +994,559 @@ func (mc *MetaCacheImp) RangeAndUpdateContainer(f func(podUID string, containerN
 		}
 	}
 
-	if !reflect.DeepEqual(oldPodEntries, mc.podEntries) {
-		mc.storeState()
+	changed := false
+	for podUID, podInfo := range podEntries {
+		for containerName, containerInfo := range podInfo {
+			if !oldPodEntries[podUID][containerName].Equal(containerInfo) {
+				events.addContainerEvent(podUID, containerName, EventKindUpdated, containerInfo)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// setPoolInfo stores a copy of the given pool info and bumps the revision of pool if
+// its info is changed, and the given pool info is left untouched
+func (mc *MetaCacheImp) setPoolInfo(poolName string, poolInfo *types.PoolInfo, events *metaEvents) bool {
+	// nil pool info is taken as deletion, since maps with nil values can't be encoded by gob codec
+	if poolInfo == nil {
+		return mc.deletePool(poolName, events)
+	}
+
+	oldPoolInfo, ok := mc.poolEntries[poolName]
+	poolInfo = poolInfo.Clone()
+	poolInfo.Revision = mc.getPoolRevision(poolName)
+	if oldPoolInfo.Equal(poolInfo) {
+		return false
 	}
+	poolInfo.Revision++
+	mc.poolEntries[poolName] = poolInfo
+
+	kind := EventKindAdded
+	if ok {
+		kind = EventKindUpdated
+	}
+	events.addPoolEvent(poolName, kind, poolInfo)
+	return true
 }
 
-func (mc *MetaCacheImp) RemovePod(podUID string) error {
-	mc.podMutex.Lock()
-	defer mc.podMutex.Unlock()
+// getPoolRevision returns the current revision of pool, and it's zero if the pool is absent
+func (mc *MetaCacheImp) getPoolRevision(poolName string) int64 {
+	if poolInfo := mc.poolEntries[poolName]; poolInfo != nil {
+		return poolInfo.Revision
+	}
+	return 0
+}
 
-	_, ok := mc.podEntries[podUID]
+func (mc *MetaCacheImp) deletePool(poolName string, events *metaEvents) bool {
+	poolInfo, ok := mc.poolEntries[poolName]
 	if !ok {
-		return nil
+		return false
+	}
+	delete(mc.poolEntries, poolName)
+
+	events.addPoolEvent(poolName, EventKindDeleted, poolInfo)
+	return true
+}
+
+// updateRegionEntries replaces region entries with a copy of the given ones,
+// and regions with nil info are dropped
+func (mc *MetaCacheImp) updateRegionEntries(entries types.RegionEntries) bool {
+	regionEntries := make(types.RegionEntries, len(entries))
+	regionEntries.Merge(entries)
+	if regionEntries.Equal(mc.regionEntries) {
+		return false
+	}
+	mc.regionEntries = regionEntries
+	return true
+}
+
+// setRegionInfo stores a copy of the given region info, and nil region info is taken as deletion
+// since maps with nil values can't be encoded by gob codec
+func (mc *MetaCacheImp) setRegionInfo(regionName string, regionInfo *types.RegionInfo) bool {
+	oldRegionInfo, ok := mc.regionEntries[regionName]
+	if regionInfo == nil {
+		if !ok {
+			return false
+		}
+		delete(mc.regionEntries, regionName)
+		return true
 	}
-	delete(mc.podEntries, podUID)
 
-	return mc.storeState()
+	if ok && oldRegionInfo.Equal(regionInfo) {
+		return false
+	}
+	if mc.regionEntries == nil {
+		mc.regionEntries = make(types.RegionEntries)
+	}
+	mc.regionEntries.Merge(types.RegionEntries{regionName: regionInfo})
+	return true
+}
+
+func (mc *MetaCacheImp) gcPodEntries(livingPodUIDSet sets.String, events *metaEvents) bool {
+	changed := false
+	for _, shard := range mc.podShards {
+		for podUID := range shard.entries {
+			if _, ok := livingPodUIDSet[podUID]; !ok {
+				changed = shard.removePod(podUID, events) || changed
+			}
+		}
+	}
+	return changed
+}
+
+func (mc *MetaCacheImp) gcPoolEntries(livingPoolNameSet sets.String, events *metaEvents) bool {
+	changed := false
+	for poolName, poolInfo := range mc.poolEntries {
+		if _, ok := livingPoolNameSet[poolName]; !ok {
+			delete(mc.poolEntries, poolName)
+			events.addPoolEvent(poolName, EventKindDeleted, poolInfo)
+			changed = true
+		}
+	}
+	return changed
 }
 
 /*
-	standard implementation for AdvisorMetaWriter
+	other helper functions
 */
 
-func (mc *MetaCacheImp) SetPoolInfo(poolName string, poolInfo *types.PoolInfo) error {
-	mc.poolMutex.Lock()
-	defer mc.poolMutex.Unlock()
+func (mc *MetaCacheImp) emitEntryCount(group entryGroup, checkpoint *MetaCacheCheckpoint) {
+	count := 0
+	switch group {
+	case entryGroupPods:
+		count = len(checkpoint.PodEntries)
+	case entryGroupPools:
+		count = len(checkpoint.PoolEntries)
+	case entryGroupRegions:
+		count = len(checkpoint.RegionEntries)
+	}
 
-	if reflect.DeepEqual(mc.poolEntries[poolName], poolInfo) {
-		return nil
+	_ = mc.emitter.StoreInt64(metricMetaCacheEntryCount, int64(count), metrics.MetricTypeNameRaw,
+		metrics.MetricTag{Key: metricTagKeyEntryGroup, Val: group.String()})
+}
+
+func (mc *MetaCacheImp) emitStateDuration(metricName string, duration time.Duration, succeeded bool) {
+	status := metricTagValueStatusSuccess
+	if !succeeded {
+		status = metricTagValueStatusFailure
 	}
 
-	mc.poolEntries[poolName] = poolInfo
+	_ = mc.emitter.StoreInt64(metricName, duration.Milliseconds(), metrics.MetricTypeNameRaw,
+		metrics.MetricTag{Key: metricTagKeyStatus, Val: status})
+}
+
+// emitRequestChanges reports the new request quantities of containers changed by UpdateMeta,
+// so that advisor decisions can be correlated with vpa activities
+func (mc *MetaCacheImp) emitRequestChanges(changes []containerRequestChange) {
+	for _, change := range changes {
+		klog.Infof("[metacache] request of pod %v container %v changed, cpu: %v -> %v, memory: %v -> %v",
+			change.podUID, change.containerName, change.oldCPURequest, change.newCPURequest,
+			change.oldMemoryRequest, change.newMemoryRequest)
 
-	return mc.storeState()
+		if change.oldCPURequest != change.newCPURequest {
+			mc.emitRequestChange(change, metricTagValueResourceCPU, change.newCPURequest)
+		}
+		if change.oldMemoryRequest != change.newMemoryRequest {
+			mc.emitRequestChange(change, metricTagValueResourceMemory, change.newMemoryRequest)
+		}
+	}
 }
 
-func (mc *MetaCacheImp) DeletePool(poolName string) error {
-	mc.poolMutex.Lock()
-	defer mc.poolMutex.Unlock()
+func (mc *MetaCacheImp) emitRequestChange(change containerRequestChange, resourceName string, value float64) {
+	_ = mc.emitter.StoreFloat64(metricMetaCacheRequestChanged, value, metrics.MetricTypeNameRaw,
+		metrics.MetricTag{Key: metricTagKeyPodUID, Val: change.podUID},
+		metrics.MetricTag{Key: metricTagKeyContainer, Val: change.containerName},
+		metrics.MetricTag{Key: metricTagKeyResource, Val: resourceName})
+}
 
-	if _, ok := mc.poolEntries[poolName]; !ok {
-		return nil
+func getContainersByQoSLevel(podEntries types.PodEntries, qosLevel string) []*types.ContainerInfo {
+	var containers []*types.ContainerInfo
+	for _, podInfo := range podEntries {
+		for _, containerInfo := range podInfo {
+			if containerInfo != nil && containerInfo.QoSLevel == qosLevel {
+				containers = append(containers, containerInfo.Clone())
+			}
+		}
 	}
+	return containers
+}
 
-	delete(mc.poolEntries, poolName)
+func getContainerInfos(podEntries types.PodEntries, podUID string, containerNames []string) map[string]*types.ContainerInfo {
+	containers := make(map[string]*types.ContainerInfo, len(containerNames))
+	podInfo, ok := podEntries[podUID]
+	if !ok {
+		return containers
+	}
 
-	return mc.storeState()
+	for _, containerName := range containerNames {
+		if containerInfo, ok := podInfo[containerName]; ok {
+			containers[containerName] = containerInfo.Clone()
+		}
+	}
+	return containers
 }
 
-func (mc *MetaCacheImp) GCPoolEntries(livingPoolNameSet sets.String) error {
-	mc.poolMutex.Lock()
-	defer mc.poolMutex.Unlock()
+func getContainerCountByQoSLevel(podEntries types.PodEntries, qosLevel string) int {
+	count := 0
+	for _, podInfo := range podEntries {
+		for _, containerInfo := range podInfo {
+			if containerInfo != nil && containerInfo.QoSLevel == qosLevel {
+				count++
+			}
+		}
+	}
+	return count
+}
 
-	needStoreState := false
-	for poolName := range mc.poolEntries {
-		if _, ok := livingPoolNameSet[poolName]; !ok {
-			delete(mc.poolEntries, poolName)
-			needStoreState = true
+// getContainerMetricWithTime returns the metric value of a container, and the update time of
+// container metrics which is reported by metrics fetcher in seconds since epoch
+func getContainerMetricWithTime(metricsFetcher metric.MetricsFetcher, podUID string, containerName string,
+	metricName string) (float64, time.Time, error) {
+	value, err := metricsFetcher.GetContainerMetric(podUID, containerName, metricName)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	updateTime, err := metricsFetcher.GetContainerMetric(podUID, containerName, consts.MetricUpdateTimeContainer)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("get update time of pod %v container %v failed: %v", podUID, containerName, err)
+	}
+	return value, time.Unix(int64(updateTime), 0), nil
+}
+
+func getFreshContainerMetric(metricsFetcher metric.MetricsFetcher, podUID string, containerName string,
+	metricName string, maxAge time.Duration) (float64, error) {
+	value, updatedAt, err := getContainerMetricWithTime(metricsFetcher, podUID, containerName, metricName)
+	if err != nil {
+		return 0, err
+	}
+
+	if age := time.Since(updatedAt); age > maxAge {
+		return 0, fmt.Errorf("metric %v of pod %v container %v is stale, updated %v ago exceeding %v",
+			metricName, podUID, containerName, age.Round(time.Second), maxAge)
+	}
+	return value, nil
+}
+
+func getReclaimableCPUs(poolEntries types.PoolEntries, reclaimPoolNamePrefix string) (int, map[int]int, error) {
+	found := false
+	total, numaCPUs := 0, make(map[int]int)
+	for poolName, pi := range poolEntries {
+		if pi == nil || !strings.HasPrefix(poolName, reclaimPoolNamePrefix) {
+			continue
+		}
+
+		found = true
+		for numaID, cset := range pi.TopologyAwareAssignments {
+			numaCPUs[numaID] += cset.Size()
+			total += cset.Size()
 		}
 	}
 
-	if needStoreState {
-		return mc.storeState()
+	if !found {
+		return 0, nil, fmt.Errorf("no pool with prefix %v found", reclaimPoolNamePrefix)
 	}
+	return total, numaCPUs, nil
+}
 
-	return nil
+func (mc *MetaCacheImp) getPodShard(podUID string) *podShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(podUID))
+	return mc.podShards[h.Sum32()%podShardNum]
 }
 
-func (mc *MetaCacheImp) UpdateRegionEntries(entries types.RegionEntries) error {
-	mc.regionMutex.Lock()
-	defer mc.regionMutex.Unlock()
-	mc.regionEntries = entries.Clone()
-	return nil
+// lockAllPodShards locks all pod shards in a fixed order to avoid deadlock
+func (mc *MetaCacheImp) lockAllPodShards() {
+	for _, shard := range mc.podShards {
+		shard.mutex.Lock()
+	}
 }
 
-/*
-	other helper functions
-*/
+func (mc *MetaCacheImp) unlockAllPodShards() {
+	for i := len(mc.podShards) - 1; i >= 0; i-- {
+		mc.podShards[i].mutex.Unlock()
+	}
+}
+
+// rLockAllPodShards read-locks all pod shards in a fixed order to avoid deadlock
+func (mc *MetaCacheImp) rLockAllPodShards() {
+	for _, shard := range mc.podShards {
+		shard.mutex.RLock()
+	}
+}
+
+func (mc *MetaCacheImp) rUnlockAllPodShards() {
+	for i := len(mc.podShards) - 1; i >= 0; i-- {
+		mc.podShards[i].mutex.RUnlock()
+	}
+}
+
+// getPodEntriesWithoutLock merges entries of all pod shards without copying
+// container entries, and the caller should hold locks of all pod shards
+func (mc *MetaCacheImp) getPodEntriesWithoutLock() types.PodEntries {
+	podEntries := make(types.PodEntries)
+	for _, shard := range mc.podShards {
+		for podUID, podInfo := range shard.entries {
+			podEntries[podUID] = podInfo
+		}
+	}
+	return podEntries
+}
+
+func (mc *MetaCacheImp) storeStateIfChanged(changed bool, groups ...entryGroup) error {
+	if !changed {
+		return nil
+	}
+	if mc.asyncStoring.Load() {
+		mc.markDirty(groups...)
+		return nil
+	}
+	return mc.storeStateSelective(groups...)
+}
 
+// storeState stores all entry groups into checkpoint
 func (mc *MetaCacheImp) storeState() error {
+	return mc.storeStateSelective(allEntryGroups...)
+}
+
+// storeStateSelective marks the given entry groups dirty, and then takes a consistent snapshot of
+// all dirty groups and writes each of them into its own checkpoint, so that unchanged groups are
+// not re-encoded. it must be called without holding any entry locks
+func (mc *MetaCacheImp) storeStateSelective(groups ...entryGroup) error {
+	mc.markDirty(groups...)
+
+	mc.storeMutex.Lock()
+	defer mc.storeMutex.Unlock()
+
+	dirtyGroups := mc.popDirty()
+	if len(dirtyGroups) == 0 {
+		return nil
+	}
+
+	// each dirty group is snapshotted under its own locks
 	checkpoint := NewMetaCacheCheckpoint()
-	checkpoint.PodEntries = mc.podEntries
-	checkpoint.PoolEntries = mc.poolEntries
-	checkpoint.RegionEntries = mc.regionEntries
+	if dirtyGroups[entryGroupPods] {
+		mc.rLockAllPodShards()
+		checkpoint.PodEntries = mc.getPodEntriesWithoutLock().Clone()
+		mc.rUnlockAllPodShards()
+	}
+	if dirtyGroups[entryGroupPools] {
+		mc.poolMutex.RLock()
+		checkpoint.PoolEntries = mc.poolEntries.Clone()
+		mc.poolMutex.RUnlock()
+	}
+	if dirtyGroups[entryGroupRegions] {
+		mc.regionMutex.RLock()
+		checkpoint.RegionEntries = mc.regionEntries.Clone()
+		mc.regionMutex.RUnlock()
+	}
 
+	for _, group := range allEntryGroups {
+		if dirtyGroups[group] {
+			mc.emitEntryCount(group, checkpoint)
+		}
+	}
+
+	var errList []error
 	begin := time.Now()
 	defer func() {
 		duration := time.Since(begin)
 		if duration > storeStateWarningDuration {
 			klog.ErrorS(fmt.Errorf("storeState took too long"), "storeState took longer than expected", "expected", storeStateWarningDuration, "actual", duration.Round(time.Millisecond))
 		}
+		mc.emitStateDuration(metricMetaCacheStoreStateDuration, duration, len(errList) == 0)
 	}()
 
-	if err := mc.checkpointManager.CreateCheckpoint(mc.checkpointName, checkpoint); err != nil {
-		klog.Errorf("[metacache] store state failed: %v", err)
-		return err
+	for _, group := range allEntryGroups {
+		if !dirtyGroups[group] {
+			continue
+		}
+
+		groupCheckpoint, err := newEntryGroupCheckpoint(checkpoint.groupEntries(group), mc.checkpointCodec)
+		if err == nil {
+			err = mc.checkpointManager.CreateCheckpoint(mc.groupCheckpointName(group), groupCheckpoint)
+		}
+		if err != nil {
+			// keep the group dirty to store it again next time
+			mc.markDirty(group)
+			klog.Errorf("[metacache] store state of %v failed: %v", group, err)
+			errList = append(errList, err)
+		}
+	}
+
+	if len(errList) > 0 {
+		return utilerrors.NewAggregate(errList)
 	}
 	klog.Infof("[metacache] store state succeeded")
 
 	return nil
 }
 
-func (mc *MetaCacheImp) restoreState() error {
-	checkpoint := NewMetaCacheCheckpoint()
+func (mc *MetaCacheImp) markDirty(groups ...entryGroup) {
+	mc.dirtyMutex.Lock()
+	defer mc.dirtyMutex.Unlock()
 
-	if err := mc.checkpointManager.GetCheckpoint(mc.checkpointName, checkpoint); err != nil {
-		if err == errors.ErrCheckpointNotFound {
-			klog.Infof("[metacache] checkpoint %v not found, create", mc.checkpointName)
-			return mc.storeState()
-		} else if err == errors.ErrCorruptCheckpoint {
-			klog.Infof("[metacache] checkpoint %v corrupted, create", mc.checkpointName)
-			return mc.storeState()
-		}
+	for _, group := range groups {
+		mc.dirtyGroups[group] = true
+	}
+}
+
+func (mc *MetaCacheImp) popDirty() map[entryGroup]bool {
+	mc.dirtyMutex.Lock()
+	defer mc.dirtyMutex.Unlock()
+
+	dirtyGroups := mc.dirtyGroups
+	mc.dirtyGroups = make(map[entryGroup]bool)
+	return dirtyGroups
+}
+
+func (mc *MetaCacheImp) groupCheckpointName(group entryGroup) string {
+	return fmt.Sprintf("%v_%v", mc.checkpointName, group)
+}
+
+func (mc *MetaCacheImp) restoreState() (err error) {
+	begin := time.Now()
+	defer func() {
+		mc.emitStateDuration(metricMetaCacheRestoreStateDuration, time.Since(begin), err == nil)
+	}()
+
+	checkpoint, outdated, err := mc.loadCheckpoint()
+	if err != nil {
 		klog.Errorf("[metacache] restore state failed: %v", err)
 		return err
 	}
 
-	mc.podEntries = checkpoint.PodEntries
+	transformers := getRestoreTransformers()
+	for i, transformer := range transformers {
+		if err := transformer(checkpoint); err != nil {
+			klog.Errorf("[metacache] restore transformer %v failed: %v", i, err)
+			return err
+		}
+	}
+
+	for podUID, podInfo := range checkpoint.PodEntries {
+		mc.getPodShard(podUID).entries[podUID] = podInfo
+	}
 	mc.poolEntries = checkpoint.PoolEntries
 	mc.regionEntries = checkpoint.RegionEntries
+	for _, group := range allEntryGroups {
+		mc.emitEntryCount(group, checkpoint)
+	}
 
 	klog.Infof("[metacache] restore state succeeded")
 
+	// re-persist the transformed or outdated entries, so that migrations take effect on disk
+	if len(transformers) > 0 || outdated {
+		if err := mc.storeState(); err != nil {
+			return err
+		}
+	}
+
+	// legacy single checkpoint is kept after migration, so that state is still restored if the agent
+	// is rolled back to previous releases, and it's ignored once entry group checkpoints exist.
+	// TODO: remove legacy checkpoint after migration in the next release
+
 	return nil
 }
+
+// loadCheckpoint loads entries from checkpoint of each entry group, and falls back to the legacy
+// single checkpoint if none of them exists. entries of older schema versions are migrated to the
+// current one. outdated is true if the result isn't loaded from entry group checkpoints of current
+// schema version and codec, and it should be stored into them then
+func (mc *MetaCacheImp) loadCheckpoint() (checkpoint *MetaCacheCheckpoint, outdated bool, err error) {
+	checkpoint = NewMetaCacheCheckpoint()
+
+	// entry groups of the same schema version are assembled and migrated together,
+	// so that migrations are able to move entries across groups in common cases
+	found := false
+	versionedCheckpoints := make(map[int]*MetaCacheCheckpoint)
+	groupVersions := make(map[entryGroup]int)
+	for _, group := range allEntryGroups {
+		name := mc.groupCheckpointName(group)
+		groupCheckpoint := &entryGroupCheckpoint{}
+		if err := mc.checkpointManager.GetCheckpoint(name, groupCheckpoint); err != nil {
+			if err == errors.ErrCheckpointNotFound {
+				klog.Infof("[metacache] checkpoint %v not found", name)
+				continue
+			} else if err == errors.ErrCorruptCheckpoint {
+				klog.Infof("[metacache] checkpoint %v corrupted, ignore it", name)
+				found = true
+				outdated = true
+				continue
+			}
+			return nil, false, err
+		}
+
+		found = true
+		versioned, ok := versionedCheckpoints[groupCheckpoint.SchemaVersion]
+		if !ok {
+			versioned = NewMetaCacheCheckpoint()
+			versioned.SchemaVersion = groupCheckpoint.SchemaVersion
+			versionedCheckpoints[groupCheckpoint.SchemaVersion] = versioned
+		}
+		if err := groupCheckpoint.decodeEntries(versioned.groupEntries(group)); err != nil {
+			klog.Errorf("[metacache] unmarshal checkpoint %v failed: %v, ignore it", name, err)
+			outdated = true
+			continue
+		}
+		groupVersions[group] = groupCheckpoint.SchemaVersion
+
+		// re-encode entries if the codec is changed
+		if groupCheckpoint.codecName() != mc.checkpointCodec.Name() {
+			klog.Infof("[metacache] checkpoint %v codec changed from %v to %v",
+				name, groupCheckpoint.codecName(), mc.checkpointCodec.Name())
+			outdated = true
+		}
+	}
+
+	if found {
+		for version, versioned := range versionedCheckpoints {
+			migrated, changed := mc.upgradeCheckpoint(versioned)
+			versionedCheckpoints[version] = migrated
+			outdated = outdated || changed
+		}
+		for group, version := range groupVersions {
+			checkpoint.copyGroupEntries(group, versionedCheckpoints[version])
+		}
+		return checkpoint, outdated, nil
+	}
+
+	// schema version is absent in legacy checkpoints of v1, so it mustn't be preset before unmarshalling
+	checkpoint.SchemaVersion = 0
+	if err := mc.checkpointManager.GetCheckpoint(mc.checkpointName, checkpoint); err != nil {
+		if err == errors.ErrCheckpointNotFound {
+			klog.Infof("[metacache] checkpoint %v not found, create", mc.checkpointName)
+			return NewMetaCacheCheckpoint(), true, nil
+		} else if err == errors.ErrCorruptCheckpoint {
+			klog.Infof("[metacache] checkpoint %v corrupted, create", mc.checkpointName)
+			return NewMetaCacheCheckpoint(), true, nil
+		}
+		return nil, false, err
+	}
+
+	klog.Infof("[metacache] legacy checkpoint %v loaded", mc.checkpointName)
+	checkpoint, _ = mc.upgradeCheckpoint(checkpoint)
+	return checkpoint, true, nil
+}
+
+// upgradeCheckpoint migrates the checkpoint to current schema version, and falls back to
+// an empty checkpoint if there is no migration path. changed is true if it's not of current
+// schema version before
+func (mc *MetaCacheImp) upgradeCheckpoint(checkpoint *MetaCacheCheckpoint) (upgraded *MetaCacheCheckpoint, changed bool) {
+	version := checkpoint.getSchemaVersion()
+	if version == currentCheckpointSchemaVersion {
+		return checkpoint, false
+	}
+
+	migrated, err := migrateCheckpoint(checkpoint)
+	if err != nil {
+		klog.Errorf("[metacache] migrate checkpoint from schema version %v to %v failed: %v, drop %v",
+			version, currentCheckpointSchemaVersion, err, checkpoint.describeEntries())
+		return NewMetaCacheCheckpoint(), true
+	}
+
+	klog.Infof("[metacache] migrate checkpoint from schema version %v to %v succeeded", version, currentCheckpointSchemaVersion)
+	return migrated, true
+}
@@ -0,0 +1,872 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
+
+	"github.com/kubewharf/katalyst-core/cmd/katalyst-agent/app/options"
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+	"github.com/kubewharf/katalyst-core/pkg/config"
+	"github.com/kubewharf/katalyst-core/pkg/consts"
+	"github.com/kubewharf/katalyst-core/pkg/metaserver/agent/metric"
+	"github.com/kubewharf/katalyst-core/pkg/metrics"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
+)
+
+func generateTestConfiguration(t testing.TB, stateFileDir string) *config.Configuration {
+	conf, err := options.NewOptions().Config()
+	require.NoError(t, err)
+	require.NotNil(t, conf)
+
+	conf.GenericSysAdvisorConfiguration.StateFileDirectory = stateFileDir
+	return conf
+}
+
+func TestRestoreTransformer(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	defer func() { restoreTransformers = nil }()
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	err = mc.SetPoolInfo("share", &types.PoolInfo{
+		PoolName: "share",
+		TopologyAwareAssignments: map[int]machine.CPUSet{
+			0: machine.MustParse("0-3"),
+		},
+		OriginalTopologyAwareAssignments: map[int]machine.CPUSet{
+			0: machine.MustParse("0-3"),
+		},
+		RegionNames: sets.NewString(),
+	})
+	require.NoError(t, err)
+	err = mc.SetPoolInfo("deprecated", &types.PoolInfo{PoolName: "deprecated"})
+	require.NoError(t, err)
+
+	var order []int
+	RegisterRestoreTransformer(func(checkpoint *MetaCacheCheckpoint) error {
+		order = append(order, 0)
+		delete(checkpoint.PoolEntries, "deprecated")
+		return nil
+	})
+	RegisterRestoreTransformer(func(checkpoint *MetaCacheCheckpoint) error {
+		order = append(order, 1)
+		pi := checkpoint.PoolEntries["share"]
+		pi.OriginalTopologyAwareAssignments = map[int]machine.CPUSet{
+			0: machine.MustParse("0-7"),
+		}
+		return nil
+	})
+
+	mc, err = NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, order)
+
+	_, ok := mc.GetPoolInfo("deprecated")
+	assert.False(t, ok)
+	pi, ok := mc.GetPoolInfo("share")
+	require.True(t, ok)
+	assert.Equal(t, "0-7", pi.OriginalTopologyAwareAssignments[0].String())
+
+	// the transformed entries should have been persisted
+	checkpoint, outdated, err := mc.loadCheckpoint()
+	require.NoError(t, err)
+	assert.False(t, outdated)
+	_, ok = checkpoint.PoolEntries["deprecated"]
+	assert.False(t, ok)
+	assert.Equal(t, "0-7", checkpoint.PoolEntries["share"].OriginalTopologyAwareAssignments[0].String())
+}
+
+func TestStoreStateSelective(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	for _, group := range allEntryGroups {
+		assert.FileExists(t, filepath.Join(stateFileDir, mc.groupCheckpointName(group)))
+	}
+
+	// only the pool group is stored when pool entries change
+	podsFile := filepath.Join(stateFileDir, mc.groupCheckpointName(entryGroupPods))
+	require.NoError(t, os.Remove(podsFile))
+	require.NoError(t, mc.SetPoolInfo("share", &types.PoolInfo{PoolName: "share"}))
+	assert.NoFileExists(t, podsFile)
+
+	require.NoError(t, mc.SetContainerInfo("pod-0", "c1", &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c1"}))
+	assert.FileExists(t, podsFile)
+
+	mc2, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	_, ok := mc2.GetPoolInfo("share")
+	assert.True(t, ok)
+	_, ok = mc2.GetContainerInfo("pod-0", "c1")
+	assert.True(t, ok)
+}
+
+func TestSetRegionInfo(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, mc.UpdateRegionEntries(types.RegionEntries{
+		"share-0": {RegionType: types.QoSRegionTypeShare, Headroom: 1},
+		"share-1": {RegionType: types.QoSRegionTypeShare, Headroom: 2},
+	}))
+
+	// only the given region is updated, and its sibling is preserved
+	require.NoError(t, mc.SetRegionInfo("share-0", &types.RegionInfo{RegionType: types.QoSRegionTypeShare, Headroom: 3}))
+	regionInfo, ok := mc.GetRegionInfo("share-0")
+	require.True(t, ok)
+	assert.Equal(t, float64(3), regionInfo.Headroom)
+	regionInfo, ok = mc.GetRegionInfo("share-1")
+	require.True(t, ok)
+	assert.Equal(t, float64(2), regionInfo.Headroom)
+
+	// region entries are stored once set
+	mc2, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	regionInfo, ok = mc2.GetRegionInfo("share-0")
+	require.True(t, ok)
+	assert.Equal(t, float64(3), regionInfo.Headroom)
+}
+
+func TestGetRegionProvisionAndHeadroom(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, mc.SetRegionInfo("share-0", &types.RegionInfo{
+		RegionType: types.QoSRegionTypeShare,
+		Headroom:   10,
+		ControlKnobMap: types.ControlKnob{
+			types.ControlKnobNonReclaimedCPUSetSize: {Value: 20},
+		},
+	}))
+
+	for _, reader := range []MetaReader{mc, mc.Snapshot()} {
+		provision, ok := reader.GetRegionProvision("share-0")
+		require.True(t, ok)
+		assert.Equal(t, float64(20), provision[types.ControlKnobNonReclaimedCPUSetSize].Value)
+
+		// provision returned is a copy
+		provision[types.ControlKnobNonReclaimedCPUSetSize] = types.ControlKnobValue{Value: 30}
+		provision, ok = reader.GetRegionProvision("share-0")
+		require.True(t, ok)
+		assert.Equal(t, float64(20), provision[types.ControlKnobNonReclaimedCPUSetSize].Value)
+
+		headroom, ok := reader.GetRegionHeadroom("share-0")
+		require.True(t, ok)
+		assert.Equal(t, float64(10), headroom)
+
+		_, ok = reader.GetRegionProvision("share-1")
+		assert.False(t, ok)
+		_, ok = reader.GetRegionHeadroom("share-1")
+		assert.False(t, ok)
+	}
+}
+
+func TestAddContainerWithResult(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	created, err := mc.AddContainerWithResult("pod-0", "c1", &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c1", CPURequest: 1})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	// repeated add only updates mutable metadata
+	created, err = mc.AddContainerWithResult("pod-0", "c1", &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c1", CPURequest: 2})
+	require.NoError(t, err)
+	assert.False(t, created)
+	containerInfo, ok := mc.GetContainerInfo("pod-0", "c1")
+	require.True(t, ok)
+	assert.Equal(t, float64(2), containerInfo.CPURequest)
+
+	require.NoError(t, mc.Transaction(func(writer MetaWriter) error {
+		created, err := writer.AddContainerWithResult("pod-0", "c2", &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c2"})
+		require.NoError(t, err)
+		assert.True(t, created)
+
+		created, err = writer.AddContainerWithResult("pod-0", "c1", &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c1"})
+		require.NoError(t, err)
+		assert.False(t, created)
+		return nil
+	}))
+}
+
+// recordingEmitter records values of float64 metrics keyed by metric name
+type recordingEmitter struct {
+	metrics.DummyMetrics
+	values map[string][]float64
+}
+
+func (e *recordingEmitter) StoreFloat64(key string, val float64, _ metrics.MetricTypeName, _ ...metrics.MetricTag) error {
+	e.values[key] = append(e.values[key], val)
+	return nil
+}
+
+func TestAddContainerRequestChanged(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	emitter := &recordingEmitter{values: map[string][]float64{}}
+	mc.emitter = emitter
+
+	var events []ContainerEvent
+	mc.RegisterContainerObserver(func(event ContainerEvent) {
+		events = append(events, event)
+	})
+
+	require.NoError(t, mc.AddContainer("pod-0", "c1", &types.ContainerInfo{CPURequest: 1, MemoryRequest: 100}))
+	require.Len(t, events, 1)
+	assert.Equal(t, EventKindAdded, events[0].Kind)
+
+	// repeated add with the same or zero request changes nothing
+	require.NoError(t, mc.AddContainer("pod-0", "c1", &types.ContainerInfo{CPURequest: 1, MemoryRequest: 100}))
+	require.NoError(t, mc.AddContainer("pod-0", "c1", &types.ContainerInfo{}))
+	assert.Len(t, events, 1)
+	assert.Empty(t, emitter.values[metricMetaCacheRequestChanged])
+
+	// request adjusted by vpa
+	require.NoError(t, mc.AddContainer("pod-0", "c1", &types.ContainerInfo{CPURequest: 2, MemoryRequest: 100}))
+	require.Len(t, events, 2)
+	assert.Equal(t, EventKindUpdated, events[1].Kind)
+	assert.Equal(t, float64(2), events[1].ContainerInfo.CPURequest)
+	assert.Equal(t, []float64{2}, emitter.values[metricMetaCacheRequestChanged])
+}
+
+func TestGetContainerInfos(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, mc.AddContainer("pod-0", "c1", &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c1"}))
+	require.NoError(t, mc.AddContainer("pod-0", "c2", &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c2"}))
+
+	for _, reader := range []MetaReader{mc, mc.Snapshot()} {
+		containers, err := reader.GetContainerInfos("pod-0", []string{"c1", "c2", "c-absent"})
+		require.NoError(t, err)
+		assert.Len(t, containers, 2)
+		assert.Equal(t, "c1", containers["c1"].ContainerName)
+		assert.Equal(t, "c2", containers["c2"].ContainerName)
+		assert.NotContains(t, containers, "c-absent")
+
+		// the returned infos are copies
+		containers["c1"].CPURequest = 10
+		ci, ok := mc.GetContainerInfo("pod-0", "c1")
+		require.True(t, ok)
+		assert.Equal(t, float64(0), ci.CPURequest)
+
+		// absent pod is not an error
+		containers, err = reader.GetContainerInfos("pod-absent", []string{"c1"})
+		require.NoError(t, err)
+		assert.NotNil(t, containers)
+		assert.Empty(t, containers)
+	}
+}
+
+func TestSetPoolInfoIfVersion(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	// zero revision means the pool is expected to be absent
+	ok, err := mc.SetPoolInfoIfVersion("share", 0, &types.PoolInfo{PoolName: "share", RegionNames: sets.NewString("share-0")})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// two writers read the same revision of pool info
+	pi1, ok := mc.GetPoolInfo("share")
+	require.True(t, ok)
+	pi2, ok := mc.GetPoolInfo("share")
+	require.True(t, ok)
+	assert.Equal(t, int64(1), pi1.Revision)
+
+	pi1.RegionNames.Insert("share-1")
+	ok, err = mc.SetPoolInfoIfVersion("share", pi1.Revision, pi1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	// the given pool info is neither modified nor aliased by metacache
+	assert.Equal(t, int64(1), pi1.Revision)
+	pi1.RegionNames.Insert("share-3")
+
+	// the stale writer loses and its update is dropped
+	pi2.RegionNames.Insert("share-2")
+	ok, err = mc.SetPoolInfoIfVersion("share", pi2.Revision, pi2)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	pi, ok := mc.GetPoolInfo("share")
+	require.True(t, ok)
+	assert.Equal(t, int64(2), pi.Revision)
+	assert.Equal(t, sets.NewString("share-0", "share-1"), pi.RegionNames)
+
+	// setting unchanged pool info doesn't bump revision
+	require.NoError(t, mc.SetPoolInfo("share", pi.Clone()))
+	pi, ok = mc.GetPoolInfo("share")
+	require.True(t, ok)
+	assert.Equal(t, int64(2), pi.Revision)
+
+	require.NoError(t, mc.Transaction(func(writer MetaWriter) error {
+		ok, err := writer.SetPoolInfoIfVersion("share", 1, &types.PoolInfo{PoolName: "share"})
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		ok, err = writer.SetPoolInfoIfVersion("share", 2, &types.PoolInfo{PoolName: "share"})
+		require.NoError(t, err)
+		assert.True(t, ok)
+		return nil
+	}))
+	pi, ok = mc.GetPoolInfo("share")
+	require.True(t, ok)
+	assert.Equal(t, int64(3), pi.Revision)
+	assert.Equal(t, 0, pi.RegionNames.Len())
+}
+
+func TestContainerSamples(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	conf.SysAdvisorPluginsConfiguration.MetaCachePluginConfiguration.ContainerSampleCapacity = 3
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	assert.Error(t, mc.AppendContainerSample("pod-0", "c1", ContainerSample{}))
+	require.NoError(t, mc.AddContainer("pod-0", "c1", &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c1"}))
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, mc.AppendContainerSample("pod-0", "c1", ContainerSample{
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			Values:    map[string]float64{"cpu": float64(i)},
+		}))
+	}
+
+	// only the newest samples are kept, ordered from the oldest
+	samples := mc.GetContainerSamples("pod-0", "c1")
+	require.Len(t, samples, 3)
+	for i, sample := range samples {
+		assert.Equal(t, float64(i+2), sample.Values["cpu"])
+		assert.Equal(t, now.Add(time.Duration(i+2)*time.Second), sample.Timestamp)
+	}
+
+	// the returned samples are copies
+	samples[0].Values["cpu"] = 100
+	assert.Equal(t, float64(2), mc.GetContainerSamples("pod-0", "c1")[0].Values["cpu"])
+
+	require.NoError(t, mc.DeleteContainer("pod-0", "c1"))
+	assert.Nil(t, mc.GetContainerSamples("pod-0", "c1"))
+	assert.Empty(t, mc.sampleEntries)
+}
+
+func TestContainerSamplesDisabled(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, mc.AddContainer("pod-0", "c1", &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c1"}))
+	assert.Error(t, mc.AppendContainerSample("pod-0", "c1", ContainerSample{Timestamp: time.Now()}))
+	assert.Nil(t, mc.GetContainerSamples("pod-0", "c1"))
+}
+
+func TestRangePoolInfo(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	poolNames := sets.NewString("share", "reclaim", "reserve")
+	for _, poolName := range poolNames.List() {
+		require.NoError(t, mc.SetPoolInfo(poolName, &types.PoolInfo{PoolName: poolName}))
+	}
+
+	for _, reader := range []MetaReader{mc, mc.Snapshot()} {
+		visited := sets.NewString()
+		reader.RangePoolInfo(func(poolName string, poolInfo *types.PoolInfo) bool {
+			assert.Equal(t, poolName, poolInfo.PoolName)
+			visited.Insert(poolName)
+			return true
+		})
+		assert.Equal(t, poolNames, visited)
+
+		// iteration stops once f returns false
+		count := 0
+		reader.RangePoolInfo(func(poolName string, poolInfo *types.PoolInfo) bool {
+			count++
+			return false
+		})
+		assert.Equal(t, 1, count)
+	}
+
+	// pool info passed to f is a copy
+	mc.RangePoolInfo(func(poolName string, poolInfo *types.PoolInfo) bool {
+		poolInfo.PoolName = "modified"
+		return true
+	})
+	poolInfo, ok := mc.GetPoolInfo("share")
+	require.True(t, ok)
+	assert.Equal(t, "share", poolInfo.PoolName)
+}
+
+// countingCheckpointManager counts checkpoints created by name
+type countingCheckpointManager struct {
+	checkpointmanager.CheckpointManager
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+func (m *countingCheckpointManager) CreateCheckpoint(checkpointKey string, checkpoint checkpointmanager.Checkpoint) error {
+	m.mutex.Lock()
+	m.counts[checkpointKey]++
+	m.mutex.Unlock()
+	return m.CheckpointManager.CreateCheckpoint(checkpointKey, checkpoint)
+}
+
+func (m *countingCheckpointManager) getCount(checkpointKey string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.counts[checkpointKey]
+}
+
+func TestAsyncStoreState(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		// stop indicates whether to stop metacache before checking the stored state
+		stop bool
+	}{
+		{
+			name:     "coalesced by periodic flush",
+			interval: 100 * time.Millisecond,
+		},
+		{
+			name:     "flushed on stop",
+			interval: time.Hour,
+			stop:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateFileDir, err := ioutil.TempDir("", "metacache")
+			require.NoError(t, err)
+			defer os.RemoveAll(stateFileDir)
+
+			conf := generateTestConfiguration(t, stateFileDir)
+			conf.SysAdvisorPluginsConfiguration.MetaCachePluginConfiguration.AsyncStoreInterval = tt.interval
+			mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+			require.NoError(t, err)
+			checkpointManager := &countingCheckpointManager{
+				CheckpointManager: mc.checkpointManager,
+				counts:            make(map[string]int),
+			}
+			mc.checkpointManager = checkpointManager
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				mc.Run(ctx)
+			}()
+			require.Eventually(t, mc.asyncStoring.Load, time.Second, 10*time.Millisecond)
+
+			// writes are visible to readers immediately without storing state, and store is
+			// blocked during writes so that they are all flushed by the same store
+			poolsCheckpoint := mc.groupCheckpointName(entryGroupPools)
+			mc.storeMutex.Lock()
+			for i := 0; i < 10; i++ {
+				poolName := fmt.Sprintf("pool-%v", i)
+				require.NoError(t, mc.SetPoolInfo(poolName, &types.PoolInfo{PoolName: poolName}))
+				_, ok := mc.GetPoolInfo(poolName)
+				assert.True(t, ok)
+			}
+			mc.storeMutex.Unlock()
+
+			if tt.stop {
+				assert.Equal(t, 0, checkpointManager.getCount(poolsCheckpoint))
+				cancel()
+				<-stopped
+				assert.False(t, mc.asyncStoring.Load())
+			}
+			require.Eventually(t, func() bool {
+				return checkpointManager.getCount(poolsCheckpoint) > 0
+			}, time.Second, 10*time.Millisecond)
+			assert.Equal(t, 1, checkpointManager.getCount(poolsCheckpoint))
+
+			mc2, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+			require.NoError(t, err)
+			for i := 0; i < 10; i++ {
+				_, ok := mc2.GetPoolInfo(fmt.Sprintf("pool-%v", i))
+				assert.True(t, ok)
+			}
+		})
+	}
+}
+
+func TestRestoreLegacyCheckpoint(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	checkpointManager, err := checkpointmanager.NewCheckpointManager(stateFileDir)
+	require.NoError(t, err)
+	checkpoint := NewMetaCacheCheckpoint()
+	checkpoint.PodEntries["pod-0"] = types.ContainerEntries{
+		"c1": &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c1"},
+	}
+	checkpoint.PoolEntries["share"] = &types.PoolInfo{PoolName: "share"}
+	require.NoError(t, checkpointManager.CreateCheckpoint(stateFileName, checkpoint))
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	_, ok := mc.GetPoolInfo("share")
+	assert.True(t, ok)
+	_, ok = mc.GetContainerInfo("pod-0", "c1")
+	assert.True(t, ok)
+
+	// legacy checkpoint is migrated into entry group checkpoints, and it's kept for rolling back
+	assert.FileExists(t, filepath.Join(stateFileDir, stateFileName))
+	restored, outdated, err := mc.loadCheckpoint()
+	require.NoError(t, err)
+	assert.False(t, outdated)
+	_, ok = restored.PoolEntries["share"]
+	assert.True(t, ok)
+	_, ok = restored.PodEntries["pod-0"]
+	assert.True(t, ok)
+}
+
+func TestRestoreBrokenGroupCheckpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkpoint checkpointmanager.Checkpoint
+	}{
+		{
+			name:       "corrupted",
+			checkpoint: rawCheckpoint(`{"entries":{"pod-0":{}},"checksum":1}`),
+		},
+		{
+			name: "undecodable",
+			checkpoint: &entryGroupCheckpoint{
+				SchemaVersion: currentCheckpointSchemaVersion,
+				Codec:         CheckpointCodecGob,
+				Data:          []byte("undecodable"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateFileDir, err := ioutil.TempDir("", "metacache")
+			require.NoError(t, err)
+			defer os.RemoveAll(stateFileDir)
+
+			conf := generateTestConfiguration(t, stateFileDir)
+			mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+			require.NoError(t, err)
+			require.NoError(t, mc.SetPoolInfo("share", &types.PoolInfo{PoolName: "share"}))
+
+			checkpointManager, err := checkpointmanager.NewCheckpointManager(stateFileDir)
+			require.NoError(t, err)
+			podsCheckpoint := mc.groupCheckpointName(entryGroupPods)
+			require.NoError(t, checkpointManager.CreateCheckpoint(podsCheckpoint, tt.checkpoint))
+
+			_, outdated, err := mc.loadCheckpoint()
+			require.NoError(t, err)
+			assert.True(t, outdated)
+
+			// the broken group is stored again at restoring, while the others are kept
+			mc, err = NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+			require.NoError(t, err)
+			_, ok := mc.GetPoolInfo("share")
+			assert.True(t, ok)
+			require.NoError(t, checkpointManager.GetCheckpoint(podsCheckpoint, &entryGroupCheckpoint{}))
+			_, outdated, err = mc.loadCheckpoint()
+			require.NoError(t, err)
+			assert.False(t, outdated)
+		})
+	}
+}
+
+func TestRestoreV1Checkpoint(t *testing.T) {
+	v1Checkpoint := func() *MetaCacheCheckpoint {
+		checkpoint := NewMetaCacheCheckpoint()
+		checkpoint.SchemaVersion = 0
+		checkpoint.PodEntries["pod-0"] = types.ContainerEntries{
+			"c1": &types.ContainerInfo{PodUID: "pod-0", ContainerName: "c1"},
+		}
+		checkpoint.PoolEntries["share"] = &types.PoolInfo{PoolName: "share"}
+		return checkpoint
+	}
+
+	tests := []struct {
+		name        string
+		store       func(t *testing.T, checkpointManager checkpointmanager.CheckpointManager)
+		wantRestore bool
+	}{
+		{
+			name: "legacy checkpoint of v1",
+			store: func(t *testing.T, checkpointManager checkpointmanager.CheckpointManager) {
+				require.NoError(t, checkpointManager.CreateCheckpoint(stateFileName, v1Checkpoint()))
+			},
+			wantRestore: true,
+		},
+		{
+			// the checkpoint file is produced by metacache before schema versioning was introduced
+			name: "legacy checkpoint of v1 stored by baseline",
+			store: func(t *testing.T, checkpointManager checkpointmanager.CheckpointManager) {
+				blob, err := ioutil.ReadFile(filepath.Join("testdata", "checkpoint_v1"))
+				require.NoError(t, err)
+				require.NoError(t, checkpointManager.CreateCheckpoint(stateFileName, rawCheckpoint(blob)))
+			},
+			wantRestore: true,
+		},
+		{
+			name: "entry group checkpoints of v1",
+			store: func(t *testing.T, checkpointManager checkpointmanager.CheckpointManager) {
+				checkpoint := v1Checkpoint()
+				for _, group := range allEntryGroups {
+					groupCheckpoint, err := newEntryGroupCheckpoint(checkpoint.groupEntries(group), jsonCheckpointCodec{})
+					require.NoError(t, err)
+					groupCheckpoint.SchemaVersion = 0
+					require.NoError(t, checkpointManager.CreateCheckpoint(fmt.Sprintf("%v_%v", stateFileName, group), groupCheckpoint))
+				}
+			},
+			wantRestore: true,
+		},
+		{
+			name: "no migration path",
+			store: func(t *testing.T, checkpointManager checkpointmanager.CheckpointManager) {
+				checkpoint := v1Checkpoint()
+				checkpoint.SchemaVersion = currentCheckpointSchemaVersion + 1
+				require.NoError(t, checkpointManager.CreateCheckpoint(stateFileName, checkpoint))
+			},
+			wantRestore: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateFileDir, err := ioutil.TempDir("", "metacache")
+			require.NoError(t, err)
+			defer os.RemoveAll(stateFileDir)
+
+			checkpointManager, err := checkpointmanager.NewCheckpointManager(stateFileDir)
+			require.NoError(t, err)
+			tt.store(t, checkpointManager)
+
+			conf := generateTestConfiguration(t, stateFileDir)
+			mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+			require.NoError(t, err)
+			_, ok := mc.GetPoolInfo("share")
+			assert.Equal(t, tt.wantRestore, ok)
+			_, ok = mc.GetContainerInfo("pod-0", "c1")
+			assert.Equal(t, tt.wantRestore, ok)
+
+			// entries are persisted with current schema version
+			for _, group := range allEntryGroups {
+				groupCheckpoint := &entryGroupCheckpoint{}
+				require.NoError(t, checkpointManager.GetCheckpoint(mc.groupCheckpointName(group), groupCheckpoint))
+				assert.Equal(t, currentCheckpointSchemaVersion, groupCheckpoint.SchemaVersion)
+			}
+			_, outdated, err := mc.loadCheckpoint()
+			require.NoError(t, err)
+			assert.False(t, outdated)
+		})
+	}
+}
+
+// rawCheckpoint is used to store checkpoint files as they are
+type rawCheckpoint []byte
+
+func (cp rawCheckpoint) MarshalCheckpoint() ([]byte, error) {
+	return cp, nil
+}
+
+func (cp rawCheckpoint) UnmarshalCheckpoint(_ []byte) error {
+	return nil
+}
+
+func (cp rawCheckpoint) VerifyChecksum() error {
+	return nil
+}
+
+func TestPodShards(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(t, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	podNum := 4 * podShardNum
+	wg := sync.WaitGroup{}
+	for i := 0; i < podNum; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			podUID := fmt.Sprintf("pod-%d", i)
+			assert.NoError(t, mc.SetContainerInfo(podUID, "c1", &types.ContainerInfo{PodUID: podUID, ContainerName: "c1"}))
+			assert.NoError(t, mc.SetContainerInfo(podUID, "c2", &types.ContainerInfo{PodUID: podUID, ContainerName: "c2"}))
+			assert.NoError(t, mc.DeleteContainer(podUID, "c2"))
+		}(i)
+	}
+	wg.Wait()
+
+	count := 0
+	mc.RangeContainer(func(podUID string, containerName string, containerInfo *types.ContainerInfo) bool {
+		assert.Equal(t, "c1", containerName)
+		count++
+		return true
+	})
+	assert.Equal(t, podNum, count)
+
+	mc.RangeAndDeleteContainer(func(containerInfo *types.ContainerInfo) bool {
+		return containerInfo.PodUID != "pod-0"
+	})
+
+	// all pod shards are restored from the checkpoint written by the last store
+	mc2, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	for i := 0; i < podNum; i++ {
+		podUID := fmt.Sprintf("pod-%d", i)
+		_, ok := mc2.GetContainerInfo(podUID, "c1")
+		assert.Equal(t, i == 0, ok)
+	}
+}
+
+func BenchmarkMetaCacheConcurrentReadWrite(b *testing.B) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(b, err)
+	defer os.RemoveAll(stateFileDir)
+
+	conf := generateTestConfiguration(b, stateFileDir)
+	mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(b, err)
+
+	podNum := 256
+	for i := 0; i < podNum; i++ {
+		podUID := fmt.Sprintf("pod-%d", i)
+		require.NoError(b, mc.SetContainerInfo(podUID, "c1", &types.ContainerInfo{PodUID: podUID, ContainerName: "c1"}))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			podUID := fmt.Sprintf("pod-%d", i%podNum)
+			// one write for every ten reads
+			if i%10 == 0 {
+				_ = mc.SetContainerInfo(podUID, "c1", &types.ContainerInfo{PodUID: podUID, ContainerName: "c1", CPURequest: float64(i)})
+			} else {
+				mc.GetContainerInfo(podUID, "c1")
+			}
+			i++
+		}
+	})
+}
+
+func TestGetFreshContainerMetric(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	fetcher := metric.NewFakeMetricsFetcher(metrics.DummyMetrics{}).(*metric.FakeMetricsFetcher)
+	mc, err := NewMetaCacheImp(generateTestConfiguration(t, stateFileDir), metricspool.DummyMetricsEmitterPool{}, fetcher)
+	require.NoError(t, err)
+
+	now := time.Now()
+	fetcher.SetContainerMetric("fresh-pod", "c1", consts.MetricCPUUsageContainer, 2)
+	fetcher.SetContainerMetric("fresh-pod", "c1", consts.MetricUpdateTimeContainer, float64(now.Unix()))
+	fetcher.SetContainerMetric("stale-pod", "c1", consts.MetricCPUUsageContainer, 3)
+	fetcher.SetContainerMetric("stale-pod", "c1", consts.MetricUpdateTimeContainer, float64(now.Add(-5*time.Minute).Unix()))
+	fetcher.SetContainerMetric("untimed-pod", "c1", consts.MetricCPUUsageContainer, 4)
+
+	value, updatedAt, err := mc.GetContainerMetricWithTime("stale-pod", "c1", consts.MetricCPUUsageContainer)
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, value)
+	assert.Equal(t, now.Add(-5*time.Minute).Unix(), updatedAt.Unix())
+
+	value, err = mc.GetFreshContainerMetric("fresh-pod", "c1", consts.MetricCPUUsageContainer, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, value)
+
+	_, err = mc.GetFreshContainerMetric("stale-pod", "c1", consts.MetricCPUUsageContainer, time.Minute)
+	assert.Error(t, err)
+	value, err = mc.Snapshot().GetFreshContainerMetric("stale-pod", "c1", consts.MetricCPUUsageContainer, 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, value)
+
+	// metrics without update time can't be checked for staleness
+	_, _, err = mc.GetContainerMetricWithTime("untimed-pod", "c1", consts.MetricCPUUsageContainer)
+	assert.Error(t, err)
+	_, _, err = mc.GetContainerMetricWithTime("absent-pod", "c1", consts.MetricCPUUsageContainer)
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+)
+
+// EventKind is the kind of lifecycle event of metacache entries
+type EventKind string
+
+const (
+	EventKindAdded   EventKind = "Added"
+	EventKindUpdated EventKind = "Updated"
+	EventKindDeleted EventKind = "Deleted"
+)
+
+// ContainerEvent is fired when a container is added, updated or deleted in metacache,
+// and ContainerInfo is the latest info, or the last info before deletion
+type ContainerEvent struct {
+	PodUID        string
+	ContainerName string
+	Kind          EventKind
+	ContainerInfo *types.ContainerInfo
+}
+
+// PoolEvent is fired when a pool is added, updated or deleted in metacache,
+// and PoolInfo is the latest info, or the last info before deletion
+type PoolEvent struct {
+	PoolName string
+	Kind     EventKind
+	PoolInfo *types.PoolInfo
+}
+
+type ContainerObserver func(event ContainerEvent)
+
+type PoolObserver func(event PoolEvent)
+
+// metaEvents collects events during mutations, and they will be dispatched
+// to observers after state is stored and all locks are released
+type metaEvents struct {
+	containerEvents []ContainerEvent
+	poolEvents      []PoolEvent
+	requestChanges  []containerRequestChange
+}
+
+// containerRequestChange records the request quantities of container before and after UpdateMeta
+type containerRequestChange struct {
+	podUID           string
+	containerName    string
+	oldCPURequest    float64
+	newCPURequest    float64
+	oldMemoryRequest float64
+	newMemoryRequest float64
+}
+
+func (e *metaEvents) addContainerEvent(podUID, containerName string, kind EventKind, containerInfo *types.ContainerInfo) {
+	e.containerEvents = append(e.containerEvents, ContainerEvent{
+		PodUID:        podUID,
+		ContainerName: containerName,
+		Kind:          kind,
+		ContainerInfo: containerInfo.Clone(),
+	})
+}
+
+func (e *metaEvents) addRequestChange(podUID, containerName string, oldInfo, newInfo *types.ContainerInfo) {
+	e.requestChanges = append(e.requestChanges, containerRequestChange{
+		podUID:           podUID,
+		containerName:    containerName,
+		oldCPURequest:    oldInfo.CPURequest,
+		newCPURequest:    newInfo.CPURequest,
+		oldMemoryRequest: oldInfo.MemoryRequest,
+		newMemoryRequest: newInfo.MemoryRequest,
+	})
+}
+
+func (e *metaEvents) addPoolEvent(poolName string, kind EventKind, poolInfo *types.PoolInfo) {
+	e.poolEvents = append(e.poolEvents, PoolEvent{
+		PoolName: poolName,
+		Kind:     kind,
+		PoolInfo: poolInfo.Clone(),
+	})
+}
+
+// RegisterContainerObserver registers an observer to be notified of container lifecycle events.
+// observers are called synchronously outside of any locks, so they are allowed to call back into
+// the reader api, but they should return quickly to avoid blocking writers
+func (mc *MetaCacheImp) RegisterContainerObserver(observer ContainerObserver) {
+	mc.observerMutex.Lock()
+	defer mc.observerMutex.Unlock()
+
+	mc.containerObservers = append(mc.containerObservers, observer)
+}
+
+// RegisterPoolObserver registers an observer to be notified of pool lifecycle events,
+// with the same calling convention as RegisterContainerObserver
+func (mc *MetaCacheImp) RegisterPoolObserver(observer PoolObserver) {
+	mc.observerMutex.Lock()
+	defer mc.observerMutex.Unlock()
+
+	mc.poolObservers = append(mc.poolObservers, observer)
+}
+
+// notifyObservers dispatches events to observers, and each observer receives its own
+// deep copy so that it can't mutate metacache or affect other observers. request changes
+// of containers are also reported here since they are collected in the same way.
+func (mc *MetaCacheImp) notifyObservers(events *metaEvents) {
+	mc.emitRequestChanges(events.requestChanges)
+
+	mc.observerMutex.RLock()
+	containerObservers := append([]ContainerObserver{}, mc.containerObservers...)
+	poolObservers := append([]PoolObserver{}, mc.poolObservers...)
+	mc.observerMutex.RUnlock()
+
+	for _, event := range events.containerEvents {
+		for _, observer := range containerObservers {
+			e := event
+			e.ContainerInfo = event.ContainerInfo.Clone()
+			observer(e)
+		}
+	}
+
+	for _, event := range events.poolEvents {
+		for _, observer := range poolObservers {
+			e := event
+			e.PoolInfo = event.PoolInfo.Clone()
+			observer(e)
+		}
+	}
+}
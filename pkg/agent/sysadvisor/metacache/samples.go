@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"fmt"
+	"time"
+)
+
+// ContainerSample is a snapshot of container values at a certain time,
+// which is kept in memory for trend-based policies
+type ContainerSample struct {
+	Timestamp time.Time
+	Values    map[string]float64
+}
+
+func (s ContainerSample) clone() ContainerSample {
+	cloned := ContainerSample{Timestamp: s.Timestamp}
+	if s.Values != nil {
+		cloned.Values = make(map[string]float64, len(s.Values))
+		for k, v := range s.Values {
+			cloned.Values[k] = v
+		}
+	}
+	return cloned
+}
+
+// sampleRing is a fixed capacity ring buffer of container samples,
+// and the oldest sample is evicted once the ring is full
+type sampleRing struct {
+	samples []ContainerSample
+	// next is the index to write the next sample into
+	next int
+	full bool
+}
+
+func newSampleRing(capacity int) *sampleRing {
+	return &sampleRing{samples: make([]ContainerSample, capacity)}
+}
+
+func (r *sampleRing) append(sample ContainerSample) {
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns deep copied samples ordered from the oldest to the newest
+func (r *sampleRing) list() []ContainerSample {
+	var ordered []ContainerSample
+	if r.full {
+		ordered = append(ordered, r.samples[r.next:]...)
+	}
+	ordered = append(ordered, r.samples[:r.next]...)
+
+	ret := make([]ContainerSample, 0, len(ordered))
+	for _, sample := range ordered {
+		ret = append(ret, sample.clone())
+	}
+	return ret
+}
+
+// sampleEntries stores sample rings keyed by pod uid and container name
+type sampleEntries map[string]map[string]*sampleRing
+
+// AppendContainerSample appends a sample into the history of an existing container. samples are
+// only kept in memory with a fixed capacity per container, so they are never stored into checkpoints,
+// and they are cleaned up once the container is deleted
+func (mc *MetaCacheImp) AppendContainerSample(podUID string, containerName string, sample ContainerSample) error {
+	if mc.containerSampleCapacity <= 0 {
+		return fmt.Errorf("container samples are disabled")
+	}
+
+	shard := mc.getPodShard(podUID)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	// hold the shard lock so that the container won't be deleted before the sample is appended
+	if _, ok := shard.entries[podUID][containerName]; !ok {
+		return fmt.Errorf("container %v/%v not found", podUID, containerName)
+	}
+
+	mc.sampleMutex.Lock()
+	defer mc.sampleMutex.Unlock()
+
+	podSamples, ok := mc.sampleEntries[podUID]
+	if !ok {
+		podSamples = make(map[string]*sampleRing)
+		mc.sampleEntries[podUID] = podSamples
+	}
+	ring, ok := podSamples[containerName]
+	if !ok {
+		ring = newSampleRing(mc.containerSampleCapacity)
+		podSamples[containerName] = ring
+	}
+	ring.append(sample.clone())
+	return nil
+}
+
+// GetContainerSamples returns samples of a container ordered from the oldest to the newest
+func (mc *MetaCacheImp) GetContainerSamples(podUID string, containerName string) []ContainerSample {
+	mc.sampleMutex.RLock()
+	defer mc.sampleMutex.RUnlock()
+
+	ring, ok := mc.sampleEntries[podUID][containerName]
+	if !ok {
+		return nil
+	}
+	return ring.list()
+}
+
+// cleanupContainerSamples is registered as a container observer to clean up
+// samples of deleted containers
+func (mc *MetaCacheImp) cleanupContainerSamples(event ContainerEvent) {
+	if event.Kind != EventKindDeleted {
+		return
+	}
+
+	mc.sampleMutex.Lock()
+	defer mc.sampleMutex.Unlock()
+
+	podSamples, ok := mc.sampleEntries[event.PodUID]
+	if !ok {
+		return
+	}
+	delete(podSamples, event.ContainerName)
+	if len(podSamples) == 0 {
+		delete(mc.sampleEntries, event.PodUID)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"time"
+
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+	"github.com/kubewharf/katalyst-core/pkg/metaserver/agent/metric"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
+)
+
+// MetaSnapshot is a consistent point-in-time view of metacache entries. it's detached from
+// subsequent writes to metacache, and it's immutable since all readers return deep copies.
+// notice that container metrics are not snapshotted, and they are still read from metrics fetcher.
+type MetaSnapshot struct {
+	podEntries    types.PodEntries
+	poolEntries   types.PoolEntries
+	regionEntries types.RegionEntries
+
+	metricsFetcher        metric.MetricsFetcher
+	reclaimPoolNamePrefix string
+}
+
+var _ MetaReader = &MetaSnapshot{}
+
+// Snapshot returns a consistent point-in-time view of all entries, and it's cheap
+// enough to be called once per advisor cycle since no checkpoint is involved
+func (mc *MetaCacheImp) Snapshot() *MetaSnapshot {
+	mc.rLockAllPodShards()
+	mc.poolMutex.RLock()
+	mc.regionMutex.RLock()
+	defer func() {
+		mc.regionMutex.RUnlock()
+		mc.poolMutex.RUnlock()
+		mc.rUnlockAllPodShards()
+	}()
+
+	return &MetaSnapshot{
+		podEntries:            mc.getPodEntriesWithoutLock().Clone(),
+		poolEntries:           mc.poolEntries.Clone(),
+		regionEntries:         mc.regionEntries.Clone(),
+		metricsFetcher:        mc.metricsFetcher,
+		reclaimPoolNamePrefix: mc.reclaimPoolNamePrefix,
+	}
+}
+
+func (ms *MetaSnapshot) GetContainerEntries(podUID string) (types.ContainerEntries, bool) {
+	v, ok := ms.podEntries[podUID]
+	return v.Clone(), ok
+}
+
+func (ms *MetaSnapshot) GetContainerInfo(podUID string, containerName string) (*types.ContainerInfo, bool) {
+	podInfo, ok := ms.podEntries[podUID]
+	if !ok {
+		return nil, false
+	}
+	containerInfo, ok := podInfo[containerName]
+
+	return containerInfo.Clone(), ok
+}
+
+func (ms *MetaSnapshot) GetContainerInfos(podUID string, containerNames []string) (map[string]*types.ContainerInfo, error) {
+	return getContainerInfos(ms.podEntries, podUID, containerNames), nil
+}
+
+func (ms *MetaSnapshot) GetContainerMetric(podUID string, containerName string, metricName string) (float64, error) {
+	return ms.metricsFetcher.GetContainerMetric(podUID, containerName, metricName)
+}
+
+func (ms *MetaSnapshot) GetContainerMetricWithTime(podUID string, containerName string, metricName string) (float64, time.Time, error) {
+	return getContainerMetricWithTime(ms.metricsFetcher, podUID, containerName, metricName)
+}
+
+func (ms *MetaSnapshot) GetFreshContainerMetric(podUID string, containerName string, metricName string, maxAge time.Duration) (float64, error) {
+	return getFreshContainerMetric(ms.metricsFetcher, podUID, containerName, metricName, maxAge)
+}
+
+func (ms *MetaSnapshot) RangeContainer(f func(podUID string, containerName string, containerInfo *types.ContainerInfo) bool) {
+	for podUID, podInfo := range ms.podEntries {
+		for containerName, containerInfo := range podInfo {
+			if !f(podUID, containerName, containerInfo.Clone()) {
+				break
+			}
+		}
+	}
+}
+
+func (ms *MetaSnapshot) GetContainersByQoSLevel(qosLevel string) []*types.ContainerInfo {
+	return getContainersByQoSLevel(ms.podEntries, qosLevel)
+}
+
+func (ms *MetaSnapshot) GetContainerCountByQoSLevel(qosLevel string) int {
+	return getContainerCountByQoSLevel(ms.podEntries, qosLevel)
+}
+
+func (ms *MetaSnapshot) GetPoolInfo(poolName string) (*types.PoolInfo, bool) {
+	poolInfo, ok := ms.poolEntries[poolName]
+	return poolInfo.Clone(), ok
+}
+
+func (ms *MetaSnapshot) GetPoolSize(poolName string) (int, bool) {
+	pi, ok := ms.poolEntries[poolName]
+	if !ok {
+		return 0, false
+	}
+	return machine.CountCPUAssignmentCPUs(pi.TopologyAwareAssignments), true
+}
+
+func (ms *MetaSnapshot) GetReclaimableCPUs() (int, map[int]int, error) {
+	return getReclaimableCPUs(ms.poolEntries, ms.reclaimPoolNamePrefix)
+}
+
+func (ms *MetaSnapshot) RangePoolInfo(f func(poolName string, poolInfo *types.PoolInfo) bool) {
+	for poolName, poolInfo := range ms.poolEntries {
+		if !f(poolName, poolInfo.Clone()) {
+			break
+		}
+	}
+}
+
+func (ms *MetaSnapshot) GetRegionInfo(regionName string) (*types.RegionInfo, bool) {
+	regionInfo, ok := ms.regionEntries[regionName]
+	return regionInfo.Clone(), ok
+}
+
+func (ms *MetaSnapshot) RangeRegionInfo(f func(regionName string, regionInfo *types.RegionInfo) bool) {
+	for regionName, regionInfo := range ms.regionEntries {
+		if !f(regionName, regionInfo.Clone()) {
+			break
+		}
+	}
+}
+
+func (ms *MetaSnapshot) GetRegionProvision(regionName string) (types.ControlKnob, bool) {
+	regionInfo, ok := ms.regionEntries[regionName]
+	if !ok || regionInfo == nil {
+		return nil, false
+	}
+	return regionInfo.ControlKnobMap.Clone(), true
+}
+
+func (ms *MetaSnapshot) GetRegionHeadroom(regionName string) (float64, bool) {
+	regionInfo, ok := ms.regionEntries[regionName]
+	if !ok || regionInfo == nil {
+		return 0, false
+	}
+	return regionInfo.Headroom, true
+}
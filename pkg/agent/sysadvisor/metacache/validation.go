@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"fmt"
+
+	"k8s.io/klog/v2"
+
+	metacacheconfig "github.com/kubewharf/katalyst-core/pkg/config/agent/sysadvisor/metacache"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
+)
+
+const (
+	// CheckpointValidationPolicyDropInvalid drops invalid entries and keeps the valid ones
+	CheckpointValidationPolicyDropInvalid = metacacheconfig.CheckpointValidationPolicyDropInvalid
+	// CheckpointValidationPolicyRejectAll drops all entries of a group if any of them is invalid
+	CheckpointValidationPolicyRejectAll = metacacheconfig.CheckpointValidationPolicyRejectAll
+)
+
+// checkValidationPolicy returns error if the checkpoint validation policy is unknown,
+// and empty policy is taken as dropping invalid entries
+func checkValidationPolicy(policy string) error {
+	switch policy {
+	case "", CheckpointValidationPolicyDropInvalid, CheckpointValidationPolicyRejectAll:
+		return nil
+	default:
+		return fmt.Errorf("unknown checkpoint validation policy %v", policy)
+	}
+}
+
+// ValidateState checks restored pool and region entries against the cpu topology of machine,
+// since checkpoints stored before hardware changes may refer to cpus or numa nodes not existing
+// anymore. nil entries stored by previous versions are invalid as well. invalid entries are
+// dropped according to the validation policy, and the cleaned state is stored again. it should
+// be called right after metacache is created.
+func (mc *MetaCacheImp) ValidateState(topology *machine.CPUTopology) error {
+	if topology == nil {
+		return fmt.Errorf("validate state with nil topology")
+	}
+
+	rejectAll := mc.checkpointValidationPolicy == CheckpointValidationPolicyRejectAll
+
+	events := &metaEvents{}
+	var dirtyGroups []entryGroup
+
+	mc.poolMutex.Lock()
+	invalidPools := make([]string, 0)
+	for poolName, poolInfo := range mc.poolEntries {
+		if poolInfo == nil {
+			klog.Errorf("[metacache] pool %v is invalid: nil pool info", poolName)
+			invalidPools = append(invalidPools, poolName)
+			continue
+		}
+
+		err := machine.ValidateCPUAssignment(poolInfo.TopologyAwareAssignments, topology)
+		if err == nil {
+			err = machine.ValidateCPUAssignment(poolInfo.OriginalTopologyAwareAssignments, topology)
+		}
+		if err != nil {
+			klog.Errorf("[metacache] pool %v is invalid: %v", poolName, err)
+			invalidPools = append(invalidPools, poolName)
+		}
+	}
+	if len(invalidPools) > 0 {
+		if rejectAll {
+			invalidPools = invalidPools[:0]
+			for poolName := range mc.poolEntries {
+				invalidPools = append(invalidPools, poolName)
+			}
+		}
+		for _, poolName := range invalidPools {
+			mc.deletePool(poolName, events)
+		}
+		klog.Warningf("[metacache] dropped pools %v with validation policy %v", invalidPools, mc.checkpointValidationPolicy)
+		dirtyGroups = append(dirtyGroups, entryGroupPools)
+	}
+	mc.poolMutex.Unlock()
+
+	mc.regionMutex.Lock()
+	numaNodes := topology.CPUDetails.NUMANodes()
+	invalidRegions := make([]string, 0)
+	for regionName, regionInfo := range mc.regionEntries {
+		if regionInfo == nil {
+			klog.Errorf("[metacache] region %v is invalid: nil region info", regionName)
+			invalidRegions = append(invalidRegions, regionName)
+		} else if !regionInfo.BindingNumas.IsSubsetOf(numaNodes) {
+			klog.Errorf("[metacache] region %v is invalid: numas %v not found in topology",
+				regionName, regionInfo.BindingNumas.Difference(numaNodes))
+			invalidRegions = append(invalidRegions, regionName)
+		}
+	}
+	if len(invalidRegions) > 0 {
+		if rejectAll {
+			invalidRegions = invalidRegions[:0]
+			for regionName := range mc.regionEntries {
+				invalidRegions = append(invalidRegions, regionName)
+			}
+		}
+		for _, regionName := range invalidRegions {
+			delete(mc.regionEntries, regionName)
+		}
+		klog.Warningf("[metacache] dropped regions %v with validation policy %v", invalidRegions, mc.checkpointValidationPolicy)
+		dirtyGroups = append(dirtyGroups, entryGroupRegions)
+	}
+	mc.regionMutex.Unlock()
+
+	err := mc.storeStateIfChanged(len(dirtyGroups) > 0, dirtyGroups...)
+	mc.notifyObservers(events)
+	return err
+}
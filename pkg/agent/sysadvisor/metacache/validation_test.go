@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metacache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
+)
+
+func TestValidateState(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		wantPools   []string
+		wantRegions []string
+	}{
+		{
+			name:        "drop invalid",
+			policy:      CheckpointValidationPolicyDropInvalid,
+			wantPools:   []string{"share"},
+			wantRegions: []string{"share-0"},
+		},
+		{
+			name:        "reject all",
+			policy:      CheckpointValidationPolicyRejectAll,
+			wantPools:   []string{},
+			wantRegions: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateFileDir, err := ioutil.TempDir("", "metacache")
+			require.NoError(t, err)
+			defer os.RemoveAll(stateFileDir)
+
+			// store a checkpoint with a pool and a region referring to cpus and numa nodes out of range
+			conf := generateTestConfiguration(t, stateFileDir)
+			conf.SysAdvisorPluginsConfiguration.MetaCachePluginConfiguration.CheckpointValidationPolicy = tt.policy
+			mc, err := NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+			require.NoError(t, err)
+			require.NoError(t, mc.SetPoolInfo("share", &types.PoolInfo{
+				PoolName:                 "share",
+				TopologyAwareAssignments: map[int]machine.CPUSet{0: machine.MustParse("0-3")},
+			}))
+			require.NoError(t, mc.SetPoolInfo("reclaim", &types.PoolInfo{
+				PoolName:                 "reclaim",
+				TopologyAwareAssignments: map[int]machine.CPUSet{1: machine.MustParse("4-7,100")},
+			}))
+			require.NoError(t, mc.SetRegionInfo("share-0", &types.RegionInfo{BindingNumas: machine.NewCPUSet(0)}))
+			require.NoError(t, mc.SetRegionInfo("share-1", &types.RegionInfo{BindingNumas: machine.NewCPUSet(5)}))
+
+			topology, err := machine.GenerateDummyCPUTopology(16, 1, 2)
+			require.NoError(t, err)
+			mc, err = NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+			require.NoError(t, err)
+
+			// nil entries may be restored from checkpoints stored by previous versions
+			mc.poolEntries["nil"] = nil
+			mc.regionEntries["nil"] = nil
+			require.NoError(t, mc.ValidateState(topology))
+
+			// the cleaned state is stored again
+			mc, err = NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+			require.NoError(t, err)
+			pools := make([]string, 0)
+			mc.RangePoolInfo(func(poolName string, _ *types.PoolInfo) bool {
+				pools = append(pools, poolName)
+				return true
+			})
+			assert.ElementsMatch(t, tt.wantPools, pools)
+			regions := make([]string, 0)
+			mc.RangeRegionInfo(func(regionName string, _ *types.RegionInfo) bool {
+				regions = append(regions, regionName)
+				return true
+			})
+			assert.ElementsMatch(t, tt.wantRegions, regions)
+		})
+	}
+}
+
+func TestUnknownValidationPolicy(t *testing.T) {
+	stateFileDir, err := ioutil.TempDir("", "metacache")
+	require.NoError(t, err)
+	defer os.RemoveAll(stateFileDir)
+
+	// unknown policy is rejected before restoring state
+	conf := generateTestConfiguration(t, stateFileDir)
+	conf.SysAdvisorPluginsConfiguration.MetaCachePluginConfiguration.CheckpointValidationPolicy = "unknown"
+	_, err = NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.Error(t, err)
+}
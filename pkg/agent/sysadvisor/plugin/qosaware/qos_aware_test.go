@@ -73,7 +73,7 @@ func generateTestMetaServer(t *testing.T, conf *config.Configuration) *metaserve
 }
 
 func generateTestMetaCache(t *testing.T, conf *config.Configuration) *metacache.MetaCacheImp {
-	metaCache, err := metacache.NewMetaCacheImp(conf, metric.NewFakeMetricsFetcher(metrics.DummyMetrics{}))
+	metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metric.NewFakeMetricsFetcher(metrics.DummyMetrics{}))
 	require.NoError(t, err)
 	require.NotNil(t, metaCache)
 
@@ -201,6 +201,12 @@ func (cra *cpuResourceAdvisor) update() {
 	}
 	klog.Infof("[qosaware-cpu] region map: %v", general.ToString(cra.regionMap))
 
+	// numa nodes with reclaim disabled override the node-level enable reclaim
+	numaEnableReclaim := make(map[int]bool)
+	for _, numaID := range cra.conf.ReclaimedResourceConfiguration.DisableReclaimNUMAs() {
+		numaEnableReclaim[numaID] = false
+	}
+
 	// run an episode of provision policy update for each region
 	for _, r := range cra.regionMap {
 		regionNumas := r.GetBindingNumas()
@@ -232,6 +238,7 @@ func (cra *cpuResourceAdvisor) update() {
 			ReservePoolSize:     regionReservePoolSize,
 			ReservedForAllocate: regionReservedForAllocate,
 			EnableReclaim:       cra.conf.ReclaimedResourceConfiguration.EnableReclaim(),
+			NUMAEnableReclaim:   numaEnableReclaim,
 		})
 
 		r.TryUpdateProvision()
@@ -37,6 +37,9 @@ type HeadroomPolicy interface {
 	Update() error
 	// GetHeadroom returns the latest headroom estimation
 	GetHeadroom() (float64, error)
+	// GetHeadroomWithConfidence returns the latest headroom estimation with its confidence
+	// in [0, 1], and consumers should act less aggressively on headroom with low confidence
+	GetHeadroomWithConfidence() (float64, float64, error)
 }
 
 type InitFunc func(regionName string, conf *config.Configuration, extraConfig interface{}, metaReader metacache.MetaReader,
@@ -65,9 +65,28 @@ func (p *PolicyCanonical) Update() error {
 		return fmt.Errorf("region type %v is invalid", regionInfo.RegionType)
 	}
 
+	// headroom is assumed to be evenly distributed among binding numas of the region,
+	// and the part on numa nodes with reclaim disabled is excluded
+	bindingNumas := regionInfo.BindingNumas.ToSliceInt()
+	if len(bindingNumas) > 0 {
+		enabledNumas := 0
+		for _, numaID := range bindingNumas {
+			if enable, ok := p.essentials.NUMAEnableReclaim[numaID]; !ok || enable {
+				enabledNumas++
+			}
+		}
+		p.headroom = p.headroom * float64(enabledNumas) / float64(len(bindingNumas))
+	}
+
 	return nil
 }
 
 func (p *PolicyCanonical) GetHeadroom() (float64, error) {
 	return p.headroom, nil
 }
+
+// GetHeadroomWithConfidence always returns full confidence, since canonical
+// headroom is derived from requirements instead of metric samples
+func (p *PolicyCanonical) GetHeadroomWithConfidence() (float64, float64, error) {
+	return p.headroom, 1, nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package headroompolicy
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/metacache"
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+	"github.com/kubewharf/katalyst-core/pkg/metaserver/agent/metric"
+	"github.com/kubewharf/katalyst-core/pkg/metrics"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
+)
+
+func TestPolicyCanonical_GetHeadroomWithDisabledNUMAs(t *testing.T) {
+	tests := []struct {
+		name              string
+		regionInfo        *types.RegionInfo
+		numaEnableReclaim map[int]bool
+		want              float64
+	}{
+		{
+			name: "share region with reclaim enabled on all numas",
+			regionInfo: &types.RegionInfo{
+				RegionType:   types.QoSRegionTypeShare,
+				BindingNumas: machine.NewCPUSet(0, 1),
+				ControlKnobMap: types.ControlKnob{
+					types.ControlKnobNonReclaimedCPUSetSize: {Value: 40},
+				},
+			},
+			want: 50,
+		},
+		{
+			name: "share region with reclaim disabled on one numa",
+			regionInfo: &types.RegionInfo{
+				RegionType:   types.QoSRegionTypeShare,
+				BindingNumas: machine.NewCPUSet(0, 1),
+				ControlKnobMap: types.ControlKnob{
+					types.ControlKnobNonReclaimedCPUSetSize: {Value: 40},
+				},
+			},
+			numaEnableReclaim: map[int]bool{1: false},
+			want:              25,
+		},
+		{
+			name: "dedicated region with reclaim disabled on its numa",
+			regionInfo: &types.RegionInfo{
+				RegionType:   types.QoSRegionTypeDedicatedNumaExclusive,
+				BindingNumas: machine.NewCPUSet(1),
+				ControlKnobMap: types.ControlKnob{
+					types.ControlKnobReclaimedCPUSupplied: {Value: 10},
+				},
+			},
+			numaEnableReclaim: map[int]bool{1: false},
+			want:              0,
+		},
+		{
+			name: "dedicated region with reclaim disabled on other numa",
+			regionInfo: &types.RegionInfo{
+				RegionType:   types.QoSRegionTypeDedicatedNumaExclusive,
+				BindingNumas: machine.NewCPUSet(1),
+				ControlKnobMap: types.ControlKnob{
+					types.ControlKnobReclaimedCPUSupplied: {Value: 10},
+				},
+			},
+			numaEnableReclaim: map[int]bool{0: false},
+			want:              10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ckDir, err := ioutil.TempDir("", "checkpoint")
+			require.NoError(t, err)
+			defer os.RemoveAll(ckDir)
+
+			sfDir, err := ioutil.TempDir("", "statefile")
+			require.NoError(t, err)
+			defer os.RemoveAll(sfDir)
+
+			conf := generateTestConfiguration(t, ckDir, sfDir)
+			metricsFetcher := metric.NewFakeMetricsFetcher(metrics.DummyMetrics{})
+			metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metricsFetcher)
+			require.NoError(t, err)
+			require.NoError(t, metaCache.SetRegionInfo("region-0", tt.regionInfo))
+
+			metaServer := generateTestMetaServer(t, nil, nil, metricsFetcher)
+			p := NewPolicyCanonical("region-0", conf, nil, metaCache, metaServer, metrics.DummyMetrics{})
+			p.SetEssentials(types.ResourceEssentials{
+				EnableReclaim:     true,
+				NUMAEnableReclaim: tt.numaEnableReclaim,
+				Total:             96,
+				ReservePoolSize:   6,
+			})
+
+			require.NoError(t, p.Update())
+			got, err := p.GetHeadroom()
+			require.NoError(t, err)
+			require.InDelta(t, tt.want, got, 1e-6)
+		})
+	}
+}
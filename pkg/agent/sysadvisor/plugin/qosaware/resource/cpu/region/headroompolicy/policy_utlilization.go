@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/kubewharf/katalyst-api/pkg/consts"
 	"github.com/kubewharf/katalyst-core/pkg/agent/qrm-plugins/cpu/dynamicpolicy/state"
@@ -30,12 +31,47 @@ import (
 	"github.com/kubewharf/katalyst-core/pkg/metaserver"
 	"github.com/kubewharf/katalyst-core/pkg/metrics"
 	"github.com/kubewharf/katalyst-core/pkg/util/general"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
 	"github.com/kubewharf/katalyst-core/pkg/util/metric"
 )
 
+// HeadroomReason indicates which limit bounds the headroom estimation
+type HeadroomReason string
+
+const (
+	// HeadroomReasonTargetUtilization means headroom is decided by the target core utilization
+	HeadroomReasonTargetUtilization HeadroomReason = "TargetUtilization"
+	// HeadroomReasonMaxCoreUtilization means headroom is reduced since core utilization exceeds the maximum
+	HeadroomReasonMaxCoreUtilization HeadroomReason = "MaxCoreUtilization"
+	// HeadroomReasonOversoldRate means headroom is capped by the maximum oversold rate
+	HeadroomReasonOversoldRate HeadroomReason = "OversoldRate"
+	// HeadroomReasonCapacityRate means headroom is capped by the maximum rate of node capacity
+	HeadroomReasonCapacityRate HeadroomReason = "CapacityRate"
+	// HeadroomReasonMinHeadroom means headroom is raised to the floor of headroom
+	HeadroomReasonMinHeadroom HeadroomReason = "MinHeadroom"
+)
+
+// spdTargetCheckPeriod is the period to check target core utilization indicated by spd of pods on the node,
+// since it walks through spd of all pods, it's too expensive to do so in each update
+const spdTargetCheckPeriod = time.Minute
+
 type PolicyUtilization struct {
 	*PolicyBase
 
+	headroomByNUMA map[int]float64
+	headroomReason HeadroomReason
+	confidence     float64
+
+	// smoothedHeadroomByNUMA is the smoothed headroom of each numa node before the floor is applied,
+	// and it's used as the history of smoothing so that the floor doesn't leak into smoothing
+	smoothedHeadroomByNUMA map[int]float64
+
+	// spdTargetCoreUtilization caches the minimum target core utilization indicated by spd of pods,
+	// and it's refreshed each spdTargetCheckPeriod since spdTargetCheckTime
+	spdTargetCoreUtilization float64
+	spdTargetFound           bool
+	spdTargetCheckTime       time.Time
+
 	policyUtilizationConfiguration *headroom.PolicyUtilizationConfiguration
 }
 
@@ -60,8 +96,59 @@ func (p *PolicyUtilization) Update() error {
 		return fmt.Errorf("calculate reclaimed cpu core utilization failed: %v", err)
 	}
 
-	p.headroom = p.calculateHeadroom(float64(reclaimedPoolMetrics.poolSize), reclaimedPoolMetrics.coreAvgUtilization,
-		lastReclaimedCPU, float64(p.metaServer.MachineInfo.NumCores))
+	totalPoolSize, totalSampledSize := 0, 0
+	for _, numaMetrics := range reclaimedPoolMetrics {
+		totalPoolSize += numaMetrics.poolSize
+		totalSampledSize += numaMetrics.sampledSize
+	}
+
+	targetCoreUtilization := p.getTargetCoreUtilization()
+
+	// headroom of the whole node is calculated from reclaimed pool on numa nodes with reclaim enabled, and
+	// last reclaimed cpu and node capacity are shared in proportion to the reclaimed pool size of them
+	enabledCPUs := machine.NewCPUSet()
+	for numaID, numaMetrics := range reclaimedPoolMetrics {
+		if p.essentials.EnableReclaimOnNUMA(numaID) {
+			enabledCPUs = enabledCPUs.Union(numaMetrics.cpuSet)
+		}
+	}
+
+	nodeCPUCapacity := float64(p.metaServer.MachineInfo.NumCores)
+	nodeHeadroom := 0.
+	var headroomReason HeadroomReason
+	if !enabledCPUs.IsEmpty() {
+		ratio := float64(enabledCPUs.Size()) / float64(totalPoolSize)
+		coreAvgUtilization := p.metaServer.AggregateCoreMetric(enabledCPUs, pkgconsts.MetricCPUUsage, metric.AggregatorAvg) / 100.
+		nodeHeadroom, headroomReason = p.calculateHeadroom(float64(enabledCPUs.Size()), coreAvgUtilization,
+			targetCoreUtilization, lastReclaimedCPU*ratio, nodeCPUCapacity*ratio)
+	}
+
+	// then it's split among numa nodes with reclaim enabled, so is the floor of headroom, and
+	// the headroom of each numa node is smoothed before the floor is applied
+	minHeadroom := p.getMinHeadroom(nodeCPUCapacity)
+	headroomByNUMA := splitHeadroomByNUMA(nodeHeadroom, reclaimedPoolMetrics, p.essentials.EnableReclaimOnNUMA)
+	smoothedHeadroomByNUMA := make(map[int]float64)
+	headroom := 0.
+	for numaID, numaMetrics := range reclaimedPoolMetrics {
+		if !p.essentials.EnableReclaimOnNUMA(numaID) {
+			continue
+		}
+
+		ratio := float64(numaMetrics.poolSize) / float64(enabledCPUs.Size())
+		smoothedHeadroomByNUMA[numaID] = p.smoothHeadroom(numaID, headroomByNUMA[numaID])
+		var floored bool
+		headroomByNUMA[numaID], floored = p.applyMinHeadroom(numaID, smoothedHeadroomByNUMA[numaID], minHeadroom*ratio)
+		if floored {
+			headroomReason = HeadroomReasonMinHeadroom
+		}
+		headroom += headroomByNUMA[numaID]
+	}
+
+	p.headroom = headroom
+	p.headroomByNUMA = headroomByNUMA
+	p.smoothedHeadroomByNUMA = smoothedHeadroomByNUMA
+	p.headroomReason = headroomReason
+	p.confidence = calculateConfidence(totalSampledSize, totalPoolSize)
 	return nil
 }
 
@@ -69,6 +156,116 @@ func (p *PolicyUtilization) GetHeadroom() (float64, error) {
 	return p.headroom, nil
 }
 
+func (p *PolicyUtilization) GetHeadroomWithConfidence() (float64, float64, error) {
+	return p.headroom, p.confidence, nil
+}
+
+// GetHeadroomWithReason returns the latest headroom estimation with the limit that bounds it
+func (p *PolicyUtilization) GetHeadroomWithReason() (float64, HeadroomReason, error) {
+	return p.headroom, p.headroomReason, nil
+}
+
+// GetHeadroomByNUMA returns the latest headroom estimation of each numa node, which is split
+// from the whole node one by the reclaim pool assignment and core utilization on that numa node,
+// and the headroom of all numa nodes sums to the one returned by GetHeadroom
+func (p *PolicyUtilization) GetHeadroomByNUMA() (map[int]float64, error) {
+	if p.headroomByNUMA == nil {
+		return nil, fmt.Errorf("headroom by numa is not updated yet")
+	}
+
+	headroomByNUMA := make(map[int]float64, len(p.headroomByNUMA))
+	for numaID, headroom := range p.headroomByNUMA {
+		headroomByNUMA[numaID] = headroom
+	}
+	return headroomByNUMA, nil
+}
+
+// splitHeadroomByNUMA splits the headroom of the whole node among numa nodes with reclaim enabled in
+// proportion to the idle cpus of reclaimed pool on them, so that numa nodes under lower load get more
+// headroom while the headroom of all numa nodes still sums to the whole node one. it falls back to the
+// proportion of reclaimed pool size if there are no idle cpus at all, and numa nodes with reclaim
+// disabled get zero headroom.
+func splitHeadroomByNUMA(headroom float64, reclaimedPoolMetrics map[int]*poolMetrics,
+	enableReclaimOnNUMA func(numaID int) bool) map[int]float64 {
+	idleByNUMA := make(map[int]float64, len(reclaimedPoolMetrics))
+	totalIdle, totalPoolSize := 0., 0.
+	for numaID, numaMetrics := range reclaimedPoolMetrics {
+		if !enableReclaimOnNUMA(numaID) {
+			continue
+		}
+
+		idleByNUMA[numaID] = float64(numaMetrics.poolSize) * math.Max(1-numaMetrics.coreAvgUtilization, 0)
+		totalIdle += idleByNUMA[numaID]
+		totalPoolSize += float64(numaMetrics.poolSize)
+	}
+
+	headroomByNUMA := make(map[int]float64, len(reclaimedPoolMetrics))
+	for numaID, numaMetrics := range reclaimedPoolMetrics {
+		if !enableReclaimOnNUMA(numaID) {
+			headroomByNUMA[numaID] = 0
+		} else if totalIdle > 0 {
+			headroomByNUMA[numaID] = headroom * idleByNUMA[numaID] / totalIdle
+		} else {
+			headroomByNUMA[numaID] = headroom * float64(numaMetrics.poolSize) / totalPoolSize
+		}
+	}
+	return headroomByNUMA
+}
+
+// smoothHeadroom applies exponential smoothing to the headroom of numa node with its last
+// smoothed headroom before the floor is applied, so that transient usage spikes won't make
+// the reported headroom swing
+func (p *PolicyUtilization) smoothHeadroom(numaID int, headroom float64) float64 {
+	alpha := p.policyUtilizationConfiguration.ReclaimedCPUHeadroomSmoothingAlpha
+	if alpha <= 0 || alpha >= 1 {
+		return headroom
+	}
+
+	lastHeadroom, ok := p.smoothedHeadroomByNUMA[numaID]
+	if !ok {
+		return headroom
+	}
+
+	smoothed := alpha*headroom + (1-alpha)*lastHeadroom
+	general.Infof("numa %v headroom %.2f is smoothed to %.2f with last %.2f (alpha: %.2f)",
+		numaID, headroom, smoothed, lastHeadroom, alpha)
+	return smoothed
+}
+
+// getMinHeadroom returns the floor of headroom in cores, and the configured one is
+// regarded as the fraction of node capacity if it's less than one
+func (p *PolicyUtilization) getMinHeadroom(nodeCPUCapacity float64) float64 {
+	minHeadroom := p.policyUtilizationConfiguration.ReclaimedCPUMinHeadroom
+	if minHeadroom <= 0 {
+		return 0
+	} else if minHeadroom < 1 {
+		return nodeCPUCapacity * minHeadroom
+	}
+	return minHeadroom
+}
+
+// applyMinHeadroom clamps the headroom of numa node from below, regardless of the caps
+// by oversold rate or capacity rate, so that reclaim won't collapse on transient load.
+// floored is true if the headroom is raised to the floor
+func (p *PolicyUtilization) applyMinHeadroom(numaID int, headroom, minHeadroom float64) (float64, bool) {
+	if headroom >= minHeadroom {
+		return headroom, false
+	}
+
+	general.Infof("numa %v headroom %.2f is raised to the floor %.2f", numaID, headroom, minHeadroom)
+	return minHeadroom, true
+}
+
+// calculateConfidence returns the ratio of reclaimed pool cpus with utilization samples,
+// since the average utilization is aggregated by sampled cpus only, and it may not
+// reflect the whole pool if only a few cpus are sampled
+func calculateConfidence(sampledSize, poolSize int) float64 {
+	if poolSize <= 0 {
+		return 0
+	}
+	return float64(sampledSize) / float64(poolSize)
+}
+
 func (p *PolicyUtilization) getLastReclaimedCPU() (float64, error) {
 	cnr, err := p.metaServer.CNRFetcher.GetCNR(context.Background())
 	if err != nil {
@@ -84,45 +281,121 @@ func (p *PolicyUtilization) getLastReclaimedCPU() (float64, error) {
 	return 0, fmt.Errorf("cnr status resource allocatable reclaimed milli cpu not found")
 }
 
+// getTargetCoreUtilization returns the target core utilization of reclaimed pool, it will be
+// lowered by the target core utilization indicator in spd of pods on the node, and the most
+// restrictive one wins if several targets apply
+func (p *PolicyUtilization) getTargetCoreUtilization() float64 {
+	targetCoreUtilization := p.policyUtilizationConfiguration.ReclaimedCPUTargetCoreUtilization
+	if p.policyUtilizationConfiguration.ReclaimedCPUTargetCoreUtilizationIndicator == "" ||
+		p.metaServer.ServiceProfileManager == nil {
+		return targetCoreUtilization
+	}
+
+	if spdTarget, ok := p.getSPDTargetCoreUtilization(); ok && spdTarget < targetCoreUtilization {
+		return spdTarget
+	}
+	return targetCoreUtilization
+}
+
+// getSPDTargetCoreUtilization returns the minimum target core utilization indicated by spd of pods
+// on the node, and ok is false if no pod has it. the result is cached for spdTargetCheckPeriod
+func (p *PolicyUtilization) getSPDTargetCoreUtilization() (float64, bool) {
+	now := time.Now()
+	if now.Before(p.spdTargetCheckTime.Add(spdTargetCheckPeriod)) {
+		return p.spdTargetCoreUtilization, p.spdTargetFound
+	}
+
+	ctx := context.Background()
+	podList, err := p.metaServer.GetPodList(ctx, nil)
+	if err != nil {
+		general.Errorf("get pod list failed: %v", err)
+		return p.spdTargetCoreUtilization, p.spdTargetFound
+	}
+
+	indicatorName := p.policyUtilizationConfiguration.ReclaimedCPUTargetCoreUtilizationIndicator
+	spdTarget, found := 0., false
+	for _, pod := range podList {
+		value, ok, err := p.metaServer.GetSPDIndicator(ctx, pod, indicatorName)
+		if err != nil {
+			general.Warningf("get spd indicator %v of pod %v/%v failed: %v", indicatorName, pod.Namespace, pod.Name, err)
+			continue
+		} else if !ok {
+			continue
+		} else if value <= 0 || value > 1 {
+			general.Warningf("spd indicator %v of pod %v/%v is invalid: %v, ignore it", indicatorName, pod.Namespace, pod.Name, value)
+			continue
+		}
+
+		if !found || value < spdTarget {
+			general.Infof("pod %v/%v indicates target core utilization %.2f", pod.Namespace, pod.Name, value)
+			spdTarget, found = value, true
+		}
+	}
+
+	p.spdTargetCoreUtilization = spdTarget
+	p.spdTargetFound = found
+	p.spdTargetCheckTime = now
+	return spdTarget, found
+}
+
 type poolMetrics struct {
+	cpuSet             machine.CPUSet
 	coreAvgUtilization float64
 	poolSize           int
+	// sampledSize is the number of cpus in pool with utilization samples
+	sampledSize int
 }
 
-// getReclaimedPoolMetrics get reclaimed pool metrics, including the average utilization of each core in
-// the reclaimed pool and the size of the pool
-func (p *PolicyUtilization) getReclaimedPoolMetrics() (*poolMetrics, error) {
+// getReclaimedPoolMetrics get reclaimed pool metrics keyed by numa id, including the average
+// utilization of each core in the reclaimed pool and the size of the pool
+func (p *PolicyUtilization) getReclaimedPoolMetrics() (map[int]*poolMetrics, error) {
 	reclaimedInfo, ok := p.metaReader.GetPoolInfo(state.PoolNameReclaim)
 	if !ok {
 		return nil, fmt.Errorf("failed get reclaim pool info")
 	}
 
-	cpuSet := reclaimedInfo.TopologyAwareAssignments.MergeCPUSet()
-	coreAvgUtilization := p.metaServer.AggregateCoreMetric(cpuSet, pkgconsts.MetricCPUUsage, metric.AggregatorAvg)
-	return &poolMetrics{
-		coreAvgUtilization: coreAvgUtilization / 100.,
-		poolSize:           cpuSet.Size(),
-	}, nil
+	reclaimedPoolMetrics := make(map[int]*poolMetrics)
+	for numaID, cpuSet := range reclaimedInfo.TopologyAwareAssignments {
+		if cpuSet.IsEmpty() {
+			continue
+		}
+
+		sampledSize := 0
+		for _, cpu := range cpuSet.ToSliceNoSortInt() {
+			if _, err := p.metaServer.GetCPUMetric(cpu, pkgconsts.MetricCPUUsage); err == nil {
+				sampledSize++
+			}
+		}
+
+		coreAvgUtilization := p.metaServer.AggregateCoreMetric(cpuSet, pkgconsts.MetricCPUUsage, metric.AggregatorAvg)
+		reclaimedPoolMetrics[numaID] = &poolMetrics{
+			cpuSet:             cpuSet,
+			coreAvgUtilization: coreAvgUtilization / 100.,
+			poolSize:           cpuSet.Size(),
+			sampledSize:        sampledSize,
+		}
+	}
+	return reclaimedPoolMetrics, nil
 }
 
 // calculateHeadroom calculates headroom by taking into account the difference between the current
 // and target core utilization of the reclaim pool
-func (p *PolicyUtilization) calculateHeadroom(reclaimedSupplyCPU, reclaimedCPUCoreUtilization,
-	lastReclaimedCPU, nodeCPUCapacity float64) float64 {
+func (p *PolicyUtilization) calculateHeadroom(reclaimedSupplyCPU, reclaimedCPUCoreUtilization, targetCoreUtilization,
+	lastReclaimedCPU, nodeCPUCapacity float64) (float64, HeadroomReason) {
 	var (
 		oversold, result float64
+		reason           = HeadroomReasonTargetUtilization
 	)
 
-	targetCoreUtilization := p.policyUtilizationConfiguration.ReclaimedCPUTargetCoreUtilization
 	maxCoreUtilization := p.policyUtilizationConfiguration.ReclaimedCPUMaxCoreUtilization
 	maxOversoldRatio := p.policyUtilizationConfiguration.ReclaimedCPUMaxOversoldRate
 	maxHeadroomCapacityRate := p.policyUtilizationConfiguration.ReclaimedCPUMaxHeadroomCapacityRate
 
 	defer func() {
 		general.Infof("reclaimed supply %.2f, reclaimed core utilization: %.2f (target: %.2f, max: %.2f), "+
-			"last reclaimed: %.2f, oversold: %.2f, max oversold ratio: %.2f, final result: %.2f (max rate: %.2f, capacity: %.2f)",
+			"last reclaimed: %.2f, oversold: %.2f, max oversold ratio: %.2f, final result: %.2f (max rate: %.2f, capacity: %.2f, reason: %v)",
 			reclaimedSupplyCPU, reclaimedCPUCoreUtilization, targetCoreUtilization, maxCoreUtilization, lastReclaimedCPU,
-			oversold, maxOversoldRatio, result, maxHeadroomCapacityRate, nodeCPUCapacity)
+			oversold, maxOversoldRatio, result, maxHeadroomCapacityRate, nodeCPUCapacity, reason)
 	}()
 
 	// calculate the cpu resources that can be oversold to the reclaimed_cores workload, and consider that
@@ -133,13 +406,34 @@ func (p *PolicyUtilization) calculateHeadroom(reclaimedSupplyCPU, reclaimedCPUCo
 		oversold = reclaimedSupplyCPU * (targetCoreUtilization - reclaimedCPUCoreUtilization)
 	} else if maxCoreUtilization > 0 && reclaimedCPUCoreUtilization > maxCoreUtilization {
 		oversold = reclaimedSupplyCPU * (maxCoreUtilization - reclaimedCPUCoreUtilization)
+		reason = HeadroomReasonMaxCoreUtilization
 	}
 
 	result = math.Max(lastReclaimedCPU+oversold, reclaimedSupplyCPU)
-	result = math.Min(result, reclaimedSupplyCPU*maxOversoldRatio)
+	if reason == HeadroomReasonMaxCoreUtilization {
+		// the headroom is downsized progressively as core utilization rises above the maximum,
+		// and it reaches zero when reclaimed cores are fully utilized
+		result *= calculateDownsizeRatio(reclaimedCPUCoreUtilization, maxCoreUtilization)
+	}
+	if maxOversold := reclaimedSupplyCPU * maxOversoldRatio; result > maxOversold {
+		result = maxOversold
+		reason = HeadroomReasonOversoldRate
+	}
 	if maxHeadroomCapacityRate > 0 {
-		result = math.Min(result, nodeCPUCapacity*maxHeadroomCapacityRate)
+		if maxCapacity := nodeCPUCapacity * maxHeadroomCapacityRate; result > maxCapacity {
+			result = maxCapacity
+			reason = HeadroomReasonCapacityRate
+		}
 	}
 
-	return result
+	return result, reason
+}
+
+// calculateDownsizeRatio returns the ratio to downsize headroom by when core utilization exceeds the
+// maximum, which decreases linearly from 1 at the maximum utilization to 0 at full utilization
+func calculateDownsizeRatio(coreUtilization, maxCoreUtilization float64) float64 {
+	if maxCoreUtilization >= 1 {
+		return 1
+	}
+	return math.Max(math.Min((1-coreUtilization)/(1-maxCoreUtilization), 1), 0)
 }
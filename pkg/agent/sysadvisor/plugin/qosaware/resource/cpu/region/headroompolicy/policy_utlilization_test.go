@@ -17,7 +17,9 @@ limitations under the License.
 package headroompolicy
 
 import (
+	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"testing"
 
@@ -25,8 +27,10 @@ import (
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/kubewharf/katalyst-api/pkg/apis/node/v1alpha1"
+	workloadapis "github.com/kubewharf/katalyst-api/pkg/apis/workload/v1alpha1"
 	"github.com/kubewharf/katalyst-api/pkg/consts"
 	"github.com/kubewharf/katalyst-core/cmd/katalyst-agent/app/options"
 	"github.com/kubewharf/katalyst-core/pkg/agent/qrm-plugins/cpu/dynamicpolicy/state"
@@ -40,7 +44,9 @@ import (
 	metaservercnr "github.com/kubewharf/katalyst-core/pkg/metaserver/agent/cnr"
 	"github.com/kubewharf/katalyst-core/pkg/metaserver/agent/metric"
 	"github.com/kubewharf/katalyst-core/pkg/metaserver/agent/pod"
+	"github.com/kubewharf/katalyst-core/pkg/metaserver/spd"
 	"github.com/kubewharf/katalyst-core/pkg/metrics"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
 	"github.com/kubewharf/katalyst-core/pkg/util/machine"
 	utilmetric "github.com/kubewharf/katalyst-core/pkg/util/metric"
 )
@@ -84,16 +90,20 @@ func TestPolicyUtilization_GetHeadroom(t *testing.T) {
 		entries                 types.RegionEntries
 		cnr                     *v1alpha1.CustomNodeResource
 		podList                 []*v1.Pod
+		spds                    map[string]*workloadapis.ServiceProfileDescriptor
 		policyUtilizationConfig *headroom.PolicyUtilizationConfiguration
 		essentials              types.ResourceEssentials
 		setFakeMetric           func(store *utilmetric.MetricStore)
 		setMetaCache            func(cache *metacache.MetaCacheImp)
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		want    float64
-		wantErr bool
+		name       string
+		fields     fields
+		want       float64
+		wantReason HeadroomReason
+		// wantByNUMA is the expected headroom of each numa node, and it's not checked if nil
+		wantByNUMA map[int]float64
+		wantErr    bool
 	}{
 		{
 			name: "normal report",
@@ -136,7 +146,8 @@ func TestPolicyUtilization_GetHeadroom(t *testing.T) {
 					require.NoError(t, err)
 				},
 			},
-			want: 13,
+			want:       13,
+			wantReason: HeadroomReasonTargetUtilization,
 		},
 		{
 			name: "gap by oversold ratio",
@@ -179,7 +190,8 @@ func TestPolicyUtilization_GetHeadroom(t *testing.T) {
 					require.NoError(t, err)
 				},
 			},
-			want: 12,
+			want:       12,
+			wantReason: HeadroomReasonOversoldRate,
 		},
 		{
 			name: "over maximum core utilization",
@@ -222,7 +234,8 @@ func TestPolicyUtilization_GetHeadroom(t *testing.T) {
 					require.NoError(t, err)
 				},
 			},
-			want: 14,
+			want:       7,
+			wantReason: HeadroomReasonMaxCoreUtilization,
 		},
 		{
 			name: "limited by capacity",
@@ -266,7 +279,361 @@ func TestPolicyUtilization_GetHeadroom(t *testing.T) {
 					require.NoError(t, err)
 				},
 			},
-			want: 96,
+			want:       96,
+			wantReason: HeadroomReasonCapacityRate,
+		},
+		{
+			name: "reclaim disabled on numa",
+			fields: fields{
+				entries: map[string]*types.RegionInfo{
+					"share-0": {
+						RegionType: types.QoSRegionTypeShare,
+					},
+				},
+				cnr: &v1alpha1.CustomNodeResource{
+					Status: v1alpha1.CustomNodeResourceStatus{
+						Resources: v1alpha1.Resources{
+							Allocatable: &v1.ResourceList{
+								consts.ReclaimedResourceMilliCPU: resource.MustParse("10000"),
+							},
+						},
+					},
+				},
+				policyUtilizationConfig: &headroom.PolicyUtilizationConfiguration{
+					ReclaimedCPUTargetCoreUtilization: 0.6,
+					ReclaimedCPUMaxCoreUtilization:    0,
+					ReclaimedCPUMaxOversoldRate:       1.5,
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: true,
+					NUMAEnableReclaim: map[int]bool{
+						1: false,
+					},
+					Total: 96,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for i := 0; i < 96; i++ {
+						store.SetCPUMetric(i, pkgconsts.MetricCPUUsage, 30)
+					}
+				},
+				setMetaCache: func(cache *metacache.MetaCacheImp) {
+					err := cache.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+						PoolName: state.PoolNameReclaim,
+						TopologyAwareAssignments: map[int]machine.CPUSet{
+							0: machine.MustParse("0-9"),
+							1: machine.MustParse("24-33"),
+						},
+					})
+					require.NoError(t, err)
+				},
+			},
+			want:       10,
+			wantReason: HeadroomReasonTargetUtilization,
+		},
+		{
+			name: "reclaim only enabled on numa",
+			fields: fields{
+				entries: map[string]*types.RegionInfo{
+					"share-0": {
+						RegionType: types.QoSRegionTypeShare,
+					},
+				},
+				cnr: &v1alpha1.CustomNodeResource{
+					Status: v1alpha1.CustomNodeResourceStatus{
+						Resources: v1alpha1.Resources{
+							Allocatable: &v1.ResourceList{
+								consts.ReclaimedResourceMilliCPU: resource.MustParse("10000"),
+							},
+						},
+					},
+				},
+				policyUtilizationConfig: &headroom.PolicyUtilizationConfiguration{
+					ReclaimedCPUTargetCoreUtilization: 0.6,
+					ReclaimedCPUMaxCoreUtilization:    0,
+					ReclaimedCPUMaxOversoldRate:       1.5,
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: false,
+					NUMAEnableReclaim: map[int]bool{
+						1: true,
+					},
+					Total: 96,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for i := 0; i < 96; i++ {
+						store.SetCPUMetric(i, pkgconsts.MetricCPUUsage, 0)
+					}
+				},
+				setMetaCache: func(cache *metacache.MetaCacheImp) {
+					err := cache.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+						PoolName: state.PoolNameReclaim,
+						TopologyAwareAssignments: map[int]machine.CPUSet{
+							0: machine.MustParse("0-9"),
+							1: machine.MustParse("24-33"),
+						},
+					})
+					require.NoError(t, err)
+				},
+			},
+			want:       11,
+			wantReason: HeadroomReasonTargetUtilization,
+			wantByNUMA: map[int]float64{0: 0, 1: 11},
+		},
+		{
+			name: "different load on numa nodes",
+			fields: fields{
+				entries: map[string]*types.RegionInfo{
+					"share-0": {
+						RegionType: types.QoSRegionTypeShare,
+					},
+				},
+				cnr: &v1alpha1.CustomNodeResource{
+					Status: v1alpha1.CustomNodeResourceStatus{
+						Resources: v1alpha1.Resources{
+							Allocatable: &v1.ResourceList{
+								consts.ReclaimedResourceMilliCPU: resource.MustParse("10000"),
+							},
+						},
+					},
+				},
+				policyUtilizationConfig: &headroom.PolicyUtilizationConfiguration{
+					ReclaimedCPUTargetCoreUtilization: 0.6,
+					ReclaimedCPUMaxCoreUtilization:    0,
+					ReclaimedCPUMaxOversoldRate:       1.5,
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: true,
+					Total:         96,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for i := 0; i < 10; i++ {
+						store.SetCPUMetric(i, pkgconsts.MetricCPUUsage, 0)
+					}
+					for i := 24; i < 34; i++ {
+						store.SetCPUMetric(i, pkgconsts.MetricCPUUsage, 60)
+					}
+				},
+				setMetaCache: func(cache *metacache.MetaCacheImp) {
+					err := cache.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+						PoolName: state.PoolNameReclaim,
+						TopologyAwareAssignments: map[int]machine.CPUSet{
+							0: machine.MustParse("0-9"),
+							1: machine.MustParse("24-33"),
+						},
+					})
+					require.NoError(t, err)
+				},
+			},
+			// the whole node one is calculated with average utilization 0.3 of the 20 reclaimed cpus,
+			// and it's split by idle cpus of reclaimed pool, i.e. 10 on numa 0 and 4 on numa 1
+			want:       20,
+			wantReason: HeadroomReasonTargetUtilization,
+			wantByNUMA: map[int]float64{0: 20. * 10 / 14, 1: 20. * 4 / 14},
+		},
+		{
+			name: "target lowered by the minimum of spd indicators",
+			fields: fields{
+				entries: map[string]*types.RegionInfo{
+					"share-0": {
+						RegionType: types.QoSRegionTypeShare,
+					},
+				},
+				cnr: &v1alpha1.CustomNodeResource{
+					Status: v1alpha1.CustomNodeResourceStatus{
+						Resources: v1alpha1.Resources{
+							Allocatable: &v1.ResourceList{
+								consts.ReclaimedResourceMilliCPU: resource.MustParse("10000"),
+							},
+						},
+					},
+				},
+				podList: []*v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod1",
+							Namespace: "default",
+							Annotations: map[string]string{
+								consts.PodAnnotationSPDNameKey: "spd1",
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod2",
+							Namespace: "default",
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod3",
+							Namespace: "default",
+							Annotations: map[string]string{
+								consts.PodAnnotationSPDNameKey: "spd2",
+							},
+						},
+					},
+				},
+				spds: map[string]*workloadapis.ServiceProfileDescriptor{
+					"default/spd1": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "spd1",
+							Namespace: "default",
+						},
+						Spec: workloadapis.ServiceProfileDescriptorSpec{
+							SystemIndicator: []workloadapis.ServiceSystemIndicatorSpec{
+								{
+									Name: "reclaimed_cpu_target_core_utilization",
+									Indicators: []workloadapis.Indicator{
+										{
+											IndicatorLevel: workloadapis.IndicatorLevelUpperBound,
+											Value:          0.5,
+										},
+									},
+								},
+							},
+						},
+					},
+					"default/spd2": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "spd2",
+							Namespace: "default",
+						},
+						Spec: workloadapis.ServiceProfileDescriptorSpec{
+							SystemIndicator: []workloadapis.ServiceSystemIndicatorSpec{
+								{
+									Name: "reclaimed_cpu_target_core_utilization",
+									Indicators: []workloadapis.Indicator{
+										{
+											IndicatorLevel: workloadapis.IndicatorLevelUpperBound,
+											Value:          0.75,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				policyUtilizationConfig: &headroom.PolicyUtilizationConfiguration{
+					ReclaimedCPUTargetCoreUtilization:          0.6,
+					ReclaimedCPUMaxCoreUtilization:             0,
+					ReclaimedCPUMaxOversoldRate:                1.5,
+					ReclaimedCPUTargetCoreUtilizationIndicator: "reclaimed_cpu_target_core_utilization",
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: true,
+					Total:         96,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for i := 0; i < 10; i++ {
+						store.SetCPUMetric(i, pkgconsts.MetricCPUUsage, 30)
+					}
+				},
+				setMetaCache: func(cache *metacache.MetaCacheImp) {
+					err := cache.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+						PoolName: state.PoolNameReclaim,
+						TopologyAwareAssignments: map[int]machine.CPUSet{
+							0: machine.MustParse("0-9"),
+						},
+					})
+					require.NoError(t, err)
+				},
+			},
+			want:       12,
+			wantReason: HeadroomReasonTargetUtilization,
+		},
+		{
+			name: "spd without target indicator",
+			fields: fields{
+				entries: map[string]*types.RegionInfo{
+					"share-0": {
+						RegionType: types.QoSRegionTypeShare,
+					},
+				},
+				cnr: &v1alpha1.CustomNodeResource{
+					Status: v1alpha1.CustomNodeResourceStatus{
+						Resources: v1alpha1.Resources{
+							Allocatable: &v1.ResourceList{
+								consts.ReclaimedResourceMilliCPU: resource.MustParse("10000"),
+							},
+						},
+					},
+				},
+				podList: []*v1.Pod{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod1",
+							Namespace: "default",
+							Annotations: map[string]string{
+								consts.PodAnnotationSPDNameKey: "spd1",
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "pod2",
+							Namespace: "default",
+						},
+					},
+				},
+				spds: map[string]*workloadapis.ServiceProfileDescriptor{
+					"default/spd1": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "spd1",
+							Namespace: "default",
+						},
+						Spec: workloadapis.ServiceProfileDescriptorSpec{
+							BusinessIndicator: []workloadapis.ServiceBusinessIndicatorSpec{
+								{
+									Name: workloadapis.ServiceBusinessIndicatorNameRPCLatency,
+									Indicators: []workloadapis.Indicator{
+										{
+											IndicatorLevel: workloadapis.IndicatorLevelUpperBound,
+											Value:          10,
+										},
+									},
+								},
+							},
+							SystemIndicator: []workloadapis.ServiceSystemIndicatorSpec{
+								{
+									Name: "cpu_sched_wait",
+									Indicators: []workloadapis.Indicator{
+										{
+											IndicatorLevel: workloadapis.IndicatorLevelUpperBound,
+											Value:          10,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				policyUtilizationConfig: &headroom.PolicyUtilizationConfiguration{
+					ReclaimedCPUTargetCoreUtilization:          0.6,
+					ReclaimedCPUMaxCoreUtilization:             0,
+					ReclaimedCPUMaxOversoldRate:                1.5,
+					ReclaimedCPUTargetCoreUtilizationIndicator: "reclaimed_cpu_target_core_utilization",
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: true,
+					Total:         96,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for i := 0; i < 10; i++ {
+						store.SetCPUMetric(i, pkgconsts.MetricCPUUsage, 30)
+					}
+				},
+				setMetaCache: func(cache *metacache.MetaCacheImp) {
+					err := cache.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+						PoolName: state.PoolNameReclaim,
+						TopologyAwareAssignments: map[int]machine.CPUSet{
+							0: machine.MustParse("0-9"),
+						},
+					})
+					require.NoError(t, err)
+				},
+			},
+			want:       13,
+			wantReason: HeadroomReasonTargetUtilization,
 		},
 	}
 	for _, tt := range tests {
@@ -282,7 +649,7 @@ func TestPolicyUtilization_GetHeadroom(t *testing.T) {
 			conf := generateTestConfiguration(t, ckDir, sfDir)
 			conf.CPUHeadroomPolicyConfiguration.PolicyUtilization = tt.fields.policyUtilizationConfig
 			metricsFetcher := metric.NewFakeMetricsFetcher(metrics.DummyMetrics{})
-			metaCache, err := metacache.NewMetaCacheImp(conf, metricsFetcher)
+			metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metricsFetcher)
 			require.NoError(t, err)
 
 			err = metaCache.UpdateRegionEntries(tt.fields.entries)
@@ -290,6 +657,9 @@ func TestPolicyUtilization_GetHeadroom(t *testing.T) {
 			tt.fields.setMetaCache(metaCache)
 
 			metaServer := generateTestMetaServer(t, tt.fields.cnr, tt.fields.podList, metricsFetcher)
+			if tt.fields.spds != nil {
+				metaServer.ServiceProfileManager = &spd.ServiceProfileManagerStub{SPDs: tt.fields.spds}
+			}
 			p := NewPolicyUtilization("share-0", conf, nil, metaCache, metaServer, metrics.DummyMetrics{})
 
 			store := utilmetric.GetMetricStoreInstance()
@@ -307,6 +677,525 @@ func TestPolicyUtilization_GetHeadroom(t *testing.T) {
 			if got != tt.want {
 				t.Errorf("GetHeadroom() got = %v, want %v", got, tt.want)
 			}
+
+			gotWithReason, reason, err := p.(*PolicyUtilization).GetHeadroomWithReason()
+			require.NoError(t, err)
+			require.Equal(t, got, gotWithReason)
+			require.Equal(t, tt.wantReason, reason)
+
+			// headroom of all numa nodes sums to the whole node one
+			headroomByNUMA, err := p.(*PolicyUtilization).GetHeadroomByNUMA()
+			require.NoError(t, err)
+			sum := 0.
+			for _, headroom := range headroomByNUMA {
+				sum += headroom
+			}
+			require.InDelta(t, tt.want, sum, 1e-6)
+			if tt.wantByNUMA != nil {
+				require.Len(t, headroomByNUMA, len(tt.wantByNUMA))
+				for numaID, want := range tt.wantByNUMA {
+					require.InDelta(t, want, headroomByNUMA[numaID], 1e-6, "numa %v", numaID)
+				}
+			}
+		})
+	}
+}
+
+// sampledMetricsFetcher only has cpu metrics of sampled cpus
+type sampledMetricsFetcher struct {
+	metric.MetricsFetcher
+	sampled machine.CPUSet
+}
+
+func (f *sampledMetricsFetcher) GetCPUMetric(coreID int, metricName string) (float64, error) {
+	if !f.sampled.Contains(coreID) {
+		return 0, fmt.Errorf("cpu %v not sampled", coreID)
+	}
+	return f.MetricsFetcher.GetCPUMetric(coreID, metricName)
+}
+
+func (f *sampledMetricsFetcher) AggregateCoreMetric(cpuset machine.CPUSet, metricName string, agg utilmetric.Aggregator) float64 {
+	return f.MetricsFetcher.AggregateCoreMetric(cpuset.Intersection(f.sampled), metricName, agg)
+}
+
+func TestPolicyUtilization_GetHeadroomWithConfidence(t *testing.T) {
+	tests := []struct {
+		name           string
+		sampled        machine.CPUSet
+		wantConfidence float64
+	}{
+		{
+			name:           "all cpus sampled",
+			sampled:        machine.MustParse("0-9,24-33"),
+			wantConfidence: 1,
+		},
+		{
+			name:           "half cpus sampled",
+			sampled:        machine.MustParse("0-4,24-28"),
+			wantConfidence: 0.5,
+		},
+		{
+			name:           "cpus of one numa sampled",
+			sampled:        machine.MustParse("0-9"),
+			wantConfidence: 0.5,
+		},
+		{
+			name:           "few cpus sampled",
+			sampled:        machine.MustParse("0,24"),
+			wantConfidence: 0.1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ckDir, err := ioutil.TempDir("", "checkpoint")
+			require.NoError(t, err)
+			defer os.RemoveAll(ckDir)
+
+			sfDir, err := ioutil.TempDir("", "statefile")
+			require.NoError(t, err)
+			defer os.RemoveAll(sfDir)
+
+			conf := generateTestConfiguration(t, ckDir, sfDir)
+			conf.CPUHeadroomPolicyConfiguration.PolicyUtilization = &headroom.PolicyUtilizationConfiguration{
+				ReclaimedCPUTargetCoreUtilization: 0.6,
+				ReclaimedCPUMaxOversoldRate:       1.5,
+			}
+			metricsFetcher := &sampledMetricsFetcher{
+				MetricsFetcher: metric.NewFakeMetricsFetcher(metrics.DummyMetrics{}),
+				sampled:        tt.sampled,
+			}
+			metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metricsFetcher)
+			require.NoError(t, err)
+
+			err = metaCache.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+				PoolName: state.PoolNameReclaim,
+				TopologyAwareAssignments: map[int]machine.CPUSet{
+					0: machine.MustParse("0-9"),
+					1: machine.MustParse("24-33"),
+				},
+			})
+			require.NoError(t, err)
+
+			store := utilmetric.GetMetricStoreInstance()
+			for i := 0; i < 96; i++ {
+				store.SetCPUMetric(i, pkgconsts.MetricCPUUsage, 30)
+			}
+
+			cnr := &v1alpha1.CustomNodeResource{
+				Status: v1alpha1.CustomNodeResourceStatus{
+					Resources: v1alpha1.Resources{
+						Allocatable: &v1.ResourceList{
+							consts.ReclaimedResourceMilliCPU: resource.MustParse("10000"),
+						},
+					},
+				},
+			}
+			metaServer := generateTestMetaServer(t, cnr, nil, metricsFetcher)
+			p := NewPolicyUtilization("share-0", conf, nil, metaCache, metaServer, metrics.DummyMetrics{})
+			p.SetEssentials(types.ResourceEssentials{
+				EnableReclaim: true,
+				Total:         96,
+			})
+
+			require.NoError(t, p.Update())
+			gotHeadroom, confidence, err := p.GetHeadroomWithConfidence()
+			require.NoError(t, err)
+			require.InDelta(t, tt.wantConfidence, confidence, 1e-6)
+
+			wantHeadroom, err := p.GetHeadroom()
+			require.NoError(t, err)
+			require.Equal(t, wantHeadroom, gotHeadroom)
+		})
+	}
+}
+
+func TestPolicyUtilization_GetHeadroomOverMaxCoreUtilization(t *testing.T) {
+	ckDir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(ckDir)
+
+	sfDir, err := ioutil.TempDir("", "statefile")
+	require.NoError(t, err)
+	defer os.RemoveAll(sfDir)
+
+	conf := generateTestConfiguration(t, ckDir, sfDir)
+	conf.CPUHeadroomPolicyConfiguration.PolicyUtilization = &headroom.PolicyUtilizationConfiguration{
+		ReclaimedCPUTargetCoreUtilization: 0.6,
+		ReclaimedCPUMaxCoreUtilization:    0.8,
+		ReclaimedCPUMaxOversoldRate:       1.5,
+	}
+	metricsFetcher := metric.NewFakeMetricsFetcher(metrics.DummyMetrics{})
+	metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metricsFetcher)
+	require.NoError(t, err)
+
+	err = metaCache.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+		PoolName: state.PoolNameReclaim,
+		TopologyAwareAssignments: map[int]machine.CPUSet{
+			0: machine.MustParse("0-9"),
+		},
+	})
+	require.NoError(t, err)
+
+	cnr := &v1alpha1.CustomNodeResource{
+		Status: v1alpha1.CustomNodeResourceStatus{
+			Resources: v1alpha1.Resources{
+				Allocatable: &v1.ResourceList{
+					consts.ReclaimedResourceMilliCPU: resource.MustParse("15000"),
+				},
+			},
+		},
+	}
+	metaServer := generateTestMetaServer(t, cnr, nil, metricsFetcher)
+	p := NewPolicyUtilization("share-0", conf, nil, metaCache, metaServer, metrics.DummyMetrics{})
+	p.SetEssentials(types.ResourceEssentials{
+		EnableReclaim: true,
+		Total:         96,
+	})
+
+	// headroom decreases monotonically as utilization rises above the maximum, and reaches zero at 100%
+	usages := []float64{82, 90, 98, 100}
+	want := []float64{13.32, 7, 1.32, 0}
+	store := utilmetric.GetMetricStoreInstance()
+	last := math.MaxFloat64
+	for i, usage := range usages {
+		for cpu := 0; cpu < 10; cpu++ {
+			store.SetCPUMetric(cpu, pkgconsts.MetricCPUUsage, usage)
+		}
+
+		require.NoError(t, p.Update())
+		got, reason, err := p.(*PolicyUtilization).GetHeadroomWithReason()
+		require.NoError(t, err)
+		require.InDelta(t, want[i], got, 1e-6, "usage %v", usage)
+		require.Equal(t, HeadroomReasonMaxCoreUtilization, reason)
+		require.Less(t, got, last, "usage %v", usage)
+		last = got
+	}
+}
+
+func TestPolicyUtilization_GetTargetCoreUtilizationCached(t *testing.T) {
+	ckDir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(ckDir)
+
+	sfDir, err := ioutil.TempDir("", "statefile")
+	require.NoError(t, err)
+	defer os.RemoveAll(sfDir)
+
+	conf := generateTestConfiguration(t, ckDir, sfDir)
+	conf.CPUHeadroomPolicyConfiguration.PolicyUtilization = &headroom.PolicyUtilizationConfiguration{
+		ReclaimedCPUTargetCoreUtilization:          0.6,
+		ReclaimedCPUTargetCoreUtilizationIndicator: "reclaimed_cpu_target_core_utilization",
+	}
+	metricsFetcher := metric.NewFakeMetricsFetcher(metrics.DummyMetrics{})
+	metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metricsFetcher)
+	require.NoError(t, err)
+
+	podList := []*v1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod1",
+				Namespace: "default",
+				Annotations: map[string]string{
+					consts.PodAnnotationSPDNameKey: "spd1",
+				},
+			},
+		},
+	}
+	spdStub := &spd.ServiceProfileManagerStub{
+		SPDs: map[string]*workloadapis.ServiceProfileDescriptor{
+			"default/spd1": {
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "spd1",
+					Namespace: "default",
+				},
+				Spec: workloadapis.ServiceProfileDescriptorSpec{
+					SystemIndicator: []workloadapis.ServiceSystemIndicatorSpec{
+						{
+							Name: "reclaimed_cpu_target_core_utilization",
+							Indicators: []workloadapis.Indicator{
+								{
+									IndicatorLevel: workloadapis.IndicatorLevelUpperBound,
+									Value:          0.4,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	metaServer := generateTestMetaServer(t, nil, podList, metricsFetcher)
+	metaServer.ServiceProfileManager = spdStub
+	p := NewPolicyUtilization("share-0", conf, nil, metaCache, metaServer, metrics.DummyMetrics{}).(*PolicyUtilization)
+
+	require.InDelta(t, 0.4, p.getTargetCoreUtilization(), 1e-6)
+
+	// the result is cached within the check period even if the spd is gone
+	delete(spdStub.SPDs, "default/spd1")
+	require.InDelta(t, 0.4, p.getTargetCoreUtilization(), 1e-6)
+
+	// and it's refreshed once the check period expires
+	p.spdTargetCheckTime = p.spdTargetCheckTime.Add(-spdTargetCheckPeriod)
+	require.Equal(t, 0.6, p.getTargetCoreUtilization())
+}
+
+func TestPolicyUtilization_GetHeadroomWithSmoothing(t *testing.T) {
+	tests := []struct {
+		name  string
+		alpha float64
+		// usages is the cpu usage of reclaimed pool in each round, i.e. normal, spike and drop
+		usages []float64
+		want   []float64
+	}{
+		{
+			name:   "smoothing disabled",
+			alpha:  1,
+			usages: []float64{30, 90, 0},
+			want:   []float64{13, 10, 15},
+		},
+		{
+			name:   "smoothing enabled",
+			alpha:  0.5,
+			usages: []float64{30, 90, 0},
+			want:   []float64{13, 11.5, 13.25},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ckDir, err := ioutil.TempDir("", "checkpoint")
+			require.NoError(t, err)
+			defer os.RemoveAll(ckDir)
+
+			sfDir, err := ioutil.TempDir("", "statefile")
+			require.NoError(t, err)
+			defer os.RemoveAll(sfDir)
+
+			conf := generateTestConfiguration(t, ckDir, sfDir)
+			conf.CPUHeadroomPolicyConfiguration.PolicyUtilization = &headroom.PolicyUtilizationConfiguration{
+				ReclaimedCPUTargetCoreUtilization:  0.6,
+				ReclaimedCPUMaxOversoldRate:        1.5,
+				ReclaimedCPUHeadroomSmoothingAlpha: tt.alpha,
+			}
+			metricsFetcher := metric.NewFakeMetricsFetcher(metrics.DummyMetrics{})
+			metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metricsFetcher)
+			require.NoError(t, err)
+
+			err = metaCache.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+				PoolName: state.PoolNameReclaim,
+				TopologyAwareAssignments: map[int]machine.CPUSet{
+					0: machine.MustParse("0-9"),
+				},
+			})
+			require.NoError(t, err)
+
+			cnr := &v1alpha1.CustomNodeResource{
+				Status: v1alpha1.CustomNodeResourceStatus{
+					Resources: v1alpha1.Resources{
+						Allocatable: &v1.ResourceList{
+							consts.ReclaimedResourceMilliCPU: resource.MustParse("10000"),
+						},
+					},
+				},
+			}
+			metaServer := generateTestMetaServer(t, cnr, nil, metricsFetcher)
+			p := NewPolicyUtilization("share-0", conf, nil, metaCache, metaServer, metrics.DummyMetrics{})
+			p.SetEssentials(types.ResourceEssentials{
+				EnableReclaim: true,
+				Total:         96,
+			})
+
+			store := utilmetric.GetMetricStoreInstance()
+			for round, usage := range tt.usages {
+				for i := 0; i < 10; i++ {
+					store.SetCPUMetric(i, pkgconsts.MetricCPUUsage, usage)
+				}
+
+				require.NoError(t, p.Update())
+				got, err := p.GetHeadroom()
+				require.NoError(t, err)
+				require.InDelta(t, tt.want[round], got, 1e-6, "round %v", round)
+			}
 		})
 	}
 }
+
+func TestPolicyUtilization_GetHeadroomWithMinHeadroom(t *testing.T) {
+	tests := []struct {
+		name          string
+		minHeadroom   float64
+		enableReclaim bool
+		// numaEnableReclaim is only set to disable reclaim on numa 1, and the reclaimed pool
+		// spans both numa nodes if it's set
+		numaEnableReclaim map[int]bool
+		want              float64
+		wantReason        HeadroomReason
+	}{
+		{
+			name:          "floor disabled",
+			minHeadroom:   0,
+			enableReclaim: true,
+			want:          2,
+			wantReason:    HeadroomReasonOversoldRate,
+		},
+		{
+			name:          "floor of cores",
+			minHeadroom:   4,
+			enableReclaim: true,
+			want:          4,
+			wantReason:    HeadroomReasonMinHeadroom,
+		},
+		{
+			name:          "floor of capacity fraction",
+			minHeadroom:   0.05,
+			enableReclaim: true,
+			want:          4.8,
+			wantReason:    HeadroomReasonMinHeadroom,
+		},
+		{
+			name:          "floor lower than headroom",
+			minHeadroom:   1,
+			enableReclaim: true,
+			want:          2,
+			wantReason:    HeadroomReasonOversoldRate,
+		},
+		{
+			name:          "floor ignored if reclaim disabled",
+			minHeadroom:   4,
+			enableReclaim: false,
+			want:          0,
+		},
+		{
+			name:              "floor shared by numa nodes with reclaim enabled",
+			minHeadroom:       4,
+			enableReclaim:     true,
+			numaEnableReclaim: map[int]bool{1: false},
+			want:              4,
+			wantReason:        HeadroomReasonMinHeadroom,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ckDir, err := ioutil.TempDir("", "checkpoint")
+			require.NoError(t, err)
+			defer os.RemoveAll(ckDir)
+
+			sfDir, err := ioutil.TempDir("", "statefile")
+			require.NoError(t, err)
+			defer os.RemoveAll(sfDir)
+
+			// headroom is capped to 2 by the oversold rate
+			conf := generateTestConfiguration(t, ckDir, sfDir)
+			conf.CPUHeadroomPolicyConfiguration.PolicyUtilization = &headroom.PolicyUtilizationConfiguration{
+				ReclaimedCPUTargetCoreUtilization: 0.6,
+				ReclaimedCPUMaxOversoldRate:       0.2,
+				ReclaimedCPUMinHeadroom:           tt.minHeadroom,
+			}
+			metricsFetcher := metric.NewFakeMetricsFetcher(metrics.DummyMetrics{})
+			metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metricsFetcher)
+			require.NoError(t, err)
+
+			assignments := map[int]machine.CPUSet{
+				0: machine.MustParse("0-9"),
+			}
+			if tt.numaEnableReclaim != nil {
+				assignments[1] = machine.MustParse("24-33")
+			}
+			err = metaCache.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+				PoolName:                 state.PoolNameReclaim,
+				TopologyAwareAssignments: assignments,
+			})
+			require.NoError(t, err)
+
+			cnr := &v1alpha1.CustomNodeResource{
+				Status: v1alpha1.CustomNodeResourceStatus{
+					Resources: v1alpha1.Resources{
+						Allocatable: &v1.ResourceList{
+							consts.ReclaimedResourceMilliCPU: resource.MustParse("10000"),
+						},
+					},
+				},
+			}
+			metaServer := generateTestMetaServer(t, cnr, nil, metricsFetcher)
+			p := NewPolicyUtilization("share-0", conf, nil, metaCache, metaServer, metrics.DummyMetrics{})
+			p.SetEssentials(types.ResourceEssentials{
+				EnableReclaim:     tt.enableReclaim,
+				NUMAEnableReclaim: tt.numaEnableReclaim,
+				Total:             96,
+			})
+
+			store := utilmetric.GetMetricStoreInstance()
+			for i := 0; i < 10; i++ {
+				store.SetCPUMetric(i, pkgconsts.MetricCPUUsage, 30)
+			}
+
+			require.NoError(t, p.Update())
+			got, reason, err := p.GetHeadroomWithReason()
+			require.NoError(t, err)
+			require.InDelta(t, tt.want, got, 1e-6)
+			require.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestPolicyUtilization_GetHeadroomWithSmoothingAndMinHeadroom(t *testing.T) {
+	ckDir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(ckDir)
+
+	sfDir, err := ioutil.TempDir("", "statefile")
+	require.NoError(t, err)
+	defer os.RemoveAll(sfDir)
+
+	conf := generateTestConfiguration(t, ckDir, sfDir)
+	conf.CPUHeadroomPolicyConfiguration.PolicyUtilization = &headroom.PolicyUtilizationConfiguration{
+		ReclaimedCPUTargetCoreUtilization:  0.6,
+		ReclaimedCPUMaxCoreUtilization:     0.8,
+		ReclaimedCPUMaxOversoldRate:        1.5,
+		ReclaimedCPUHeadroomSmoothingAlpha: 0.5,
+		ReclaimedCPUMinHeadroom:            8,
+	}
+	metricsFetcher := metric.NewFakeMetricsFetcher(metrics.DummyMetrics{})
+	metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metricsFetcher)
+	require.NoError(t, err)
+
+	err = metaCache.SetPoolInfo(state.PoolNameReclaim, &types.PoolInfo{
+		PoolName: state.PoolNameReclaim,
+		TopologyAwareAssignments: map[int]machine.CPUSet{
+			0: machine.MustParse("0-9"),
+		},
+	})
+	require.NoError(t, err)
+
+	cnr := &v1alpha1.CustomNodeResource{
+		Status: v1alpha1.CustomNodeResourceStatus{
+			Resources: v1alpha1.Resources{
+				Allocatable: &v1.ResourceList{
+					consts.ReclaimedResourceMilliCPU: resource.MustParse("10000"),
+				},
+			},
+		},
+	}
+	metaServer := generateTestMetaServer(t, cnr, nil, metricsFetcher)
+	p := NewPolicyUtilization("share-0", conf, nil, metaCache, metaServer, metrics.DummyMetrics{})
+	p.SetEssentials(types.ResourceEssentials{
+		EnableReclaim: true,
+		Total:         96,
+	})
+
+	// the unsmoothed headroom is 13, 0, 0 and 13 in each round, and the smoothed one is 13, 6.5, 3.25
+	// and 8.125, which is raised to the floor if it's lower than 8. the floor is not taken as the history
+	// of smoothing, otherwise the last round would be smoothed to 10.5
+	usages := []float64{30, 100, 100, 30}
+	want := []float64{13, 8, 8, 8.125}
+	store := utilmetric.GetMetricStoreInstance()
+	for round, usage := range usages {
+		for i := 0; i < 10; i++ {
+			store.SetCPUMetric(i, pkgconsts.MetricCPUUsage, usage)
+		}
+
+		require.NoError(t, p.Update())
+		got, err := p.GetHeadroom()
+		require.NoError(t, err)
+		require.InDelta(t, want[round], got, 1e-6, "round %v", round)
+	}
+}
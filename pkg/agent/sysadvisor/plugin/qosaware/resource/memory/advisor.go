@@ -38,6 +38,7 @@ import (
 
 func init() {
 	headroompolicy.RegisterInitializer(types.MemoryHeadroomPolicyCanonical, headroompolicy.NewPolicyCanonical)
+	headroompolicy.RegisterInitializer(types.MemoryHeadroomPolicyUtilization, headroompolicy.NewPolicyUtilization)
 }
 
 const (
@@ -41,6 +41,7 @@ import (
 	"github.com/kubewharf/katalyst-core/pkg/metaserver/agent/metric"
 	"github.com/kubewharf/katalyst-core/pkg/metaserver/agent/pod"
 	"github.com/kubewharf/katalyst-core/pkg/metrics"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
 	"github.com/kubewharf/katalyst-core/pkg/util/machine"
 	utilmetric "github.com/kubewharf/katalyst-core/pkg/util/metric"
 )
@@ -427,7 +428,7 @@ func TestPolicyCanonical_calculateMemoryBuffer(t *testing.T) {
 			conf.MemoryPolicyCanonicalConfiguration = tt.fields.policyCanonicalConfiguration
 
 			metricsFetcher := metric.NewFakeMetricsFetcher(metrics.DummyMetrics{})
-			metaCache, err := metacache.NewMetaCacheImp(conf, metricsFetcher)
+			metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metricsFetcher)
 			require.NoError(t, err)
 
 			for _, c := range tt.fields.containers {
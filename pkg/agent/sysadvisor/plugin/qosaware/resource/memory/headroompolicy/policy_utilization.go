@@ -0,0 +1,201 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package headroompolicy
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/kubewharf/katalyst-api/pkg/consts"
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/metacache"
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+	"github.com/kubewharf/katalyst-core/pkg/config"
+	"github.com/kubewharf/katalyst-core/pkg/config/agent/sysadvisor/qosaware/resource/memory/headroom"
+	pkgconsts "github.com/kubewharf/katalyst-core/pkg/consts"
+	"github.com/kubewharf/katalyst-core/pkg/metaserver"
+	"github.com/kubewharf/katalyst-core/pkg/metrics"
+	"github.com/kubewharf/katalyst-core/pkg/util/general"
+)
+
+type PolicyUtilization struct {
+	*PolicyBase
+
+	// memoryHeadroom is valid to be used iff updateStatus successes
+	memoryHeadroom float64
+	updateStatus   types.PolicyUpdateStatus
+
+	policyUtilizationConfig *headroom.MemoryPolicyUtilizationConfiguration
+}
+
+func NewPolicyUtilization(conf *config.Configuration, _ interface{}, metaReader metacache.MetaReader,
+	metaServer *metaserver.MetaServer, _ metrics.MetricEmitter) HeadroomPolicy {
+	p := PolicyUtilization{
+		PolicyBase:              NewPolicyBase(metaReader, metaServer),
+		updateStatus:            types.PolicyUpdateFailed,
+		policyUtilizationConfig: conf.MemoryHeadroomPolicyConfiguration.MemoryPolicyUtilizationConfiguration,
+	}
+
+	return &p
+}
+
+func (p *PolicyUtilization) Update() (err error) {
+	defer func() {
+		if err != nil {
+			p.updateStatus = types.PolicyUpdateFailed
+		} else {
+			p.updateStatus = types.PolicyUpdateSucceeded
+		}
+	}()
+
+	if !p.essentials.EnableReclaim {
+		p.memoryHeadroom = 0
+		return nil
+	}
+
+	reclaimedMemory, found, err := p.getReclaimedMemoryAllocatable()
+	if err != nil {
+		return fmt.Errorf("get reclaimed memory allocatable failed: %v", err)
+	}
+
+	numaMetrics, err := p.getNUMAMemoryMetrics()
+	if err != nil {
+		return fmt.Errorf("get numa memory metrics failed: %v", err)
+	}
+
+	totalNUMAMemory := 0.
+	for _, m := range numaMetrics {
+		totalNUMAMemory += m.total
+	}
+	if totalNUMAMemory <= 0 {
+		return fmt.Errorf("invalid total numa memory: %.2e", totalNUMAMemory)
+	}
+
+	// headroom is calculated for each numa node separately, and allocatable memory is
+	// shared among numa nodes in proportion to their memory capacity
+	maxAllocatableMemory := math.Max(float64(p.essentials.Total-p.essentials.ReservedForAllocate), 0)
+	memoryHeadroom := 0.
+	for numaID, m := range numaMetrics {
+		ratio := m.total / totalNUMAMemory
+		numaHeadroom := p.calculateHeadroom(maxAllocatableMemory*ratio, m.used/m.total)
+		general.Infof("numa %v memory headroom: %.2e", numaID, numaHeadroom)
+		memoryHeadroom += numaHeadroom
+	}
+
+	maxHeadroomCapacityRate := p.policyUtilizationConfig.ReclaimedMemoryMaxHeadroomCapacityRate
+	if maxHeadroomCapacityRate > 0 {
+		memoryHeadroom = math.Min(memoryHeadroom, float64(p.metaServer.MemoryCapacity)*maxHeadroomCapacityRate)
+	}
+
+	// reclaimed memory allocatable in cnr is absent on a fresh node, and it's not capped then
+	if found {
+		memoryHeadroom = math.Min(memoryHeadroom, reclaimedMemory)
+	}
+
+	p.memoryHeadroom = memoryHeadroom
+	general.Infof("final memory headroom: %.2e, max allocatable: %.2e, reclaimed allocatable: %.2e (found: %v), "+
+		"max capacity rate: %.2f", memoryHeadroom, maxAllocatableMemory, reclaimedMemory, found, maxHeadroomCapacityRate)
+	return nil
+}
+
+func (p *PolicyUtilization) GetHeadroom() (resource.Quantity, error) {
+	if p.updateStatus != types.PolicyUpdateSucceeded {
+		return resource.Quantity{}, fmt.Errorf("last update failed")
+	}
+
+	return *resource.NewQuantity(int64(p.memoryHeadroom), resource.BinarySI), nil
+}
+
+// getReclaimedMemoryAllocatable returns the reclaimed memory allocatable in cnr, and found
+// is false if it's not reported yet, e.g. on a fresh node
+func (p *PolicyUtilization) getReclaimedMemoryAllocatable() (reclaimedMemory float64, found bool, err error) {
+	cnr, err := p.metaServer.CNRFetcher.GetCNR(context.Background())
+	if err != nil {
+		return 0, false, err
+	}
+
+	if cnr.Status.Resources.Allocatable != nil {
+		if reclaimedMemory, ok := (*cnr.Status.Resources.Allocatable)[consts.ReclaimedResourceMemory]; ok {
+			return float64(reclaimedMemory.Value()), true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+type numaMemoryMetrics struct {
+	total float64
+	used  float64
+}
+
+// getNUMAMemoryMetrics get memory total and used of each numa node keyed by numa id
+func (p *PolicyUtilization) getNUMAMemoryMetrics() (map[int]*numaMemoryMetrics, error) {
+	numaMetrics := make(map[int]*numaMemoryMetrics)
+	for _, numaID := range p.metaServer.CPUDetails.NUMANodes().ToSliceInt() {
+		total, err := p.metaServer.GetNumaMetric(numaID, pkgconsts.MetricMemTotalNuma)
+		if err != nil {
+			return nil, err
+		} else if total <= 0 {
+			return nil, fmt.Errorf("invalid memory total %.2e of numa %v", total, numaID)
+		}
+
+		used, err := p.metaServer.GetNumaMetric(numaID, pkgconsts.MetricMemUsedNuma)
+		if err != nil {
+			return nil, err
+		}
+
+		numaMetrics[numaID] = &numaMemoryMetrics{
+			total: total,
+			used:  used,
+		}
+	}
+	return numaMetrics, nil
+}
+
+// calculateHeadroom calculates headroom by taking into account the difference between the current
+// and target memory utilization of the numa node
+func (p *PolicyUtilization) calculateHeadroom(allocatableMemory, memoryUtilization float64) float64 {
+	var (
+		oversold, result float64
+	)
+
+	targetUtilization := p.policyUtilizationConfig.ReclaimedMemoryTargetUtilization
+	maxUtilization := p.policyUtilizationConfig.ReclaimedMemoryMaxUtilization
+	maxOversoldRatio := p.policyUtilizationConfig.ReclaimedMemoryMaxOversoldRate
+
+	defer func() {
+		general.Infof("allocatable %.2e, memory utilization: %.2f (target: %.2f, max: %.2f), "+
+			"oversold: %.2e, max oversold ratio: %.2f, result: %.2e",
+			allocatableMemory, memoryUtilization, targetUtilization, maxUtilization,
+			oversold, maxOversoldRatio, result)
+	}()
+
+	// the memory that can be oversold to the reclaimed_cores workload is proportional to the gap
+	// between target and current utilization, and it's negative if the maximum utilization is
+	// set and exceeded, in which case no memory is reported reclaimed
+	if targetUtilization > memoryUtilization {
+		oversold = allocatableMemory * (targetUtilization - memoryUtilization)
+	} else if maxUtilization > 0 && memoryUtilization > maxUtilization {
+		oversold = allocatableMemory * (maxUtilization - memoryUtilization)
+	}
+
+	result = math.Max(oversold, 0)
+	result = math.Min(result, allocatableMemory*maxOversoldRatio)
+	return result
+}
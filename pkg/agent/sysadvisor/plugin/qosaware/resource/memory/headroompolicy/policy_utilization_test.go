@@ -0,0 +1,252 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package headroompolicy
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/kubewharf/katalyst-api/pkg/apis/node/v1alpha1"
+	"github.com/kubewharf/katalyst-api/pkg/consts"
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/metacache"
+	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
+	"github.com/kubewharf/katalyst-core/pkg/config/agent/sysadvisor/qosaware/resource/memory/headroom"
+	pkgconsts "github.com/kubewharf/katalyst-core/pkg/consts"
+	metaservercnr "github.com/kubewharf/katalyst-core/pkg/metaserver/agent/cnr"
+	"github.com/kubewharf/katalyst-core/pkg/metaserver/agent/metric"
+	"github.com/kubewharf/katalyst-core/pkg/metrics"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
+	utilmetric "github.com/kubewharf/katalyst-core/pkg/util/metric"
+)
+
+func generateTestReclaimedMemoryCNR(reclaimedMemory string) *v1alpha1.CustomNodeResource {
+	return &v1alpha1.CustomNodeResource{
+		Status: v1alpha1.CustomNodeResourceStatus{
+			Resources: v1alpha1.Resources{
+				Allocatable: &v1.ResourceList{
+					consts.ReclaimedResourceMemory: resource.MustParse(reclaimedMemory),
+				},
+			},
+		},
+	}
+}
+
+func TestPolicyUtilization_GetHeadroom(t *testing.T) {
+	type fields struct {
+		cnr                     *v1alpha1.CustomNodeResource
+		policyUtilizationConfig *headroom.MemoryPolicyUtilizationConfiguration
+		essentials              types.ResourceEssentials
+		setFakeMetric           func(store *utilmetric.MetricStore)
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		want    resource.Quantity
+		wantErr bool
+	}{
+		{
+			name: "normal report",
+			fields: fields{
+				cnr: generateTestReclaimedMemoryCNR("100Gi"),
+				policyUtilizationConfig: &headroom.MemoryPolicyUtilizationConfiguration{
+					ReclaimedMemoryTargetUtilization: 0.75,
+					ReclaimedMemoryMaxOversoldRate:   1.5,
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: true,
+					Total:         100 << 30,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for numaID := 0; numaID < 2; numaID++ {
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemTotalNuma, 50<<30)
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemUsedNuma, 25<<30)
+					}
+				},
+			},
+			// each numa: 50Gi * (0.75 - 0.5) oversold
+			want: *resource.NewQuantity(25<<30, resource.BinarySI),
+		},
+		{
+			name: "capped by oversold ratio",
+			fields: fields{
+				cnr: generateTestReclaimedMemoryCNR("100Gi"),
+				policyUtilizationConfig: &headroom.MemoryPolicyUtilizationConfiguration{
+					ReclaimedMemoryTargetUtilization: 0.75,
+					ReclaimedMemoryMaxOversoldRate:   0.1,
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: true,
+					Total:         100 << 30,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for numaID := 0; numaID < 2; numaID++ {
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemTotalNuma, 50<<30)
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemUsedNuma, 25<<30)
+					}
+				},
+			},
+			want: *resource.NewQuantity(10<<30, resource.BinarySI),
+		},
+		{
+			name: "capped by capacity",
+			fields: fields{
+				cnr: generateTestReclaimedMemoryCNR("100Gi"),
+				policyUtilizationConfig: &headroom.MemoryPolicyUtilizationConfiguration{
+					ReclaimedMemoryTargetUtilization:       0.75,
+					ReclaimedMemoryMaxOversoldRate:         1.5,
+					ReclaimedMemoryMaxHeadroomCapacityRate: 0.03125,
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: true,
+					Total:         100 << 30,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for numaID := 0; numaID < 2; numaID++ {
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemTotalNuma, 50<<30)
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemUsedNuma, 25<<30)
+					}
+				},
+			},
+			// machine memory capacity is 500Gi
+			want: *resource.NewQuantity(500<<30/32, resource.BinarySI),
+		},
+		{
+			name: "capped by CNR reclaimed allocatable",
+			fields: fields{
+				cnr: generateTestReclaimedMemoryCNR("10Gi"),
+				policyUtilizationConfig: &headroom.MemoryPolicyUtilizationConfiguration{
+					ReclaimedMemoryTargetUtilization: 0.75,
+					ReclaimedMemoryMaxOversoldRate:   1.5,
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: true,
+					Total:         100 << 30,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for numaID := 0; numaID < 2; numaID++ {
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemTotalNuma, 50<<30)
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemUsedNuma, 25<<30)
+					}
+				},
+			},
+			want: *resource.NewQuantity(10<<30, resource.BinarySI),
+		},
+		{
+			name: "CNR without reclaimed allocatable",
+			fields: fields{
+				cnr: &v1alpha1.CustomNodeResource{},
+				policyUtilizationConfig: &headroom.MemoryPolicyUtilizationConfiguration{
+					ReclaimedMemoryTargetUtilization: 0.75,
+					ReclaimedMemoryMaxOversoldRate:   1.5,
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: true,
+					Total:         100 << 30,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for numaID := 0; numaID < 2; numaID++ {
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemTotalNuma, 50<<30)
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemUsedNuma, 25<<30)
+					}
+				},
+			},
+			want: *resource.NewQuantity(25<<30, resource.BinarySI),
+		},
+		{
+			name: "over maximum utilization",
+			fields: fields{
+				cnr: generateTestReclaimedMemoryCNR("100Gi"),
+				policyUtilizationConfig: &headroom.MemoryPolicyUtilizationConfiguration{
+					ReclaimedMemoryTargetUtilization: 0.5,
+					ReclaimedMemoryMaxUtilization:    0.75,
+					ReclaimedMemoryMaxOversoldRate:   1.5,
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: true,
+					Total:         100 << 30,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {
+					for numaID := 0; numaID < 2; numaID++ {
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemTotalNuma, 50<<30)
+						store.SetNumaMetric(numaID, pkgconsts.MetricMemUsedNuma, 50<<30)
+					}
+				},
+			},
+			want: *resource.NewQuantity(0, resource.BinarySI),
+		},
+		{
+			name: "reclaim disabled",
+			fields: fields{
+				cnr: generateTestReclaimedMemoryCNR("100Gi"),
+				policyUtilizationConfig: &headroom.MemoryPolicyUtilizationConfiguration{
+					ReclaimedMemoryTargetUtilization: 0.75,
+					ReclaimedMemoryMaxOversoldRate:   1.5,
+				},
+				essentials: types.ResourceEssentials{
+					EnableReclaim: false,
+					Total:         100 << 30,
+				},
+				setFakeMetric: func(store *utilmetric.MetricStore) {},
+			},
+			want: *resource.NewQuantity(0, resource.BinarySI),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ckDir, err := ioutil.TempDir("", "checkpoint")
+			require.NoError(t, err)
+			defer os.RemoveAll(ckDir)
+
+			sfDir, err := ioutil.TempDir("", "statefile")
+			require.NoError(t, err)
+			defer os.RemoveAll(sfDir)
+
+			conf := generateTestConfiguration(t, ckDir, sfDir)
+			conf.MemoryPolicyUtilizationConfiguration = tt.fields.policyUtilizationConfig
+
+			metricsFetcher := metric.NewFakeMetricsFetcher(metrics.DummyMetrics{})
+			metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, metricsFetcher)
+			require.NoError(t, err)
+
+			metaServer := generateTestMetaServer(t, nil, metricsFetcher)
+			metaServer.CNRFetcher = &metaservercnr.CNRFetcherStub{CNR: tt.fields.cnr}
+
+			p := NewPolicyUtilization(conf, nil, metaCache, metaServer, metrics.DummyMetrics{})
+
+			store := utilmetric.GetMetricStoreInstance()
+			tt.fields.setFakeMetric(store)
+
+			p.SetEssentials(tt.fields.essentials)
+
+			err = p.Update()
+			require.NoError(t, err)
+			got, err := p.GetHeadroom()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetHeadroom() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.Cmp(tt.want) != 0 {
+				t.Errorf("GetHeadroom() got = %v, want %v", got.String(), tt.want.String())
+			}
+		})
+	}
+}
@@ -39,6 +39,7 @@ import (
 	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
 	"github.com/kubewharf/katalyst-core/pkg/config"
 	"github.com/kubewharf/katalyst-core/pkg/metrics"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
 )
 
 func generateTestConfiguration(t *testing.T) *config.Configuration {
@@ -65,7 +66,7 @@ func newTestCPUServer(t *testing.T) *cpuServer {
 	sendCh := make(chan struct{})
 	conf := generateTestConfiguration(t)
 
-	metaCache, err := metacache.NewMetaCacheImp(conf, nil)
+	metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
 	require.NoError(t, err)
 	require.NotNil(t, metaCache)
 
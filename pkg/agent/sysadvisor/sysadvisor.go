@@ -52,6 +52,7 @@ type AdvisorAgent struct {
 	extraConf  interface{}
 	metaServer *metaserver.MetaServer
 	emitPool   metricspool.MetricsEmitterPool
+	metaCache  *metacache.MetaCacheImp
 
 	plugins      []pkgplugin.SysAdvisorPlugin
 	pluginsToRun []pkgplugin.SysAdvisorPlugin
@@ -82,10 +83,14 @@ func NewAdvisorAgent(conf *config.Configuration, extraConf interface{}, metaServ
 }
 
 func (m *AdvisorAgent) getAdvisorPlugins(SysAdvisorPluginInitializers map[string]pkgplugin.AdvisorPluginInitFunc) error {
-	metaCache, err := metacache.NewMetaCacheImp(m.config, m.metaServer.MetricsFetcher)
+	metaCache, err := metacache.NewMetaCacheImp(m.config, m.emitPool, m.metaServer.MetricsFetcher)
 	if err != nil {
 		return fmt.Errorf("new metacache failed: %v", err)
 	}
+	if err := metaCache.ValidateState(m.metaServer.CPUTopology); err != nil {
+		return fmt.Errorf("validate metacache state failed: %v", err)
+	}
+	m.metaCache = metaCache
 
 	for pluginName, initFn := range SysAdvisorPluginInitializers {
 		if !general.IsNameEnabled(pluginName, sets.NewString(), m.config.GenericSysAdvisorConfiguration.SysAdvisorPlugins) {
@@ -147,6 +152,13 @@ func (m *AdvisorAgent) init() {
 // Run starts sysadvisor agent
 func (m *AdvisorAgent) Run(ctx context.Context) {
 	wg := sync.WaitGroup{}
+	// metacache flushes its state on stop, so wait for it before exiting
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.metaCache.Run(ctx)
+	}()
+
 	// sysadvisor plugin can both run synchronously or asynchronously
 	for _, plugin := range m.pluginsToRun {
 		wg.Add(1)
@@ -17,6 +17,7 @@ limitations under the License.
 package util
 
 import (
+	"fmt"
 	"io/ioutil"
 	"testing"
 
@@ -24,10 +25,14 @@ import (
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/util/sets"
 
+	"github.com/kubewharf/katalyst-api/pkg/consts"
+
 	"github.com/kubewharf/katalyst-core/cmd/katalyst-agent/app/options"
 	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/metacache"
 	"github.com/kubewharf/katalyst-core/pkg/agent/sysadvisor/types"
 	"github.com/kubewharf/katalyst-core/pkg/config"
+	metricspool "github.com/kubewharf/katalyst-core/pkg/metrics/metrics-pool"
+	"github.com/kubewharf/katalyst-core/pkg/util/machine"
 )
 
 func generateMachineConfig(t *testing.T) *config.Configuration {
@@ -43,7 +48,7 @@ func generateMachineConfig(t *testing.T) *config.Configuration {
 }
 
 func newTestMetaCache(t *testing.T) *metacache.MetaCacheImp {
-	metaCache, err := metacache.NewMetaCacheImp(generateMachineConfig(t), nil)
+	metaCache, err := metacache.NewMetaCacheImp(generateMachineConfig(t), metricspool.DummyMetricsEmitterPool{}, nil)
 	require.NoError(t, err)
 	require.NotNil(t, metaCache)
 	return metaCache
@@ -102,3 +107,303 @@ func TestPool(t *testing.T) {
 	_, ok = metaCache.GetPoolInfo("pool-2")
 	assert.True(t, ok)
 }
+
+func TestGetReclaimableCPUs(t *testing.T) {
+	metaCache := newTestMetaCache(t)
+
+	_, _, err := metaCache.GetReclaimableCPUs()
+	assert.Error(t, err)
+
+	err = metaCache.SetPoolInfo("share", &types.PoolInfo{
+		TopologyAwareAssignments: map[int]machine.CPUSet{
+			0: machine.MustParse("0-9"),
+		},
+	})
+	assert.Nil(t, err)
+	err = metaCache.SetPoolInfo("reclaim", &types.PoolInfo{
+		TopologyAwareAssignments: map[int]machine.CPUSet{
+			0: machine.MustParse("10-13"),
+			1: machine.MustParse("24-25"),
+		},
+	})
+	assert.Nil(t, err)
+	err = metaCache.SetPoolInfo("reclaim-numa1", &types.PoolInfo{
+		TopologyAwareAssignments: map[int]machine.CPUSet{
+			1: machine.MustParse("26-28"),
+		},
+	})
+	assert.Nil(t, err)
+
+	total, numaCPUs, err := metaCache.GetReclaimableCPUs()
+	assert.NoError(t, err)
+	assert.Equal(t, 9, total)
+	assert.Equal(t, map[int]int{0: 4, 1: 5}, numaCPUs)
+}
+
+func TestTransaction(t *testing.T) {
+	conf := generateMachineConfig(t)
+	metaCache, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+
+	err = metaCache.SetContainerInfo("pod-0", "container-0", &types.ContainerInfo{})
+	assert.Nil(t, err)
+
+	err = metaCache.Transaction(func(writer metacache.MetaWriter) error {
+		for i := 1; i <= 3; i++ {
+			if err := writer.SetContainerInfo(fmt.Sprintf("pod-%d", i), "container-0", &types.ContainerInfo{}); err != nil {
+				return err
+			}
+		}
+		if err := writer.RemovePod("pod-0"); err != nil {
+			return err
+		}
+		if err := writer.UpdateRegionEntries(types.RegionEntries{"region-0": &types.RegionInfo{}}); err != nil {
+			return err
+		}
+		return writer.SetPoolInfo("pool-0", &types.PoolInfo{})
+	})
+	assert.Nil(t, err)
+
+	_, ok := metaCache.GetContainerInfo("pod-0", "container-0")
+	assert.False(t, ok)
+	_, ok = metaCache.GetContainerInfo("pod-3", "container-0")
+	assert.True(t, ok)
+	_, ok = metaCache.GetPoolInfo("pool-0")
+	assert.True(t, ok)
+
+	// mutations are rolled back if the transaction fails
+	err = metaCache.Transaction(func(writer metacache.MetaWriter) error {
+		if err := writer.SetContainerInfo("pod-4", "container-0", &types.ContainerInfo{}); err != nil {
+			return err
+		}
+		if err := writer.AddContainer("pod-1", "container-0", &types.ContainerInfo{CPURequest: 1}); err != nil {
+			return err
+		}
+		if err := writer.DeletePool("pool-0"); err != nil {
+			return err
+		}
+		if err := writer.UpdateRegionEntries(types.RegionEntries{"region-1": &types.RegionInfo{}}); err != nil {
+			return err
+		}
+		return fmt.Errorf("mock error")
+	})
+	assert.NotNil(t, err)
+
+	_, ok = metaCache.GetContainerInfo("pod-4", "container-0")
+	assert.False(t, ok)
+	containerInfo, ok := metaCache.GetContainerInfo("pod-1", "container-0")
+	assert.True(t, ok)
+	assert.Equal(t, float64(0), containerInfo.CPURequest)
+	_, ok = metaCache.GetPoolInfo("pool-0")
+	assert.True(t, ok)
+	_, ok = metaCache.GetRegionInfo("region-0")
+	assert.True(t, ok)
+	_, ok = metaCache.GetRegionInfo("region-1")
+	assert.False(t, ok)
+
+	// the committed transaction is persisted into checkpoint
+	restored, err := metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	_, ok = restored.GetContainerInfo("pod-0", "container-0")
+	assert.False(t, ok)
+	for i := 1; i <= 3; i++ {
+		_, ok = restored.GetContainerInfo(fmt.Sprintf("pod-%d", i), "container-0")
+		assert.True(t, ok)
+	}
+	_, ok = restored.GetPoolInfo("pool-0")
+	assert.True(t, ok)
+	_, ok = restored.GetRegionInfo("region-0")
+	assert.True(t, ok)
+
+	// region entries updated out of transaction are persisted as well
+	err = metaCache.UpdateRegionEntries(types.RegionEntries{"region-2": &types.RegionInfo{}})
+	assert.Nil(t, err)
+	restored, err = metacache.NewMetaCacheImp(conf, metricspool.DummyMetricsEmitterPool{}, nil)
+	require.NoError(t, err)
+	_, ok = restored.GetRegionInfo("region-0")
+	assert.False(t, ok)
+	_, ok = restored.GetRegionInfo("region-2")
+	assert.True(t, ok)
+}
+
+func TestObserver(t *testing.T) {
+	metaCache := newTestMetaCache(t)
+
+	var containerEvents []metacache.ContainerEvent
+	metaCache.RegisterContainerObserver(func(event metacache.ContainerEvent) {
+		// calling back into the reader api should not deadlock
+		_, _ = metaCache.GetContainerInfo(event.PodUID, event.ContainerName)
+		// mutating the event should not affect metacache
+		if event.ContainerInfo != nil {
+			event.ContainerInfo.CPURequest = -1
+		}
+		containerEvents = append(containerEvents, event)
+	})
+	var poolEvents []metacache.PoolEvent
+	metaCache.RegisterPoolObserver(func(event metacache.PoolEvent) {
+		poolEvents = append(poolEvents, event)
+	})
+
+	err := metaCache.SetContainerInfo("pod-0", "container-0", &types.ContainerInfo{CPURequest: 1})
+	assert.Nil(t, err)
+	err = metaCache.SetContainerInfo("pod-0", "container-0", &types.ContainerInfo{CPURequest: 2})
+	assert.Nil(t, err)
+	// unchanged container info fires no event
+	err = metaCache.SetContainerInfo("pod-0", "container-0", &types.ContainerInfo{CPURequest: 2})
+	assert.Nil(t, err)
+	err = metaCache.SetContainerInfo("pod-0", "container-1", &types.ContainerInfo{CPURequest: 3})
+	assert.Nil(t, err)
+	err = metaCache.DeleteContainer("pod-0", "container-1")
+	assert.Nil(t, err)
+	err = metaCache.RemovePod("pod-0")
+	assert.Nil(t, err)
+
+	kinds := make([]metacache.EventKind, 0, len(containerEvents))
+	for _, event := range containerEvents {
+		assert.Equal(t, "pod-0", event.PodUID)
+		kinds = append(kinds, event.Kind)
+	}
+	assert.Equal(t, []metacache.EventKind{metacache.EventKindAdded, metacache.EventKindUpdated,
+		metacache.EventKindAdded, metacache.EventKindDeleted, metacache.EventKindDeleted}, kinds)
+	assert.Equal(t, "container-0", containerEvents[4].ContainerName)
+
+	err = metaCache.SetContainerInfo("pod-1", "container-0", &types.ContainerInfo{CPURequest: 1})
+	assert.Nil(t, err)
+	ci, ok := metaCache.GetContainerInfo("pod-1", "container-0")
+	assert.True(t, ok)
+	assert.Equal(t, float64(1), ci.CPURequest)
+
+	err = metaCache.SetPoolInfo("pool-0", &types.PoolInfo{PoolName: "pool-0"})
+	assert.Nil(t, err)
+	err = metaCache.GCPoolEntries(sets.NewString())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(poolEvents))
+	assert.Equal(t, metacache.EventKindAdded, poolEvents[0].Kind)
+	assert.Equal(t, metacache.EventKindDeleted, poolEvents[1].Kind)
+	assert.Equal(t, "pool-0", poolEvents[1].PoolName)
+}
+
+func TestSnapshot(t *testing.T) {
+	metaCache := newTestMetaCache(t)
+
+	err := metaCache.SetContainerInfo("pod-0", "container-0", &types.ContainerInfo{CPURequest: 1})
+	assert.Nil(t, err)
+	err = metaCache.SetPoolInfo("pool-0", &types.PoolInfo{PoolName: "pool-0"})
+	assert.Nil(t, err)
+
+	snapshot := metaCache.Snapshot()
+
+	// later writes to metacache are invisible to the snapshot
+	err = metaCache.SetContainerInfo("pod-0", "container-0", &types.ContainerInfo{CPURequest: 2})
+	assert.Nil(t, err)
+	err = metaCache.SetContainerInfo("pod-1", "container-0", &types.ContainerInfo{})
+	assert.Nil(t, err)
+	err = metaCache.DeletePool("pool-0")
+	assert.Nil(t, err)
+
+	ci, ok := snapshot.GetContainerInfo("pod-0", "container-0")
+	assert.True(t, ok)
+	assert.Equal(t, float64(1), ci.CPURequest)
+	_, ok = snapshot.GetContainerInfo("pod-1", "container-0")
+	assert.False(t, ok)
+	_, ok = snapshot.GetPoolInfo("pool-0")
+	assert.True(t, ok)
+
+	// mutating returned entries does not affect the snapshot
+	ci.CPURequest = -1
+	ci, _ = snapshot.GetContainerInfo("pod-0", "container-0")
+	assert.Equal(t, float64(1), ci.CPURequest)
+}
+
+func TestGetContainersByQoSLevel(t *testing.T) {
+	metaCache := newTestMetaCache(t)
+
+	containers := []*types.ContainerInfo{
+		{PodUID: "pod-0", ContainerName: "c1", QoSLevel: consts.PodAnnotationQoSLevelSharedCores},
+		{PodUID: "pod-0", ContainerName: "c2", QoSLevel: consts.PodAnnotationQoSLevelSharedCores},
+		{PodUID: "pod-1", ContainerName: "c1", QoSLevel: consts.PodAnnotationQoSLevelDedicatedCores},
+		{PodUID: "pod-2", ContainerName: "c1", QoSLevel: consts.PodAnnotationQoSLevelReclaimedCores},
+	}
+	for _, ci := range containers {
+		err := metaCache.SetContainerInfo(ci.PodUID, ci.ContainerName, ci)
+		assert.Nil(t, err)
+	}
+
+	shared := metaCache.GetContainersByQoSLevel(consts.PodAnnotationQoSLevelSharedCores)
+	assert.Len(t, shared, 2)
+	assert.Equal(t, 2, metaCache.GetContainerCountByQoSLevel(consts.PodAnnotationQoSLevelSharedCores))
+	assert.Equal(t, 1, metaCache.GetContainerCountByQoSLevel(consts.PodAnnotationQoSLevelDedicatedCores))
+	assert.Equal(t, 0, metaCache.GetContainerCountByQoSLevel(consts.PodAnnotationQoSLevelSystemCores))
+	assert.Empty(t, metaCache.GetContainersByQoSLevel(consts.PodAnnotationQoSLevelSystemCores))
+
+	// returned containers are deep copies
+	shared[0].QoSLevel = consts.PodAnnotationQoSLevelReclaimedCores
+	assert.Equal(t, 2, metaCache.GetContainerCountByQoSLevel(consts.PodAnnotationQoSLevelSharedCores))
+}
+
+func TestContainerEphemeral(t *testing.T) {
+	metaCache := newTestMetaCache(t)
+
+	err := metaCache.SetContainerEphemeral("pod-0", "container-0", "smoothed", 1.5)
+	assert.NotNil(t, err)
+
+	err = metaCache.SetContainerInfo("pod-0", "container-0", &types.ContainerInfo{})
+	assert.Nil(t, err)
+	err = metaCache.SetContainerInfo("pod-0", "container-1", &types.ContainerInfo{})
+	assert.Nil(t, err)
+	err = metaCache.SetContainerEphemeral("pod-0", "container-0", "smoothed", 1.5)
+	assert.Nil(t, err)
+	err = metaCache.SetContainerEphemeral("pod-0", "container-1", "smoothed", 2.5)
+	assert.Nil(t, err)
+
+	value, ok := metaCache.GetContainerEphemeral("pod-0", "container-0", "smoothed")
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, value)
+	_, ok = metaCache.GetContainerEphemeral("pod-0", "container-0", "unknown")
+	assert.False(t, ok)
+
+	// ephemeral values are cleaned up with containers
+	err = metaCache.DeleteContainer("pod-0", "container-0")
+	assert.Nil(t, err)
+	_, ok = metaCache.GetContainerEphemeral("pod-0", "container-0", "smoothed")
+	assert.False(t, ok)
+	_, ok = metaCache.GetContainerEphemeral("pod-0", "container-1", "smoothed")
+	assert.True(t, ok)
+
+	err = metaCache.RemovePod("pod-0")
+	assert.Nil(t, err)
+	_, ok = metaCache.GetContainerEphemeral("pod-0", "container-1", "smoothed")
+	assert.False(t, ok)
+}
+
+func TestGCPodEntries(t *testing.T) {
+	metaCache := newTestMetaCache(t)
+
+	var deleted []string
+	metaCache.RegisterContainerObserver(func(event metacache.ContainerEvent) {
+		if event.Kind == metacache.EventKindDeleted {
+			deleted = append(deleted, event.PodUID)
+		}
+	})
+
+	for i := 0; i < 3; i++ {
+		podUID := fmt.Sprintf("pod-%d", i)
+		err := metaCache.SetContainerInfo(podUID, "container-0", &types.ContainerInfo{})
+		assert.Nil(t, err)
+		err = metaCache.SetContainerEphemeral(podUID, "container-0", "smoothed", 1.5)
+		assert.Nil(t, err)
+	}
+
+	err := metaCache.GCPodEntries(sets.NewString("pod-1"))
+	assert.Nil(t, err)
+
+	_, ok := metaCache.GetContainerEntries("pod-1")
+	assert.True(t, ok)
+	for _, podUID := range []string{"pod-0", "pod-2"} {
+		_, ok = metaCache.GetContainerEntries(podUID)
+		assert.False(t, ok)
+		_, ok = metaCache.GetContainerEphemeral(podUID, "container-0", "smoothed")
+		assert.False(t, ok)
+	}
+	assert.ElementsMatch(t, []string{"pod-0", "pod-2"}, deleted)
+}
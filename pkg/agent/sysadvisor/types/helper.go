@@ -17,8 +17,6 @@ limitations under the License.
 package types
 
 import (
-	"reflect"
-
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/kubewharf/katalyst-api/pkg/consts"
@@ -58,14 +56,19 @@ func (ci *ContainerInfo) Clone() *ContainerInfo {
 	return clone
 }
 
-// UpdateMeta updates mutable container meta from another container info
-func (ci *ContainerInfo) UpdateMeta(c *ContainerInfo) {
-	if c.CPURequest > 0 {
+// UpdateMeta updates mutable container meta from another container info,
+// and returns true if any request quantity is changed, e.g. adjusted by vpa
+func (ci *ContainerInfo) UpdateMeta(c *ContainerInfo) bool {
+	changed := false
+	if c.CPURequest > 0 && c.CPURequest != ci.CPURequest {
 		ci.CPURequest = c.CPURequest
+		changed = true
 	}
-	if c.MemoryRequest > 0 {
+	if c.MemoryRequest > 0 && c.MemoryRequest != ci.MemoryRequest {
 		ci.MemoryRequest = c.MemoryRequest
+		changed = true
 	}
+	return changed
 }
 
 func (ta TopologyAwareAssignment) Clone() TopologyAwareAssignment {
@@ -88,8 +91,19 @@ func (ta TopologyAwareAssignment) MergeCPUSet() machine.CPUSet {
 	return cpusets
 }
 
+// Equals returns true if both assignments are nil or non-nil with the same cpusets, and
+// it keeps the same semantics as reflect.DeepEqual, i.e. nil and empty are not equal
 func (ta TopologyAwareAssignment) Equals(t TopologyAwareAssignment) bool {
-	return reflect.DeepEqual(ta, t)
+	if (ta == nil) != (t == nil) || len(ta) != len(t) {
+		return false
+	}
+	for numaID, cpuset := range ta {
+		other, ok := t[numaID]
+		if !ok || !equalCPUSet(cpuset, other) {
+			return false
+		}
+	}
+	return true
 }
 
 func (pi *PoolInfo) Clone() *PoolInfo {
@@ -101,10 +115,48 @@ func (pi *PoolInfo) Clone() *PoolInfo {
 		TopologyAwareAssignments:         pi.TopologyAwareAssignments.Clone(),
 		OriginalTopologyAwareAssignments: pi.OriginalTopologyAwareAssignments.Clone(),
 		RegionNames:                      sets.NewString(pi.RegionNames.List()...),
+		Revision:                         pi.Revision,
 	}
 	return clone
 }
 
+// Equal compares all fields of container info without reflection, and it keeps the same
+// semantics as reflect.DeepEqual. it must be updated once a field is added to ContainerInfo.
+func (ci *ContainerInfo) Equal(other *ContainerInfo) bool {
+	if ci == nil || other == nil {
+		return ci == other
+	}
+	return ci.PodUID == other.PodUID &&
+		ci.PodNamespace == other.PodNamespace &&
+		ci.PodName == other.PodName &&
+		ci.ContainerName == other.ContainerName &&
+		ci.ContainerType == other.ContainerType &&
+		ci.ContainerIndex == other.ContainerIndex &&
+		equalStringMap(ci.Labels, other.Labels) &&
+		equalStringMap(ci.Annotations, other.Annotations) &&
+		ci.QoSLevel == other.QoSLevel &&
+		ci.CPURequest == other.CPURequest &&
+		ci.MemoryRequest == other.MemoryRequest &&
+		ci.RampUp == other.RampUp &&
+		ci.OwnerPoolName == other.OwnerPoolName &&
+		ci.TopologyAwareAssignments.Equals(other.TopologyAwareAssignments) &&
+		ci.OriginalTopologyAwareAssignments.Equals(other.OriginalTopologyAwareAssignments) &&
+		equalStringSet(ci.RegionNames, other.RegionNames)
+}
+
+// Equal compares all fields of pool info without reflection, and it keeps the same
+// semantics as reflect.DeepEqual. it must be updated once a field is added to PoolInfo.
+func (pi *PoolInfo) Equal(other *PoolInfo) bool {
+	if pi == nil || other == nil {
+		return pi == other
+	}
+	return pi.PoolName == other.PoolName &&
+		pi.TopologyAwareAssignments.Equals(other.TopologyAwareAssignments) &&
+		pi.OriginalTopologyAwareAssignments.Equals(other.OriginalTopologyAwareAssignments) &&
+		equalStringSet(pi.RegionNames, other.RegionNames) &&
+		pi.Revision == other.Revision
+}
+
 func (ri *RegionInfo) Clone() *RegionInfo {
 	if ri == nil {
 		return nil
@@ -124,6 +176,22 @@ func (ri *RegionInfo) Clone() *RegionInfo {
 	return clone
 }
 
+// Equal compares all fields of region info without reflection, and it keeps the same
+// semantics as reflect.DeepEqual. it must be updated once a field is added to RegionInfo.
+func (ri *RegionInfo) Equal(other *RegionInfo) bool {
+	if ri == nil || other == nil {
+		return ri == other
+	}
+	return ri.RegionType == other.RegionType &&
+		equalCPUSet(ri.BindingNumas, other.BindingNumas) &&
+		ri.HeadroomPolicyTopPriority == other.HeadroomPolicyTopPriority &&
+		ri.HeadroomPolicyInUse == other.HeadroomPolicyInUse &&
+		ri.Headroom == other.Headroom &&
+		ri.ControlKnobMap.Equal(other.ControlKnobMap) &&
+		ri.ProvisionPolicyTopPriority == other.ProvisionPolicyTopPriority &&
+		ri.ProvisionPolicyInUse == other.ProvisionPolicyInUse
+}
+
 func (ce ContainerEntries) Clone() ContainerEntries {
 	if ce == nil {
 		return nil
@@ -146,6 +214,36 @@ func (pe PodEntries) Clone() PodEntries {
 	return clone
 }
 
+// Equal returns true if both container entries hold equal container info by name,
+// with the same semantics as reflect.DeepEqual
+func (ce ContainerEntries) Equal(other ContainerEntries) bool {
+	if (ce == nil) != (other == nil) || len(ce) != len(other) {
+		return false
+	}
+	for containerName, containerInfo := range ce {
+		otherInfo, ok := other[containerName]
+		if !ok || !containerInfo.Equal(otherInfo) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal returns true if both pod entries hold equal container entries by pod uid,
+// with the same semantics as reflect.DeepEqual
+func (pe PodEntries) Equal(other PodEntries) bool {
+	if (pe == nil) != (other == nil) || len(pe) != len(other) {
+		return false
+	}
+	for podUID, containerEntries := range pe {
+		otherEntries, ok := other[podUID]
+		if !ok || !containerEntries.Equal(otherEntries) {
+			return false
+		}
+	}
+	return true
+}
+
 func (pe PoolEntries) Clone() PoolEntries {
 	if pe == nil {
 		return nil
@@ -168,6 +266,34 @@ func (re RegionEntries) Clone() RegionEntries {
 	return clone
 }
 
+// Equal returns true if both region entries hold equal region info by name,
+// with the same semantics as reflect.DeepEqual
+func (re RegionEntries) Equal(other RegionEntries) bool {
+	if (re == nil) != (other == nil) || len(re) != len(other) {
+		return false
+	}
+	for regionName, regionInfo := range re {
+		otherInfo, ok := other[regionName]
+		if !ok || !regionInfo.Equal(otherInfo) {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge stores copies of region info in other into re, overwriting the ones with the same
+// region name, and the other regions in re are kept untouched; nil region info in other
+// deletes the region from re, and re must be non-nil
+func (re RegionEntries) Merge(other RegionEntries) {
+	for regionName, regionInfo := range other {
+		if regionInfo == nil {
+			delete(re, regionName)
+			continue
+		}
+		re[regionName] = regionInfo.Clone()
+	}
+}
+
 func (ps PodSet) Clone() PodSet {
 	if ps == nil {
 		return nil
@@ -188,6 +314,14 @@ func (ps PodSet) Insert(podUID string, containerName string) {
 	containerSet.Insert(containerName)
 }
 
+// EnableReclaimOnNUMA returns whether reclaim is enabled on the given numa node
+func (re ResourceEssentials) EnableReclaimOnNUMA(numaID int) bool {
+	if enable, ok := re.NUMAEnableReclaim[numaID]; ok {
+		return enable
+	}
+	return re.EnableReclaim
+}
+
 func (ck ControlKnob) Clone() ControlKnob {
 	if ck == nil {
 		return nil
@@ -198,3 +332,46 @@ func (ck ControlKnob) Clone() ControlKnob {
 	}
 	return clone
 }
+
+// Equal returns true if both control knobs hold the same values, with the same
+// semantics as reflect.DeepEqual
+func (ck ControlKnob) Equal(other ControlKnob) bool {
+	if (ck == nil) != (other == nil) || len(ck) != len(other) {
+		return false
+	}
+	for name, value := range ck {
+		if otherValue, ok := other[name]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// equalCPUSet compares cpusets including whether they are initialized, as reflect.DeepEqual does
+func equalCPUSet(a, b machine.CPUSet) bool {
+	return a.Initialed == b.Initialed && a.Equals(b)
+}
+
+func equalStringMap(a, b map[string]string) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if otherV, ok := b[k]; !ok || otherV != v {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStringSet(a, b sets.String) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if !b.Has(k) {
+			return false
+		}
+	}
+	return true
+}
@@ -61,3 +61,174 @@ func TestClonePodEntries(t *testing.T) {
 
 	assert.True(t, reflect.DeepEqual(copyPodEntries, podEntries))
 }
+
+func TestRegionEntriesMerge(t *testing.T) {
+	entries := RegionEntries{
+		"share-0": {RegionType: QoSRegionTypeShare, Headroom: 1},
+		"share-1": {RegionType: QoSRegionTypeShare, Headroom: 2},
+	}
+	other := RegionEntries{
+		"share-0": {RegionType: QoSRegionTypeShare, Headroom: 3},
+		"share-2": {RegionType: QoSRegionTypeShare, Headroom: 4},
+	}
+	entries.Merge(other)
+
+	assert.Len(t, entries, 3)
+	assert.Equal(t, float64(3), entries["share-0"].Headroom)
+	assert.Equal(t, float64(2), entries["share-1"].Headroom)
+	assert.Equal(t, float64(4), entries["share-2"].Headroom)
+
+	// merged entries are copies of the other ones
+	other["share-0"].Headroom = 5
+	assert.Equal(t, float64(3), entries["share-0"].Headroom)
+
+	// nil region info deletes the region
+	entries.Merge(RegionEntries{"share-1": nil, "share-3": nil})
+	assert.Len(t, entries, 2)
+	_, ok := entries["share-1"]
+	assert.False(t, ok)
+}
+
+func newTestContainerInfo() *ContainerInfo {
+	return &ContainerInfo{
+		PodUID:        "uid1",
+		PodNamespace:  "ns1",
+		PodName:       "pod1",
+		ContainerName: "c1",
+		Labels:        map[string]string{"k1": "v1"},
+		Annotations:   map[string]string{"k1": "v1"},
+		QoSLevel:      consts.PodAnnotationQoSLevelSharedCores,
+		CPURequest:    1,
+		MemoryRequest: 2,
+		OwnerPoolName: "share",
+		TopologyAwareAssignments: map[int]machine.CPUSet{
+			0: machine.NewCPUSet(1, 2),
+			1: machine.NewCPUSet(25, 26),
+		},
+		OriginalTopologyAwareAssignments: map[int]machine.CPUSet{
+			0: machine.NewCPUSet(1, 2),
+			1: machine.NewCPUSet(25, 26),
+		},
+		RegionNames: sets.NewString("share"),
+	}
+}
+
+func TestContainerInfoEqual(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(ci *ContainerInfo)
+	}{
+		{name: "unchanged", modify: func(ci *ContainerInfo) {}},
+		{name: "request", modify: func(ci *ContainerInfo) { ci.CPURequest = 2 }},
+		{name: "nil labels", modify: func(ci *ContainerInfo) { ci.Labels = nil }},
+		{name: "empty annotations", modify: func(ci *ContainerInfo) { ci.Annotations = map[string]string{} }},
+		{name: "label value", modify: func(ci *ContainerInfo) { ci.Labels["k1"] = "v2" }},
+		{name: "cpuset", modify: func(ci *ContainerInfo) { ci.TopologyAwareAssignments[0] = machine.NewCPUSet(1) }},
+		{name: "uninitialized cpuset", modify: func(ci *ContainerInfo) { ci.TopologyAwareAssignments[0] = machine.CPUSet{} }},
+		{name: "nil assignments", modify: func(ci *ContainerInfo) { ci.OriginalTopologyAwareAssignments = nil }},
+		{name: "region names", modify: func(ci *ContainerInfo) { ci.RegionNames.Insert("isolation") }},
+		{name: "nil region names", modify: func(ci *ContainerInfo) { ci.RegionNames = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ci := newTestContainerInfo()
+			other := ci.Clone()
+			tt.modify(other)
+
+			// Equal must keep the same semantics as reflect.DeepEqual
+			assert.Equal(t, reflect.DeepEqual(ci, other), ci.Equal(other))
+			assert.Equal(t, reflect.DeepEqual(other, ci), other.Equal(ci))
+
+			podEntries := PodEntries{"uid1": ContainerEntries{"c1": ci}}
+			otherPodEntries := PodEntries{"uid1": ContainerEntries{"c1": other}}
+			assert.Equal(t, reflect.DeepEqual(podEntries, otherPodEntries), podEntries.Equal(otherPodEntries))
+		})
+	}
+
+	var nilInfo *ContainerInfo
+	assert.True(t, nilInfo.Equal(nil))
+	assert.False(t, nilInfo.Equal(newTestContainerInfo()))
+	assert.False(t, newTestContainerInfo().Equal(nil))
+	assert.True(t, PodEntries(nil).Equal(nil))
+	assert.False(t, PodEntries{}.Equal(nil))
+}
+
+func TestPoolInfoEqual(t *testing.T) {
+	pi := &PoolInfo{
+		PoolName: "share",
+		TopologyAwareAssignments: map[int]machine.CPUSet{
+			0: machine.NewCPUSet(1, 2),
+		},
+		RegionNames: sets.NewString("share"),
+		Revision:    1,
+	}
+
+	for _, modify := range []func(pi *PoolInfo){
+		func(pi *PoolInfo) {},
+		func(pi *PoolInfo) { pi.PoolName = "reclaim" },
+		func(pi *PoolInfo) { pi.TopologyAwareAssignments[1] = machine.NewCPUSet() },
+		func(pi *PoolInfo) { pi.OriginalTopologyAwareAssignments = TopologyAwareAssignment{} },
+		func(pi *PoolInfo) { pi.RegionNames = sets.NewString() },
+		func(pi *PoolInfo) { pi.Revision = 2 },
+	} {
+		other := pi.Clone()
+		modify(other)
+		assert.Equal(t, reflect.DeepEqual(pi, other), pi.Equal(other))
+	}
+}
+
+func TestRegionInfoEqual(t *testing.T) {
+	ri := &RegionInfo{
+		RegionType:   QoSRegionTypeShare,
+		BindingNumas: machine.NewCPUSet(0, 1),
+		Headroom:     1,
+		ControlKnobMap: ControlKnob{
+			ControlKnobNonReclaimedCPUSetSize: {Value: 10, Action: ControlKnobActionNone},
+		},
+	}
+
+	for _, modify := range []func(ri *RegionInfo){
+		func(ri *RegionInfo) {},
+		func(ri *RegionInfo) { ri.RegionType = QoSRegionTypeDedicatedNumaExclusive },
+		func(ri *RegionInfo) { ri.BindingNumas = machine.NewCPUSet(0) },
+		func(ri *RegionInfo) { ri.BindingNumas = machine.CPUSet{} },
+		func(ri *RegionInfo) { ri.Headroom = 2 },
+		func(ri *RegionInfo) {
+			ri.ControlKnobMap[ControlKnobNonReclaimedCPUSetSize] = ControlKnobValue{Value: 11}
+		},
+		func(ri *RegionInfo) { ri.ControlKnobMap = nil },
+		func(ri *RegionInfo) { ri.HeadroomPolicyInUse = "canonical" },
+	} {
+		other := ri.Clone()
+		modify(other)
+		assert.Equal(t, reflect.DeepEqual(ri, other), ri.Equal(other))
+
+		entries := RegionEntries{"share": ri}
+		otherEntries := RegionEntries{"share": other}
+		assert.Equal(t, reflect.DeepEqual(entries, otherEntries), entries.Equal(otherEntries))
+	}
+
+	var nilInfo *RegionInfo
+	assert.True(t, nilInfo.Equal(nil))
+	assert.False(t, nilInfo.Equal(ri))
+	assert.True(t, RegionEntries(nil).Equal(nil))
+	assert.False(t, RegionEntries{}.Equal(nil))
+}
+
+func BenchmarkContainerInfoEqual(b *testing.B) {
+	ci := newTestContainerInfo()
+	other := ci.Clone()
+
+	b.Run("reflect.DeepEqual", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = reflect.DeepEqual(ci, other)
+		}
+	})
+	b.Run("Equal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = ci.Equal(other)
+		}
+	})
+}
@@ -59,8 +59,9 @@ const (
 type MemoryHeadroomPolicyName string
 
 const (
-	MemoryHeadroomPolicyNone      MemoryHeadroomPolicyName = "none"
-	MemoryHeadroomPolicyCanonical MemoryHeadroomPolicyName = "canonical"
+	MemoryHeadroomPolicyNone        MemoryHeadroomPolicyName = "none"
+	MemoryHeadroomPolicyCanonical   MemoryHeadroomPolicyName = "canonical"
+	MemoryHeadroomPolicyUtilization MemoryHeadroomPolicyName = "utilization"
 )
 
 // QoSRegionType declares pre-defined region types
@@ -109,6 +110,10 @@ type PoolInfo struct {
 	TopologyAwareAssignments         TopologyAwareAssignment
 	OriginalTopologyAwareAssignments TopologyAwareAssignment
 	RegionNames                      sets.String
+
+	// Revision increases monotonically each time the pool info is changed in metacache,
+	// and it's used for compare-and-set to avoid lost updates between concurrent writers
+	Revision int64
 }
 
 // RegionInfo contains region information generated by sysadvisor resource advisor
@@ -157,6 +162,9 @@ const (
 // ResourceEssentials defines essential (const) variables, and those variables may be adjusted by KCC
 type ResourceEssentials struct {
 	EnableReclaim bool
+	// NUMAEnableReclaim overrides EnableReclaim for specific numa nodes,
+	// and numa nodes not in this map just follow EnableReclaim
+	NUMAEnableReclaim map[int]bool
 
 	Total               int
 	ReservePoolSize     int
@@ -27,6 +27,7 @@ import (
 type ReclaimedResourceConfiguration struct {
 	mutex                         sync.RWMutex
 	enableReclaim                 bool
+	disableReclaimNUMAs           []int
 	reservedResourceForReport     v1.ResourceList
 	minReclaimedResourceForReport v1.ResourceList
 	reservedResourceForAllocate   v1.ResourceList
@@ -59,6 +60,21 @@ func (c *ReclaimedResourceConfiguration) SetEnableReclaim(enableReclaim bool) {
 	c.enableReclaim = enableReclaim
 }
 
+// DisableReclaimNUMAs returns numa nodes on which reclaim is disabled even if reclaim is enabled
+func (c *ReclaimedResourceConfiguration) DisableReclaimNUMAs() []int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.disableReclaimNUMAs
+}
+
+func (c *ReclaimedResourceConfiguration) SetDisableReclaimNUMAs(disableReclaimNUMAs []int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.disableReclaimNUMAs = disableReclaimNUMAs
+}
+
 func (c *ReclaimedResourceConfiguration) ReservedResourceForReport() v1.ResourceList {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
@@ -133,6 +149,7 @@ func (c *ReclaimedResourceConfiguration) ApplyConfiguration(defaultConf *Reclaim
 
 func (c *ReclaimedResourceConfiguration) applyDefault(defaultConf *ReclaimedResourceConfiguration) {
 	c.enableReclaim = defaultConf.enableReclaim
+	c.disableReclaimNUMAs = append([]int{}, defaultConf.disableReclaimNUMAs...)
 	c.reservedResourceForReport = defaultConf.reservedResourceForReport.DeepCopy()
 	c.minReclaimedResourceForReport = defaultConf.minReclaimedResourceForReport.DeepCopy()
 	c.reservedResourceForAllocate = defaultConf.reservedResourceForAllocate.DeepCopy()
@@ -28,6 +28,12 @@ type MetaServerConfiguration struct {
 	CNRCacheTTL                    time.Duration
 	CustomNodeConfigCacheTTL       time.Duration
 	ServiceProfileCacheTTL         time.Duration
+	ServiceProfileCacheTTLJitter   float64
+	ServiceProfileNegativeCacheTTL time.Duration
+	ServiceProfileOverrideDir      string
+	ServiceProfileReconcilePeriod  time.Duration
+	ServiceProfilePrefetch         bool
+	ServiceProfileEnableInformer   bool
 	ConfigCacheTTL                 time.Duration
 	ConfigSkipFailedInitialization bool
 	ConfigDisableDynamic           bool
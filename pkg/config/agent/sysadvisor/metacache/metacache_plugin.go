@@ -22,9 +22,29 @@ import (
 	"github.com/kubewharf/katalyst-core/pkg/config/dynamic"
 )
 
+const (
+	// CheckpointValidationPolicyDropInvalid drops invalid entries and keeps the valid ones
+	CheckpointValidationPolicyDropInvalid = "drop-invalid"
+	// CheckpointValidationPolicyRejectAll drops all entries of a group if any of them is invalid
+	CheckpointValidationPolicyRejectAll = "reject-all"
+)
+
 // MetaCachePluginConfiguration stores configurations of metacache Plugin
 type MetaCachePluginConfiguration struct {
 	SyncPeriod time.Duration
+	// ReclaimPoolNamePrefix is the name prefix of pools whose cpus are reclaimable
+	ReclaimPoolNamePrefix string
+	// CheckpointCodec is the codec used to marshal entries into checkpoints
+	CheckpointCodec string
+	// AsyncStoreInterval is the interval to store checkpoints in background with mutations
+	// coalesced, and checkpoints are stored synchronously on each mutation if not positive
+	AsyncStoreInterval time.Duration
+	// CheckpointValidationPolicy decides how to handle restored entries not matching machine topology,
+	// either dropping invalid entries or rejecting all entries of the group
+	CheckpointValidationPolicy string
+	// ContainerSampleCapacity is the max number of in-memory samples kept for each container,
+	// and container samples are disabled if not positive
+	ContainerSampleCapacity int
 }
 
 // NewMetaCachePluginConfiguration creates a new metacache Plugin configuration.
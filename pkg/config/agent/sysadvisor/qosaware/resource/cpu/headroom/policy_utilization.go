@@ -23,6 +23,20 @@ type PolicyUtilizationConfiguration struct {
 	ReclaimedCPUMaxCoreUtilization      float64
 	ReclaimedCPUMaxOversoldRate         float64
 	ReclaimedCPUMaxHeadroomCapacityRate float64
+
+	// ReclaimedCPUTargetCoreUtilizationIndicator is the name of spd indicator which specifies the target
+	// core utilization of reclaimed pool tolerated by the pod, and it's disabled if empty. the effective
+	// target is the minimum of ReclaimedCPUTargetCoreUtilization and indicator values of all pods on the
+	// node, i.e. the most restrictive one wins, and values out of (0, 1] are ignored
+	ReclaimedCPUTargetCoreUtilizationIndicator string
+
+	// ReclaimedCPUHeadroomSmoothingAlpha is the weight of the latest headroom in exponential smoothing
+	// of headroom to avoid reclaim flapping, and smoothing is disabled if it's not in (0, 1)
+	ReclaimedCPUHeadroomSmoothingAlpha float64
+	// ReclaimedCPUMinHeadroom is the floor of headroom to avoid evicting reclaimed workloads on transient
+	// usage spikes, it's the number of cores if not less than one, otherwise the fraction of node capacity,
+	// and the floor is disabled if it's not positive
+	ReclaimedCPUMinHeadroom float64
 }
 
 func NewPolicyUtilizationConfiguration() *PolicyUtilizationConfiguration {
@@ -19,15 +19,18 @@ package headroom
 import "github.com/kubewharf/katalyst-core/pkg/config/dynamic"
 
 type MemoryHeadroomPolicyConfiguration struct {
-	MemoryPolicyCanonicalConfiguration *MemoryPolicyCanonicalConfiguration
+	MemoryPolicyCanonicalConfiguration   *MemoryPolicyCanonicalConfiguration
+	MemoryPolicyUtilizationConfiguration *MemoryPolicyUtilizationConfiguration
 }
 
 func NewMemoryHeadroomPolicyConfiguration() *MemoryHeadroomPolicyConfiguration {
 	return &MemoryHeadroomPolicyConfiguration{
-		MemoryPolicyCanonicalConfiguration: NewMemoryPolicyCanonicalConfiguration(),
+		MemoryPolicyCanonicalConfiguration:   NewMemoryPolicyCanonicalConfiguration(),
+		MemoryPolicyUtilizationConfiguration: NewMemoryPolicyUtilizationConfiguration(),
 	}
 }
 
 func (c *MemoryHeadroomPolicyConfiguration) ApplyConfiguration(defaultConf *MemoryHeadroomPolicyConfiguration, conf *dynamic.DynamicConfigCRD) {
 	c.MemoryPolicyCanonicalConfiguration.ApplyConfiguration(defaultConf.MemoryPolicyCanonicalConfiguration, conf)
+	c.MemoryPolicyUtilizationConfiguration.ApplyConfiguration(defaultConf.MemoryPolicyUtilizationConfiguration, conf)
 }
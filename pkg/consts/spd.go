@@ -20,3 +20,15 @@ package consts
 const (
 	ServiceProfileDescriptorAnnotationKeyConfigHash = "spd.katalyst.kubewharf.io/config.hash"
 )
+
+// PodAnnotationSPDExtraNamesKey defines the pod annotation of extra spd names applied to the pod
+// besides the one named by spd name annotation, and they're comma separated in priority order.
+const (
+	PodAnnotationSPDExtraNamesKey = "spd.katalyst.kubewharf.io/extra-names"
+)
+
+// ServiceProfileDescriptorAnnotationKeyCacheTTL defines the spd annotation to override the global
+// spd cache ttl of agent for this spd, and its value is a duration string such as "30s".
+const (
+	ServiceProfileDescriptorAnnotationKeyCacheTTL = "spd.katalyst.kubewharf.io/cache-ttl"
+)
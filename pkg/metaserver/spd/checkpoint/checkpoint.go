@@ -113,5 +113,9 @@ func WriteSPD(cpm checkpointmanager.CheckpointManager, spd *v1alpha1.ServiceProf
 
 // DeleteSPD deletes a checkpoint from disk if present
 func DeleteSPD(cpm checkpointmanager.CheckpointManager, spd *v1alpha1.ServiceProfileDescriptor) error {
+	// spd info may be initialized by attempts to fetch spd not existing, and there is no checkpoint of it
+	if spd == nil {
+		return nil
+	}
 	return cpm.RemoveCheckpoint(getSPDKey(spd))
 }
@@ -19,6 +19,10 @@ package spd
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,10 +32,18 @@ import (
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
 
 	configapis "github.com/kubewharf/katalyst-api/pkg/apis/config/v1alpha1"
 	workloadapis "github.com/kubewharf/katalyst-api/pkg/apis/workload/v1alpha1"
+	"github.com/kubewharf/katalyst-api/pkg/client/informers/externalversions"
+	workloadlisters "github.com/kubewharf/katalyst-api/pkg/client/listers/workload/v1alpha1"
 	"github.com/kubewharf/katalyst-core/pkg/client"
 	pkgconfig "github.com/kubewharf/katalyst-core/pkg/config"
 	"github.com/kubewharf/katalyst-core/pkg/metaserver/agent/cnc"
@@ -41,21 +53,54 @@ import (
 )
 
 const (
-	defaultClearUnusedSPDPeriod = 10 * time.Minute
+	defaultClearUnusedSPDPeriod   = 10 * time.Minute
+	defaultEmitCacheMetricsPeriod = 30 * time.Second
+	defaultPrefetchWorkers        = 8
 )
 
 const (
 	metricsNameGetCNCTargetConfigFailed = "spd_manager_get_cnc_target_failed"
 	metricsNameUpdateCacheFailed        = "spd_manager_update_cache_failed"
 	metricsNameCacheNotFound            = "spd_manager_cache_not_found"
+	metricsNameNegativeCacheHit         = "spd_manager_negative_cache_hit"
+	metricsNameSPDOverridden            = "spd_manager_spd_overridden"
+	metricsNameLoadOverrideFailed       = "spd_manager_load_override_failed"
+	metricsNameReconcileOrphanDeleted   = "spd_manager_reconcile_orphan_deleted"
+	metricsNameReconcilePrefetched      = "spd_manager_reconcile_prefetched"
+	metricsNameCacheHit                 = "spd_manager_cache_hit"
+	metricsNameRemoteFetch              = "spd_manager_remote_fetch"
+	metricsNameCachedSPDCount           = "spd_manager_cached_spd_count"
+	metricsNameBaselineSPDUsed          = "spd_manager_baseline_spd_used"
+	metricsNameInvalidSPD               = "spd_manager_invalid_spd"
+)
+
+const (
+	overrideFileBufferSize = 4096
 )
 
 type GetPodSPDNameFunc func(pod *v1.Pod) (string, error)
 
+type GetPodSPDNamesFunc func(pod *v1.Pod) ([]string, error)
+
+// BaselineSPDFunc returns the baseline spd for the given namespace/name, and nil means no baseline
+type BaselineSPDFunc func(namespace, name string) *workloadapis.ServiceProfileDescriptor
+
 type ServiceProfileManager interface {
 	// GetSPD get spd for given pod
 	GetSPD(ctx context.Context, pod *v1.Pod) (*workloadapis.ServiceProfileDescriptor, error)
 
+	// GetSPDs get all spd selecting given pod in priority order, and spd not found is skipped.
+	// if indicators conflict across spd, callers should take the one from spd of higher
+	// priority, i.e. the earlier one in the list, instead of combining them
+	GetSPDs(ctx context.Context, pod *v1.Pod) ([]*workloadapis.ServiceProfileDescriptor, error)
+
+	// GetSPDIndicator get value of the named business or system indicator from spd of given pod,
+	// and ok is false if the indicator is absent in spd
+	GetSPDIndicator(ctx context.Context, pod *v1.Pod, indicatorName string) (float64, bool, error)
+
+	// PrefetchSPDs loads spd of given pods into cache in bulk, so that following GetSPD hit cache
+	PrefetchSPDs(ctx context.Context, pods []*v1.Pod) error
+
 	// Run async loop to clear unused spd
 	Run(ctx context.Context)
 }
@@ -64,24 +109,82 @@ type spdManager struct {
 	started *atomic.Bool
 	mux     sync.Mutex
 
-	client            *client.GenericClientSet
-	emitter           metrics.MetricEmitter
-	cncFetcher        cnc.CNCFetcher
-	checkpointManager checkpointmanager.CheckpointManager
-	getPodSPDNameFunc GetPodSPDNameFunc
+	client             *client.GenericClientSet
+	emitter            metrics.MetricEmitter
+	cncFetcher         cnc.CNCFetcher
+	checkpointManager  checkpointmanager.CheckpointManager
+	getPodSPDNameFunc  GetPodSPDNameFunc
+	getPodSPDNamesFunc GetPodSPDNamesFunc
+	// podSPDNamesFuncSet is true if getPodSPDNamesFunc is explicitly overridden,
+	// otherwise it follows the overridden getPodSPDNameFunc
+	podSPDNamesFuncSet bool
+
+	// baselineSPDFunc provides the fallback spd once it fails to get spd from both
+	// cache and remote, and it's disabled if nil
+	baselineSPDFunc BaselineSPDFunc
 
 	ServiceProfileCacheTTL time.Duration
+	// serviceProfileCacheTTLJitter is the max fraction of random jitter applied to the
+	// cache ttl of each spd, and it's disabled if zero
+	serviceProfileCacheTTLJitter float64
+	// refreshingSPDs is the set of spd keys being refreshed forcibly, and it's guarded by mux
+	refreshingSPDs sets.String
+	// randFloat64 returns a random number in [0.0,1.0) to generate ttl jitter,
+	// and it's injectable to make jitter deterministic in tests
+	randFloat64 func() float64
+	// serviceProfileNegativeCacheTTL is the ttl of caching that remote spd doesn't exist,
+	// and it's disabled if zero
+	serviceProfileNegativeCacheTTL time.Duration
+
+	// serviceProfileOverrideDir is the directory of local spd files which override
+	// the remote ones, and it's disabled if empty
+	serviceProfileOverrideDir string
+
+	// serviceProfileReconcilePeriod is the period to reconcile spd cache with
+	// cnc target list, and it's disabled if zero
+	serviceProfileReconcilePeriod time.Duration
+	// serviceProfilePrefetch decides whether to prefetch spd newly appeared in
+	// cnc target list when reconciling
+	serviceProfilePrefetch bool
+
+	// spdInformerFactory is only wired if informer is enabled, and then spd cache is updated
+	// on spd events instead of checking cnc target config on each GetSPD
+	spdInformerFactory externalversions.SharedInformerFactory
+	spdInformer        cache.SharedIndexInformer
+	spdLister          workloadlisters.ServiceProfileDescriptorLister
 
 	// spdCache is a cache of namespace/name to current target spd
-	spdCache *Cache
+	spdCache SPDStore
+
+	// cachedSPDNamespaces is the set of namespaces whose count of cached spd was reported last
+	// time, and it's only accessed by emitCacheMetrics
+	cachedSPDNamespaces sets.String
 }
 
-// NewSPDManager creates a spd manager to implement ServiceProfileManager
+// NewSPDManager creates a spd manager to implement ServiceProfileManager,
+// and spd is cached with checkpoints in conf.CheckpointManagerDir
 func NewSPDManager(clientSet *client.GenericClientSet, emitter metrics.MetricEmitter,
 	cncFetcher cnc.CNCFetcher, conf *pkgconfig.Configuration) (ServiceProfileManager, error) {
-	checkpointManager, err := checkpointmanager.NewCheckpointManager(conf.CheckpointManagerDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize checkpoint manager: %v", err)
+	return NewSPDManagerWithStore(clientSet, emitter, cncFetcher, conf, nil)
+}
+
+// NewSPDManagerWithStore creates a spd manager with spd cached in the given store,
+// and it falls back to the checkpoint backed cache if store is nil
+func NewSPDManagerWithStore(clientSet *client.GenericClientSet, emitter metrics.MetricEmitter,
+	cncFetcher cnc.CNCFetcher, conf *pkgconfig.Configuration, store SPDStore) (ServiceProfileManager, error) {
+	var checkpointManager checkpointmanager.CheckpointManager
+	if store == nil {
+		var err error
+		checkpointManager, err = checkpointmanager.NewCheckpointManager(conf.CheckpointManagerDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize checkpoint manager: %v", err)
+		}
+
+		spdCache := NewSPDCache(checkpointManager, defaultClearUnusedSPDPeriod)
+		if spdCache == nil {
+			return nil, fmt.Errorf("failed to initialize spd cache")
+		}
+		store = spdCache
 	}
 
 	m := &spdManager{
@@ -91,10 +194,33 @@ func NewSPDManager(clientSet *client.GenericClientSet, emitter metrics.MetricEmi
 		checkpointManager:      checkpointManager,
 		cncFetcher:             cncFetcher,
 		ServiceProfileCacheTTL: conf.ServiceProfileCacheTTL,
+
+		serviceProfileCacheTTLJitter:   conf.ServiceProfileCacheTTLJitter,
+		randFloat64:                    rand.Float64,
+		refreshingSPDs:                 sets.NewString(),
+		serviceProfileNegativeCacheTTL: conf.ServiceProfileNegativeCacheTTL,
+		serviceProfileOverrideDir:      conf.ServiceProfileOverrideDir,
+		serviceProfileReconcilePeriod:  conf.ServiceProfileReconcilePeriod,
+		serviceProfilePrefetch:         conf.ServiceProfilePrefetch,
 	}
 
 	m.getPodSPDNameFunc = util.GetPodSPDName
-	m.spdCache = NewSPDCache(checkpointManager, defaultClearUnusedSPDPeriod)
+	m.getPodSPDNamesFunc = util.GetPodSPDNames
+	m.spdCache = store
+
+	if conf.ServiceProfileEnableInformer && clientSet != nil && clientSet.InternalClient != nil {
+		m.spdInformerFactory = externalversions.NewSharedInformerFactory(clientSet.InternalClient, 0)
+		spdInformer := m.spdInformerFactory.Workload().V1alpha1().ServiceProfileDescriptors()
+		m.spdInformer = spdInformer.Informer()
+		m.spdLister = spdInformer.Lister()
+		m.spdInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: m.onSPDAddOrUpdate,
+			UpdateFunc: func(_, newObj interface{}) {
+				m.onSPDAddOrUpdate(newObj)
+			},
+			DeleteFunc: m.onSPDDelete,
+		})
+	}
 
 	return m, nil
 }
@@ -108,6 +234,106 @@ func (s *spdManager) GetSPD(ctx context.Context, pod *v1.Pod) (*workloadapis.Ser
 	return s.getSPDByNamespaceName(ctx, pod.GetNamespace(), spdName)
 }
 
+func (s *spdManager) GetSPDs(ctx context.Context, pod *v1.Pod) ([]*workloadapis.ServiceProfileDescriptor, error) {
+	spdNames, err := s.getPodSPDNamesFunc(pod)
+	if err != nil {
+		return nil, fmt.Errorf("get pod spd names failed: %v", err)
+	}
+
+	var spdList []*workloadapis.ServiceProfileDescriptor
+	for _, spdName := range spdNames {
+		spd, err := s.getSPDByNamespaceName(ctx, pod.GetNamespace(), spdName)
+		if err != nil {
+			klog.Warningf("[spd-manager] get spd %s/%s for pod %s failed: %v, skip it",
+				pod.GetNamespace(), spdName, pod.GetName(), err)
+			continue
+		}
+		spdList = append(spdList, spd)
+	}
+
+	if len(spdList) == 0 {
+		return nil, fmt.Errorf("no spd found for pod %s/%s", pod.GetNamespace(), pod.GetName())
+	}
+	return spdList, nil
+}
+
+func (s *spdManager) GetSPDIndicator(ctx context.Context, pod *v1.Pod, indicatorName string) (float64, bool, error) {
+	spd, err := s.GetSPD(ctx, pod)
+	if err != nil {
+		return 0, false, err
+	}
+
+	value, ok := util.GetSPDIndicatorValue(spd, indicatorName)
+	return value, ok, nil
+}
+
+// PrefetchSPDs loads spd of the given pods into cache concurrently, and cnc target config is fetched
+// only once for all of them. the rate of getting remote spd is still limited by cache ttl.
+func (s *spdManager) PrefetchSPDs(ctx context.Context, pods []*v1.Pod) error {
+	// spd cache is kept updated by informer, and spd not cached is got from lister without remote request
+	if s.spdInformer != nil && s.spdInformer.HasSynced() {
+		return nil
+	}
+
+	keys := sets.NewString()
+	for _, pod := range pods {
+		spdName, err := s.getPodSPDNameFunc(pod)
+		if err != nil {
+			klog.Warningf("[spd-manager] get spd name of pod %s/%s failed: %v, skip it",
+				pod.GetNamespace(), pod.GetName(), err)
+			continue
+		}
+		keys.Insert(native.GenerateNamespaceNameKey(pod.GetNamespace(), spdName))
+	}
+	if keys.Len() == 0 {
+		return nil
+	}
+
+	currentCNC, err := s.cncFetcher.GetCNC(ctx)
+	if err != nil {
+		_ = s.emitter.StoreInt64(metricsNameGetCNCTargetConfigFailed, 1, metrics.MetricTypeNameCount)
+		return fmt.Errorf("prefetch spd failed to get cnc: %v", err)
+	}
+
+	targetConfigs := make(map[string]configapis.TargetConfig, len(currentCNC.Status.ServiceProfileConfigList))
+	for _, target := range currentCNC.Status.ServiceProfileConfigList {
+		targetConfigs[native.GenerateNamespaceNameKey(target.ConfigNamespace, target.ConfigName)] = target
+	}
+
+	keyList := keys.List()
+	var (
+		errMux  sync.Mutex
+		errList []error
+	)
+	prefetch := func(i int) {
+		key := keyList[i]
+		target, found := targetConfigs[key]
+		if !found {
+			namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+			target = configapis.TargetConfig{ConfigNamespace: namespace, ConfigName: name}
+		}
+
+		if s.isSPDNegativelyCached(key, &target, found) {
+			return
+		}
+
+		if err := s.updateSPDCacheIfNeed(ctx, s.spdCache.GetSPD(key), &target); err != nil {
+			_ = s.emitter.StoreInt64(metricsNameUpdateCacheFailed, 1, metrics.MetricTypeNameCount)
+			errMux.Lock()
+			errList = append(errList, fmt.Errorf("prefetch spd %s failed: %v", key, err))
+			errMux.Unlock()
+		}
+	}
+	workqueue.ParallelizeUntil(ctx, defaultPrefetchWorkers, len(keyList), prefetch)
+
+	return utilerrors.NewAggregate(errList)
+}
+
+// DumpCache returns snapshots of all spd in cache for debugging, and it may be large
+func (s *spdManager) DumpCache() []SPDCacheEntry {
+	return s.spdCache.Dump()
+}
+
 // SetGetPodSPDNameFunc set get spd name function to override default getPodSPDNameFunc before started
 func (s *spdManager) SetGetPodSPDNameFunc(f GetPodSPDNameFunc) {
 	if s.started.Load() {
@@ -116,6 +342,38 @@ func (s *spdManager) SetGetPodSPDNameFunc(f GetPodSPDNameFunc) {
 	}
 
 	s.getPodSPDNameFunc = f
+	if !s.podSPDNamesFuncSet {
+		s.getPodSPDNamesFunc = func(pod *v1.Pod) ([]string, error) {
+			spdName, err := f(pod)
+			if err != nil {
+				return nil, err
+			}
+			return []string{spdName}, nil
+		}
+	}
+}
+
+// SetGetPodSPDNamesFunc set get spd names function to override default getPodSPDNamesFunc before started,
+// and the returned names are in descending order of precedence. if only SetGetPodSPDNameFunc is called,
+// getPodSPDNamesFunc wraps the single name returned by it.
+func (s *spdManager) SetGetPodSPDNamesFunc(f GetPodSPDNamesFunc) {
+	if s.started.Load() {
+		klog.Warningf("spd manager has already started, not allowed to set implementations")
+		return
+	}
+
+	s.getPodSPDNamesFunc = f
+	s.podSPDNamesFuncSet = true
+}
+
+// SetBaselineSPDFunc set baseline spd function to provide fallback spd before started
+func (s *spdManager) SetBaselineSPDFunc(f BaselineSPDFunc) {
+	if s.started.Load() {
+		klog.Warningf("spd manager has already started, not allowed to set implementations")
+		return
+	}
+
+	s.baselineSPDFunc = f
 }
 
 func (s *spdManager) Run(ctx context.Context) {
@@ -124,16 +382,154 @@ func (s *spdManager) Run(ctx context.Context) {
 	}
 
 	s.spdCache.Run(ctx)
+	if s.spdInformerFactory != nil {
+		s.spdInformerFactory.Start(ctx.Done())
+	}
+	if s.serviceProfileReconcilePeriod > 0 {
+		go wait.UntilWithContext(ctx, s.reconcileSPDCache, s.serviceProfileReconcilePeriod)
+	}
+	go wait.UntilWithContext(ctx, s.emitCacheMetrics, defaultEmitCacheMetricsPeriod)
 	<-ctx.Done()
 }
 
+// emitCacheMetrics emits the number of spd held in spd cache for each namespace, and zero is
+// emitted for namespaces reported last time but without any cached spd now to clear the gauge
+func (s *spdManager) emitCacheMetrics(_ context.Context) {
+	countByNamespace := make(map[string]int64)
+	for _, key := range s.spdCache.ListSPDKeys() {
+		namespace, _, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			klog.Errorf("[spd-manager] split spd key %s failed: %v", key, err)
+			continue
+		}
+		countByNamespace[namespace]++
+	}
+
+	for namespace := range s.cachedSPDNamespaces {
+		if _, ok := countByNamespace[namespace]; !ok {
+			countByNamespace[namespace] = 0
+		}
+	}
+
+	cachedSPDNamespaces := sets.NewString()
+	for namespace, count := range countByNamespace {
+		_ = s.emitter.StoreInt64(metricsNameCachedSPDCount, count, metrics.MetricTypeNameRaw,
+			metrics.MetricTag{Key: "spdNamespace", Val: namespace})
+		if count > 0 {
+			cachedSPDNamespaces.Insert(namespace)
+		}
+	}
+	s.cachedSPDNamespaces = cachedSPDNamespaces
+}
+
+// reconcileSPDCache keeps spd cache aligned with cnc target list, it deletes cached spd
+// not in the target list any more, and prefetches spd newly appeared if prefetch is enabled.
+// it's skipped if the target list is empty, since it may be not populated yet
+func (s *spdManager) reconcileSPDCache(ctx context.Context) {
+	currentCNC, err := s.cncFetcher.GetCNC(ctx)
+	if err != nil {
+		klog.Errorf("[spd-manager] reconcile spd cache failed to get cnc: %v", err)
+		_ = s.emitter.StoreInt64(metricsNameGetCNCTargetConfigFailed, 1, metrics.MetricTypeNameCount)
+		return
+	}
+
+	if len(currentCNC.Status.ServiceProfileConfigList) == 0 {
+		klog.Infof("[spd-manager] skip reconciling spd cache: cnc target list is empty")
+		return
+	}
+
+	targetConfigs := make(map[string]configapis.TargetConfig, len(currentCNC.Status.ServiceProfileConfigList))
+	for _, target := range currentCNC.Status.ServiceProfileConfigList {
+		targetConfigs[native.GenerateNamespaceNameKey(target.ConfigNamespace, target.ConfigName)] = target
+	}
+
+	cachedKeys := sets.NewString(s.spdCache.ListSPDKeys()...)
+	orphanDeleted := 0
+	for _, key := range cachedKeys.List() {
+		if _, ok := targetConfigs[key]; ok {
+			continue
+		}
+
+		// the timestamp of the last attempt to fetch is kept after deletion, so that
+		// the following GetSPD is still limited by ttl instead of fetching remote at once
+		lastFetchRemoteTime := s.spdCache.GetLastFetchRemoteTime(key)
+		if err := s.spdCache.DeleteSPD(key); err != nil {
+			klog.Errorf("[spd-manager] delete orphaned spd %s from cache failed: %v", key, err)
+			continue
+		}
+		s.spdCache.SetLastFetchRemoteTime(key, lastFetchRemoteTime)
+		klog.Infof("[spd-manager] orphaned spd %s has been deleted from cache", key)
+		orphanDeleted++
+	}
+
+	// prefetched spd not used by any pod will still be cleared by spd cache later
+	prefetched := 0
+	if s.serviceProfilePrefetch {
+		for key, target := range targetConfigs {
+			if cachedKeys.Has(key) {
+				continue
+			}
+
+			targetConfig := target
+			if err := s.updateSPDCacheIfNeed(ctx, nil, &targetConfig); err != nil {
+				klog.Errorf("[spd-manager] prefetch spd %s failed: %v", key, err)
+				_ = s.emitter.StoreInt64(metricsNameUpdateCacheFailed, 1, metrics.MetricTypeNameCount)
+				continue
+			}
+			prefetched++
+		}
+	}
+
+	_ = s.emitter.StoreInt64(metricsNameReconcileOrphanDeleted, int64(orphanDeleted), metrics.MetricTypeNameCount)
+	_ = s.emitter.StoreInt64(metricsNameReconcilePrefetched, int64(prefetched), metrics.MetricTypeNameCount)
+}
+
+// getSPDByNamespaceName gets spd from override file, cache or remote, and falls back
+// to baseline spd if all of them fail
 func (s *spdManager) getSPDByNamespaceName(ctx context.Context, namespace, name string) (*workloadapis.ServiceProfileDescriptor, error) {
+	spd, err := s.lookupSPD(ctx, namespace, name)
+	if err == nil || s.baselineSPDFunc == nil {
+		return spd, err
+	}
+
+	baselineSPD := s.baselineSPDFunc(namespace, name)
+	if baselineSPD == nil {
+		return nil, err
+	}
+
+	klog.Warningf("[spd-manager] get spd %s/%s failed: %v, use baseline spd instead", namespace, name, err)
+	_ = s.emitter.StoreInt64(metricsNameBaselineSPDUsed, 1, metrics.MetricTypeNameCount,
+		metrics.MetricTag{Key: "spdNamespace", Val: namespace},
+		metrics.MetricTag{Key: "spdName", Val: name})
+
+	// baseline spd may be shared across calls, so it must be copied before returned
+	return baselineSPD.DeepCopy(), nil
+}
+
+func (s *spdManager) lookupSPD(ctx context.Context, namespace, name string) (*workloadapis.ServiceProfileDescriptor, error) {
 	key := native.GenerateNamespaceNameKey(namespace, name)
 	baseTag := []metrics.MetricTag{
 		{Key: "spdNamespace", Val: namespace},
 		{Key: "spdName", Val: name},
 	}
 
+	// local override spd takes precedence over both cache and remote spd, and it's
+	// loaded every time to take effect without restart
+	overrideSPD, err := s.getOverrideSPD(namespace, name)
+	if err != nil {
+		klog.Errorf("[spd-manager] load override spd %s failed: %v, ignore it", key, err)
+		_ = s.emitter.StoreInt64(metricsNameLoadOverrideFailed, 1, metrics.MetricTypeNameCount, baseTag...)
+	} else if overrideSPD != nil {
+		klog.Infof("[spd-manager] spd %s is overridden by local file", key)
+		_ = s.emitter.StoreInt64(metricsNameSPDOverridden, 1, metrics.MetricTypeNameCount, baseTag...)
+		return overrideSPD, nil
+	}
+
+	// spd cache is kept updated by informer once it has synced, so there is no need to check cnc
+	if s.spdInformer != nil && s.spdInformer.HasSynced() {
+		return s.getSPDFromInformer(namespace, name, baseTag)
+	}
+
 	// first get spd origin spd from local cache
 	originSPD := s.spdCache.GetSPD(key)
 
@@ -149,8 +545,15 @@ func (s *spdManager) getSPDByNamespaceName(ctx context.Context, namespace, name
 		_ = s.emitter.StoreInt64(metricsNameGetCNCTargetConfigFailed, 1, metrics.MetricTypeNameCount, baseTag...)
 	}
 
+	// spd known not existing is negatively cached to avoid repeated remote requests,
+	// unless cnc target config shows that it exists now
+	if s.isSPDNegativelyCached(key, targetConfig, err == nil) {
+		_ = s.emitter.StoreInt64(metricsNameNegativeCacheHit, 1, metrics.MetricTypeNameCount, baseTag...)
+		return nil, fmt.Errorf("get spd cache for %s not found", key)
+	}
+
 	// try to update spd cache from remote if cache spd hash is not equal to target config hash,
-	// the rate of getting remote spd will be limited by spd ServiceProfileCacheTTL
+	// the rate of getting remote spd will be limited by spd ServiceProfileCacheTTL or its override in spd annotation
 	err = s.updateSPDCacheIfNeed(ctx, originSPD, targetConfig)
 	if err != nil {
 		klog.Errorf("[spd-manager] failed update spd cache from remote: %v, use local cache instead", err)
@@ -168,6 +571,107 @@ func (s *spdManager) getSPDByNamespaceName(ctx context.Context, namespace, name
 	return nil, fmt.Errorf("get spd cache for %s not found", key)
 }
 
+// getSPDFromInformer gets spd from local cache, and only falls back to informer
+// lister if it's not cached yet, which doesn't involve any remote request
+func (s *spdManager) getSPDFromInformer(namespace, name string, baseTag []metrics.MetricTag) (*workloadapis.ServiceProfileDescriptor, error) {
+	key := native.GenerateNamespaceNameKey(namespace, name)
+	if currentSPD := s.spdCache.GetSPD(key); currentSPD != nil {
+		_ = s.emitter.StoreInt64(metricsNameCacheHit, 1, metrics.MetricTypeNameCount,
+			metrics.MetricTag{Key: "spdNamespace", Val: namespace})
+		return currentSPD, nil
+	}
+
+	spd, err := s.spdLister.ServiceProfileDescriptors(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		_ = s.emitter.StoreInt64(metricsNameCacheNotFound, 1, metrics.MetricTypeNameCount, baseTag...)
+		return nil, fmt.Errorf("get spd cache for %s not found", key)
+	} else if err != nil {
+		return nil, fmt.Errorf("get spd %s from informer failed: %v", key, err)
+	}
+
+	if err = s.validateSPD(spd); err != nil {
+		return nil, fmt.Errorf("spd %s from informer is invalid: %v", key, err)
+	}
+
+	// spd from lister is shared by informer, so it must be copied before being modified by cache
+	spd = spd.DeepCopy()
+	if err = s.spdCache.SetSPD(key, spd); err != nil {
+		klog.Errorf("[spd-manager] set spd %s to cache failed: %v", key, err)
+		_ = s.emitter.StoreInt64(metricsNameUpdateCacheFailed, 1, metrics.MetricTypeNameCount, baseTag...)
+	}
+
+	return spd, nil
+}
+
+// onSPDAddOrUpdate updates spd cache on spd events, and only spd already cached is updated,
+// since spd not used by any pod will be loaded lazily on GetSPD
+func (s *spdManager) onSPDAddOrUpdate(obj interface{}) {
+	spd, ok := obj.(*workloadapis.ServiceProfileDescriptor)
+	if !ok {
+		klog.Errorf("[spd-manager] cannot convert obj to spd: %v", obj)
+		return
+	}
+
+	key := native.GenerateUniqObjectNameKey(spd)
+	if !s.spdCache.HasSPD(key) {
+		return
+	}
+
+	// the cached spd is kept if the updated one is invalid
+	if err := s.validateSPD(spd); err != nil {
+		klog.Errorf("[spd-manager] spd %s on event is invalid: %v", key, err)
+		return
+	}
+
+	// hash of spd is refreshed by cache, so the spd shared by informer must be copied
+	if err := s.spdCache.SetSPD(key, spd.DeepCopy()); err != nil {
+		klog.Errorf("[spd-manager] update spd %s cache on event failed: %v", key, err)
+		_ = s.emitter.StoreInt64(metricsNameUpdateCacheFailed, 1, metrics.MetricTypeNameCount)
+		return
+	}
+	klog.Infof("[spd-manager] spd %s cache has been updated on event", key)
+}
+
+func (s *spdManager) onSPDDelete(obj interface{}) {
+	spd, ok := obj.(*workloadapis.ServiceProfileDescriptor)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("[spd-manager] cannot convert obj to spd: %v", obj)
+			return
+		}
+		spd, ok = tombstone.Obj.(*workloadapis.ServiceProfileDescriptor)
+		if !ok {
+			klog.Errorf("[spd-manager] cannot convert tombstone obj to spd: %v", tombstone.Obj)
+			return
+		}
+	}
+
+	key := native.GenerateUniqObjectNameKey(spd)
+	if err := s.spdCache.DeleteSPD(key); err != nil {
+		klog.Errorf("[spd-manager] delete spd %s cache on event failed: %v", key, err)
+		return
+	}
+	klog.Infof("[spd-manager] spd %s cache has been deleted on event", key)
+}
+
+// isSPDNegativelyCached checks whether the spd is recorded not existing within negative cache ttl,
+// and the record is invalidated if the spd is found in cnc target config list
+func (s *spdManager) isSPDNegativelyCached(key string, targetConfig *configapis.TargetConfig, targetFound bool) bool {
+	notFoundTime := s.spdCache.GetSPDNotFoundTime(key)
+	if notFoundTime.IsZero() {
+		return false
+	}
+
+	if targetFound && targetConfig.Hash != "" {
+		klog.Infof("[spd-manager] spd %s appears in cnc target config, invalidate negative cache", key)
+		s.spdCache.ClearSPDNotFound(key)
+		return false
+	}
+
+	return notFoundTime.Add(s.serviceProfileNegativeCacheTTL).After(time.Now())
+}
+
 // getSPDTargetConfig get spd target config from cnc
 func (s *spdManager) getSPDTargetConfig(ctx context.Context, namespace, name string) (*configapis.TargetConfig, error) {
 	currentCNC, err := s.cncFetcher.GetCNC(ctx)
@@ -188,45 +692,173 @@ func (s *spdManager) getSPDTargetConfig(ctx context.Context, namespace, name str
 // re-get from APIServer if the previous is out-of date.
 func (s *spdManager) updateSPDCacheIfNeed(ctx context.Context, originSPD *workloadapis.ServiceProfileDescriptor,
 	targetConfig *configapis.TargetConfig) error {
-	s.mux.Lock()
-	defer s.mux.Unlock()
-
 	if originSPD == nil && targetConfig == nil {
 		return nil
 	}
 
+	// cache hit and remote fetch are only tagged with namespace to limit the cardinality
+	namespaceTag := metrics.MetricTag{Key: "spdNamespace", Val: targetConfig.ConfigNamespace}
+
 	now := time.Now()
 	if originSPD == nil || util.GetSPDHash(originSPD) != targetConfig.Hash {
 		key := native.GenerateNamespaceNameKey(targetConfig.ConfigNamespace, targetConfig.ConfigName)
-		if lastFetchRemoteTime := s.spdCache.GetLastFetchRemoteTime(key); lastFetchRemoteTime.Add(s.ServiceProfileCacheTTL).After(time.Now()) {
+		if !s.acquireRemoteFetch(key, now) {
+			if originSPD != nil {
+				_ = s.emitter.StoreInt64(metricsNameCacheHit, 1, metrics.MetricTypeNameCount, namespaceTag)
+			}
 			return nil
-		} else {
-			// first update the timestamp of the last attempt to fetch the remote spd to
-			// avoid frequent requests to the api-server in some bad situations
-			s.spdCache.SetLastFetchRemoteTime(key, now)
 		}
 
 		klog.Infof("[spd-manager] spd %s targetConfig hash is changed from %s to %s", key, util.GetSPDHash(originSPD), targetConfig.Hash)
-		spd, err := s.client.InternalClient.WorkloadV1alpha1().ServiceProfileDescriptors(targetConfig.ConfigNamespace).
-			Get(ctx, targetConfig.ConfigName, metav1.GetOptions{ResourceVersion: "0"})
-		if err != nil && !errors.IsNotFound(err) {
-			return fmt.Errorf("get spd %s from remote failed: %v", key, err)
-		} else if err != nil {
-			err = s.spdCache.DeleteSPD(key)
-			if err != nil {
-				return fmt.Errorf("delete spd %s from cache failed: %v", key, err)
-			}
+		return s.fetchRemoteSPD(ctx, targetConfig.ConfigNamespace, targetConfig.ConfigName, now)
+	}
 
-			klog.Infof("[spd-manager] spd %s cache has been deleted", key)
-			return nil
-		}
+	_ = s.emitter.StoreInt64(metricsNameCacheHit, 1, metrics.MetricTypeNameCount, namespaceTag)
+	return nil
+}
 
-		err = s.spdCache.SetSPD(key, spd)
+// fetchRemoteSPD gets spd from APIServer and updates the cache with it, and the cached one
+// is deleted if the remote spd doesn't exist, while it's kept if the remote spd is invalid
+func (s *spdManager) fetchRemoteSPD(ctx context.Context, namespace, name string, now time.Time) error {
+	key := native.GenerateNamespaceNameKey(namespace, name)
+	_ = s.emitter.StoreInt64(metricsNameRemoteFetch, 1, metrics.MetricTypeNameCount,
+		metrics.MetricTag{Key: "spdNamespace", Val: namespace})
+	spd, err := s.client.InternalClient.WorkloadV1alpha1().ServiceProfileDescriptors(namespace).
+		Get(ctx, name, metav1.GetOptions{ResourceVersion: "0"})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("get spd %s from remote failed: %v", key, err)
+	} else if err != nil {
+		err = s.spdCache.DeleteSPD(key)
 		if err != nil {
-			return err
+			return fmt.Errorf("delete spd %s from cache failed: %v", key, err)
 		}
-		klog.Infof("[spd-manager] spd %s cache has been updated to %v", key, spd)
+
+		if s.serviceProfileNegativeCacheTTL > 0 {
+			s.spdCache.SetSPDNotFound(key, now)
+		}
+
+		klog.Infof("[spd-manager] spd %s cache has been deleted", key)
+		return nil
 	}
 
+	if err = s.validateSPD(spd); err != nil {
+		return fmt.Errorf("spd %s from remote is invalid: %v", key, err)
+	}
+
+	err = s.spdCache.SetSPD(key, spd)
+	if err != nil {
+		return err
+	}
+	klog.Infof("[spd-manager] spd %s cache has been updated to %v", key, spd)
+	return nil
+}
+
+// validateSPD validates spd before it's cached, and emits metric if it's invalid
+func (s *spdManager) validateSPD(spd *workloadapis.ServiceProfileDescriptor) error {
+	if err := validateSPD(spd); err != nil {
+		_ = s.emitter.StoreInt64(metricsNameInvalidSPD, 1, metrics.MetricTypeNameCount,
+			metrics.MetricTag{Key: "spdNamespace", Val: spd.GetNamespace()},
+			metrics.MetricTag{Key: "spdName", Val: spd.GetName()})
+		return err
+	}
 	return nil
 }
+
+// Refresh re-gets the spd from APIServer synchronously regardless of the cache ttl, so that
+// the modification of spd takes effect immediately. concurrent refreshes of the same spd
+// are skipped since the one in progress will get the latest spd.
+func (s *spdManager) Refresh(ctx context.Context, namespace, name string) error {
+	key := native.GenerateNamespaceNameKey(namespace, name)
+	now := time.Now()
+
+	s.mux.Lock()
+	if s.refreshingSPDs.Has(key) {
+		s.mux.Unlock()
+		klog.Infof("[spd-manager] spd %s is being refreshed, skip it", key)
+		return nil
+	}
+	s.refreshingSPDs.Insert(key)
+	// reset the timestamp of the last attempt as acquireRemoteFetch does, so that
+	// following GetSPD won't fetch it again within ttl
+	s.spdCache.SetLastFetchRemoteTime(key, now)
+	s.spdCache.SetCacheTTLJitter(key, s.generateCacheTTLJitter())
+	s.mux.Unlock()
+
+	defer func() {
+		s.mux.Lock()
+		s.refreshingSPDs.Delete(key)
+		s.mux.Unlock()
+	}()
+
+	return s.fetchRemoteSPD(ctx, namespace, name, now)
+}
+
+// acquireRemoteFetch checks whether the spd is allowed to be fetched from remote, since the rate of
+// getting remote spd is limited by ServiceProfileCacheTTL or its override in spd annotation. it's
+// serialized so that the same spd is fetched only once within ttl even if called concurrently,
+// while fetches of different spd are not blocked by each other
+func (s *spdManager) acquireRemoteFetch(key string, now time.Time) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	cacheTTL := s.ServiceProfileCacheTTL
+	if ttl := s.spdCache.GetCacheTTL(key); ttl > 0 {
+		cacheTTL = ttl
+	}
+
+	cacheTTL = time.Duration(float64(cacheTTL) * (1 + s.spdCache.GetCacheTTLJitter(key)))
+
+	if lastFetchRemoteTime := s.spdCache.GetLastFetchRemoteTime(key); lastFetchRemoteTime.Add(cacheTTL).After(now) {
+		return false
+	}
+
+	// first update the timestamp of the last attempt to fetch the remote spd to
+	// avoid frequent requests to the api-server in some bad situations
+	s.spdCache.SetLastFetchRemoteTime(key, now)
+	s.spdCache.SetCacheTTLJitter(key, s.generateCacheTTLJitter())
+	return true
+}
+
+// generateCacheTTLJitter returns a random jitter fraction in [-serviceProfileCacheTTLJitter, serviceProfileCacheTTLJitter),
+// so that spd fetched at the same time won't expire at the same time and refresh from remote together
+func (s *spdManager) generateCacheTTLJitter() float64 {
+	if s.serviceProfileCacheTTLJitter <= 0 {
+		return 0
+	}
+	return s.serviceProfileCacheTTLJitter * (2*s.randFloat64() - 1)
+}
+
+// getOverrideSPD loads spd from local override file <dir>/<namespace>/<name>.yaml,
+// and it returns nil if override is disabled or the file doesn't exist
+func (s *spdManager) getOverrideSPD(namespace, name string) (*workloadapis.ServiceProfileDescriptor, error) {
+	if s.serviceProfileOverrideDir == "" {
+		return nil, nil
+	}
+
+	// spd name comes from pod annotations, so it must be checked not to escape from override dir
+	for _, elem := range []string{namespace, name} {
+		if strings.ContainsAny(elem, `/\`) || strings.Contains(elem, "..") {
+			return nil, fmt.Errorf("invalid override file name %q", elem)
+		}
+	}
+
+	overrideFile := filepath.Join(s.serviceProfileOverrideDir, namespace, name+".yaml")
+	if !strings.HasPrefix(overrideFile, filepath.Clean(s.serviceProfileOverrideDir)+string(filepath.Separator)) {
+		return nil, fmt.Errorf("override file %v is out of %v", overrideFile, s.serviceProfileOverrideDir)
+	}
+
+	file, err := os.Open(overrideFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	spd := &workloadapis.ServiceProfileDescriptor{}
+	if err = yaml.NewYAMLOrJSONDecoder(file, overrideFileBufferSize).Decode(spd); err != nil {
+		return nil, fmt.Errorf("decode override file failed: %v", err)
+	}
+
+	return spd, nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+
+	workloadapis "github.com/kubewharf/katalyst-api/pkg/apis/workload/v1alpha1"
+	"github.com/kubewharf/katalyst-core/pkg/util"
+	"github.com/kubewharf/katalyst-core/pkg/util/native"
+)
+
+// ServiceProfileManagerStub is a stub implementation of ServiceProfileManager,
+// and SPDs is keyed by the namespace/name of spd
+type ServiceProfileManagerStub struct {
+	mutex sync.Mutex
+	SPDs  map[string]*workloadapis.ServiceProfileDescriptor
+}
+
+var _ ServiceProfileManager = &ServiceProfileManagerStub{}
+
+func (s *ServiceProfileManagerStub) GetSPD(_ context.Context, pod *v1.Pod) (*workloadapis.ServiceProfileDescriptor, error) {
+	spdName, err := util.GetPodSPDName(pod)
+	if err != nil {
+		return nil, fmt.Errorf("get pod spd name failed: %v", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	spd, ok := s.SPDs[native.GenerateNamespaceNameKey(pod.GetNamespace(), spdName)]
+	if !ok {
+		return nil, fmt.Errorf("spd %s/%s not found", pod.GetNamespace(), spdName)
+	}
+	return spd, nil
+}
+
+func (s *ServiceProfileManagerStub) GetSPDs(_ context.Context, pod *v1.Pod) ([]*workloadapis.ServiceProfileDescriptor, error) {
+	spdNames, err := util.GetPodSPDNames(pod)
+	if err != nil {
+		return nil, fmt.Errorf("get pod spd names failed: %v", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var spdList []*workloadapis.ServiceProfileDescriptor
+	for _, spdName := range spdNames {
+		if spd, ok := s.SPDs[native.GenerateNamespaceNameKey(pod.GetNamespace(), spdName)]; ok {
+			spdList = append(spdList, spd)
+		}
+	}
+	if len(spdList) == 0 {
+		return nil, fmt.Errorf("no spd found for pod %s/%s", pod.GetNamespace(), pod.GetName())
+	}
+	return spdList, nil
+}
+
+func (s *ServiceProfileManagerStub) GetSPDIndicator(ctx context.Context, pod *v1.Pod, indicatorName string) (float64, bool, error) {
+	spd, err := s.GetSPD(ctx, pod)
+	if err != nil {
+		return 0, false, err
+	}
+
+	value, ok := util.GetSPDIndicatorValue(spd, indicatorName)
+	return value, ok, nil
+}
+
+func (s *ServiceProfileManagerStub) PrefetchSPDs(_ context.Context, _ []*v1.Pod) error {
+	return nil
+}
+
+func (s *ServiceProfileManagerStub) Run(_ context.Context) {}
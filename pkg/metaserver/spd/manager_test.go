@@ -18,11 +18,17 @@ package spd
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
+	"go.uber.org/atomic"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -31,6 +37,7 @@ import (
 
 	"github.com/kubewharf/katalyst-api/pkg/apis/config/v1alpha1"
 	workloadapis "github.com/kubewharf/katalyst-api/pkg/apis/workload/v1alpha1"
+	externalfake "github.com/kubewharf/katalyst-api/pkg/client/clientset/versioned/fake"
 	"github.com/kubewharf/katalyst-api/pkg/consts"
 	katalyst_base "github.com/kubewharf/katalyst-core/cmd/base"
 	"github.com/kubewharf/katalyst-core/cmd/katalyst-agent/app/options"
@@ -40,6 +47,26 @@ import (
 	"github.com/kubewharf/katalyst-core/pkg/metrics"
 )
 
+// countingMetrics records the sum of int64 metrics keyed by metric name
+type countingMetrics struct {
+	metrics.DummyMetrics
+	mutex  sync.Mutex
+	counts map[string]int64
+}
+
+func (c *countingMetrics) StoreInt64(key string, val int64, _ metrics.MetricTypeName, _ ...metrics.MetricTag) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[key] += val
+	return nil
+}
+
+func (c *countingMetrics) getCount(key string) int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.counts[key]
+}
+
 func generateTestConfiguration(t *testing.T, nodeName string, checkpoint string) *pkgconfig.Configuration {
 	testConfiguration, err := options.NewOptions().Config()
 	require.NoError(t, err)
@@ -218,3 +245,1121 @@ func Test_spdManager_GetSPD(t *testing.T) {
 		})
 	}
 }
+
+func Test_spdManager_GetSPDWithOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	overrideDir, err := ioutil.TempDir("", "override")
+	require.NoError(t, err)
+	defer os.RemoveAll(overrideDir)
+
+	remoteSPD := &workloadapis.ServiceProfileDescriptor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "spd-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: "3c7e3ff3f218",
+			},
+		},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				consts.PodAnnotationSPDNameKey: "spd-1",
+			},
+		},
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	conf.ServiceProfileOverrideDir = overrideDir
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+		remoteSPD,
+		&v1alpha1.CustomNodeConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+			Status: v1alpha1.CustomNodeConfigStatus{
+				ServiceProfileConfigList: []v1alpha1.TargetConfig{
+					{
+						ConfigName:      "spd-1",
+						ConfigNamespace: "default",
+						Hash:            "3c7e3ff3f218",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	s, err := NewSPDManager(genericCtx.Client, metrics.DummyMetrics{}, cncFetcher, conf)
+	require.NoError(t, err)
+
+	ctx := context.TODO()
+
+	// no override file, get spd from remote
+	got, err := s.GetSPD(ctx, pod)
+	require.NoError(t, err)
+	require.Equal(t, remoteSPD, got)
+
+	// override file takes effect without restart
+	require.NoError(t, os.MkdirAll(filepath.Join(overrideDir, "default"), 0755))
+	overrideFile := filepath.Join(overrideDir, "default", "spd-1.yaml")
+	require.NoError(t, ioutil.WriteFile(overrideFile, []byte(`
+apiVersion: workload.katalyst.kubewharf.io/v1alpha1
+kind: ServiceProfileDescriptor
+metadata:
+  name: spd-1
+  namespace: default
+spec:
+  businessIndicator:
+  - name: RPCLatency
+`), 0644))
+	got, err = s.GetSPD(ctx, pod)
+	require.NoError(t, err)
+	require.Equal(t, "spd-1", got.Name)
+	require.Equal(t, 1, len(got.Spec.BusinessIndicator))
+	require.Equal(t, workloadapis.ServiceBusinessIndicatorNameRPCLatency, got.Spec.BusinessIndicator[0].Name)
+
+	// malformed override file is ignored
+	require.NoError(t, ioutil.WriteFile(overrideFile, []byte("{malformed"), 0644))
+	got, err = s.GetSPD(ctx, pod)
+	require.NoError(t, err)
+	require.Equal(t, remoteSPD, got)
+
+	// removing the override file falls back to the cached spd
+	require.NoError(t, os.Remove(overrideFile))
+	got, err = s.GetSPD(ctx, pod)
+	require.NoError(t, err)
+	require.Equal(t, remoteSPD, got)
+}
+
+func Test_spdManager_GetOverrideSPDWithInvalidName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rootDir, err := ioutil.TempDir("", "override")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	// the file out of override dir mustn't be loaded by spd names from pod annotations
+	overrideDir := filepath.Join(rootDir, "override")
+	require.NoError(t, os.MkdirAll(filepath.Join(overrideDir, "default"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(rootDir, "spd-1.yaml"), []byte(`
+apiVersion: workload.katalyst.kubewharf.io/v1alpha1
+kind: ServiceProfileDescriptor
+metadata:
+  name: spd-1
+  namespace: default
+`), 0644))
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	conf.ServiceProfileOverrideDir = overrideDir
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, nil)
+	require.NoError(t, err)
+
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	m, err := NewSPDManager(genericCtx.Client, metrics.DummyMetrics{}, cncFetcher, conf)
+	require.NoError(t, err)
+	s := m.(*spdManager)
+
+	for _, tt := range []struct {
+		namespace string
+		name      string
+	}{
+		{namespace: "default", name: "../../spd-1"},
+		{namespace: "..", name: "spd-1"},
+		{namespace: "default", name: "sub/spd-1"},
+		{namespace: "default", name: `sub\spd-1`},
+	} {
+		got, err := s.getOverrideSPD(tt.namespace, tt.name)
+		require.Error(t, err, "%s/%s", tt.namespace, tt.name)
+		require.Nil(t, got)
+	}
+}
+
+func Test_spdManager_reconcileSPDCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newSPD := func(name string) *workloadapis.ServiceProfileDescriptor {
+		return &workloadapis.ServiceProfileDescriptor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+		}
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	conf.ServiceProfilePrefetch = true
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+		newSPD("spd-1"),
+		newSPD("spd-2"),
+		newSPD("spd-3"),
+		&v1alpha1.CustomNodeConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+			Status: v1alpha1.CustomNodeConfigStatus{
+				ServiceProfileConfigList: []v1alpha1.TargetConfig{
+					{
+						ConfigName:      "spd-1",
+						ConfigNamespace: "default",
+					},
+					{
+						ConfigName:      "spd-2",
+						ConfigNamespace: "default",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	m, err := NewSPDManager(genericCtx.Client, metrics.DummyMetrics{}, cncFetcher, conf)
+	require.NoError(t, err)
+	s := m.(*spdManager)
+
+	// spd-1 is cached and still in cnc, spd-3 is cached but orphaned
+	require.NoError(t, s.spdCache.SetSPD("default/spd-1", newSPD("spd-1")))
+	require.NoError(t, s.spdCache.SetSPD("default/spd-3", newSPD("spd-3")))
+
+	ctx := context.TODO()
+	s.reconcileSPDCache(ctx)
+
+	keys := s.spdCache.ListSPDKeys()
+	sort.Strings(keys)
+	require.Equal(t, []string{"default/spd-1", "default/spd-2"}, keys)
+
+	// without prefetch, only orphaned spd is deleted
+	s.serviceProfilePrefetch = false
+	require.NoError(t, s.spdCache.DeleteSPD("default/spd-2"))
+	require.NoError(t, s.spdCache.SetSPD("default/spd-3", newSPD("spd-3")))
+	fetchTime := time.Now()
+	s.spdCache.SetLastFetchRemoteTime("default/spd-3", fetchTime)
+	s.reconcileSPDCache(ctx)
+
+	keys = s.spdCache.ListSPDKeys()
+	require.Equal(t, []string{"default/spd-1"}, keys)
+
+	// the timestamp of the last attempt to fetch orphaned spd is kept to limit remote requests
+	require.True(t, fetchTime.Equal(s.spdCache.GetLastFetchRemoteTime("default/spd-3")))
+	require.False(t, s.acquireRemoteFetch("default/spd-3", time.Now()))
+}
+
+func Test_spdManager_reconcileSPDCacheWithEmptyCNC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	spd := &workloadapis.ServiceProfileDescriptor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "spd-1",
+			Namespace: "default",
+		},
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+		spd,
+		&v1alpha1.CustomNodeConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	m, err := NewSPDManager(genericCtx.Client, metrics.DummyMetrics{}, cncFetcher, conf)
+	require.NoError(t, err)
+	s := m.(*spdManager)
+
+	// cached spd is kept if cnc target list is not populated yet
+	require.NoError(t, s.spdCache.SetSPD("default/spd-1", spd))
+	s.reconcileSPDCache(context.TODO())
+	require.Equal(t, []string{"default/spd-1"}, s.spdCache.ListSPDKeys())
+}
+
+func Test_spdManager_GetSPDWithInformer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	remoteSPD := &workloadapis.ServiceProfileDescriptor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "spd-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: "3c7e3ff3f218",
+			},
+		},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				consts.PodAnnotationSPDNameKey: "spd-1",
+			},
+		},
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	conf.ServiceProfileEnableInformer = true
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+		remoteSPD,
+		&v1alpha1.CustomNodeConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+			Status: v1alpha1.CustomNodeConfigStatus{
+				ServiceProfileConfigList: []v1alpha1.TargetConfig{
+					{
+						ConfigName:      "spd-1",
+						ConfigNamespace: "default",
+						Hash:            "3c7e3ff3f218",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	m, err := NewSPDManager(genericCtx.Client, metrics.DummyMetrics{}, cncFetcher, conf)
+	require.NoError(t, err)
+	s := m.(*spdManager)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go s.Run(ctx)
+	require.Eventually(t, s.spdInformer.HasSynced, 5*time.Second, 10*time.Millisecond)
+
+	got, err := s.GetSPD(ctx, pod)
+	require.NoError(t, err)
+	require.Equal(t, remoteSPD, got)
+
+	// spd cache is updated on events even if the hash in cnc target config is unchanged
+	updatedSPD := remoteSPD.DeepCopy()
+	updatedSPD.Labels = map[string]string{"updated": "true"}
+	_, err = genericCtx.Client.InternalClient.WorkloadV1alpha1().ServiceProfileDescriptors("default").
+		Update(ctx, updatedSPD, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		got, err := s.GetSPD(ctx, pod)
+		return err == nil && got.Labels["updated"] == "true"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// and deleted on events
+	err = genericCtx.Client.InternalClient.WorkloadV1alpha1().ServiceProfileDescriptors("default").
+		Delete(ctx, "spd-1", metav1.DeleteOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err := s.GetSPD(ctx, pod)
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_spdManager_GetSPDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newSPD := func(name string) *workloadapis.ServiceProfileDescriptor {
+		return &workloadapis.ServiceProfileDescriptor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: name,
+				},
+			},
+		}
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				consts.PodAnnotationSPDNameKey:          "spd-1",
+				pkgconsts.PodAnnotationSPDExtraNamesKey: "spd-2, spd-missing,spd-1",
+			},
+		},
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+		newSPD("spd-1"),
+		newSPD("spd-2"),
+		&v1alpha1.CustomNodeConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	s, err := NewSPDManager(genericCtx.Client, metrics.DummyMetrics{}, cncFetcher, conf)
+	require.NoError(t, err)
+
+	ctx := context.TODO()
+	got, err := s.GetSPDs(ctx, pod)
+	require.NoError(t, err)
+	require.Equal(t, []*workloadapis.ServiceProfileDescriptor{newSPD("spd-1"), newSPD("spd-2")}, got)
+
+	// the single GetSPD returns the one of highest priority
+	spd, err := s.GetSPD(ctx, pod)
+	require.NoError(t, err)
+	require.Equal(t, newSPD("spd-1"), spd)
+}
+
+func Test_spdManager_SetGetPodSPDNameFuncs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newSPD := func(name string) *workloadapis.ServiceProfileDescriptor {
+		return &workloadapis.ServiceProfileDescriptor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: name,
+				},
+			},
+		}
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-1",
+			Namespace: "default",
+			Labels: map[string]string{
+				"app":  "spd-1",
+				"team": "spd-2",
+			},
+		},
+	}
+
+	newManager := func() *spdManager {
+		conf := generateTestConfiguration(t, "node-1", dir)
+		genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+			newSPD("spd-1"),
+			newSPD("spd-2"),
+			&v1alpha1.CustomNodeConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node-1",
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+		s, err := NewSPDManager(genericCtx.Client, metrics.DummyMetrics{}, cncFetcher, conf)
+		require.NoError(t, err)
+		return s.(*spdManager)
+	}
+	ctx := context.TODO()
+
+	t.Run("single name func is wrapped", func(t *testing.T) {
+		s := newManager()
+		s.SetGetPodSPDNameFunc(func(pod *v1.Pod) (string, error) {
+			return pod.Labels["team"], nil
+		})
+
+		got, err := s.GetSPDs(ctx, pod)
+		require.NoError(t, err)
+		require.Equal(t, []*workloadapis.ServiceProfileDescriptor{newSPD("spd-2")}, got)
+	})
+
+	t.Run("names func takes precedence", func(t *testing.T) {
+		s := newManager()
+		s.SetGetPodSPDNamesFunc(func(pod *v1.Pod) ([]string, error) {
+			return []string{pod.Labels["team"], pod.Labels["app"]}, nil
+		})
+		// setting single name func afterwards doesn't override the names func
+		s.SetGetPodSPDNameFunc(func(pod *v1.Pod) (string, error) {
+			return pod.Labels["app"], nil
+		})
+
+		got, err := s.GetSPDs(ctx, pod)
+		require.NoError(t, err)
+		require.Equal(t, []*workloadapis.ServiceProfileDescriptor{newSPD("spd-2"), newSPD("spd-1")}, got)
+
+		spd, err := s.GetSPD(ctx, pod)
+		require.NoError(t, err)
+		require.Equal(t, newSPD("spd-1"), spd)
+	})
+
+	t.Run("not allowed after started", func(t *testing.T) {
+		s := newManager()
+		s.started.Store(true)
+		s.SetGetPodSPDNamesFunc(func(pod *v1.Pod) ([]string, error) {
+			return []string{pod.Labels["team"]}, nil
+		})
+		s.SetGetPodSPDNameFunc(func(pod *v1.Pod) (string, error) {
+			return pod.Labels["team"], nil
+		})
+		require.False(t, s.podSPDNamesFuncSet)
+
+		_, err := s.GetSPDs(ctx, pod)
+		require.Error(t, err)
+	})
+}
+
+func Test_spdManager_GetSPDWithNegativeCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				consts.PodAnnotationSPDNameKey: "spd-1",
+			},
+		},
+	}
+	cncObj := &v1alpha1.CustomNodeConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+		},
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	conf.ServiceProfileCacheTTL = 0
+	conf.CustomNodeConfigCacheTTL = 0
+	conf.ServiceProfileNegativeCacheTTL = time.Minute
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{cncObj})
+	require.NoError(t, err)
+
+	fakeClient := genericCtx.Client.InternalClient.(*externalfake.Clientset)
+	countSPDGets := func() int {
+		count := 0
+		for _, action := range fakeClient.Actions() {
+			if action.GetVerb() == "get" && action.GetResource().Resource == "serviceprofiledescriptors" {
+				count++
+			}
+		}
+		return count
+	}
+
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	s, err := NewSPDManager(genericCtx.Client, metrics.DummyMetrics{}, cncFetcher, conf)
+	require.NoError(t, err)
+
+	// remote spd is only requested once for spd-less pod
+	ctx := context.TODO()
+	for i := 0; i < 3; i++ {
+		_, err = s.GetSPD(ctx, pod)
+		require.Error(t, err)
+	}
+	require.Equal(t, 1, countSPDGets())
+
+	// negative cache is invalidated once the spd appears in cnc target config
+	spd := &workloadapis.ServiceProfileDescriptor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "spd-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: "3c7e3ff3f218",
+			},
+		},
+	}
+	_, err = fakeClient.WorkloadV1alpha1().ServiceProfileDescriptors("default").Create(ctx, spd, metav1.CreateOptions{})
+	require.NoError(t, err)
+	cncObj.Status.ServiceProfileConfigList = []v1alpha1.TargetConfig{
+		{
+			ConfigName:      "spd-1",
+			ConfigNamespace: "default",
+			Hash:            "3c7e3ff3f218",
+		},
+	}
+	_, err = fakeClient.ConfigV1alpha1().CustomNodeConfigs().Update(ctx, cncObj, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	got, err := s.GetSPD(ctx, pod)
+	require.NoError(t, err)
+	require.Equal(t, spd, got)
+}
+
+func Test_spdManager_GetSPDIndicator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	current := float32(0.8)
+	spd := &workloadapis.ServiceProfileDescriptor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "spd-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: "3c7e3ff3f218",
+			},
+		},
+		Spec: workloadapis.ServiceProfileDescriptorSpec{
+			SystemIndicator: []workloadapis.ServiceSystemIndicatorSpec{
+				{
+					Name: workloadapis.TargetIndicatorNameCPUSchedWait,
+					Indicators: []workloadapis.Indicator{
+						{IndicatorLevel: workloadapis.IndicatorLevelLowerBound, Value: 10},
+						{IndicatorLevel: workloadapis.IndicatorLevelUpperBound, Value: 20},
+					},
+				},
+			},
+		},
+		Status: workloadapis.ServiceProfileDescriptorStatus{
+			BusinessStatus: []workloadapis.ServiceBusinessIndicatorStatus{
+				{
+					Name:    workloadapis.ServiceBusinessIndicatorNameRPCLatency,
+					Current: &current,
+				},
+			},
+		},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				consts.PodAnnotationSPDNameKey: "spd-1",
+			},
+		},
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+		spd,
+		&v1alpha1.CustomNodeConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	s, err := NewSPDManager(genericCtx.Client, metrics.DummyMetrics{}, cncFetcher, conf)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		indicatorName string
+		wantValue     float64
+		wantOK        bool
+	}{
+		{
+			name:          "business indicator",
+			indicatorName: string(workloadapis.ServiceBusinessIndicatorNameRPCLatency),
+			wantValue:     float64(current),
+			wantOK:        true,
+		},
+		{
+			name:          "system indicator",
+			indicatorName: string(workloadapis.TargetIndicatorNameCPUSchedWait),
+			wantValue:     20,
+			wantOK:        true,
+		},
+		{
+			name:          "absent indicator",
+			indicatorName: string(workloadapis.TargetIndicatorNameCPI),
+			wantOK:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, ok, err := s.GetSPDIndicator(context.TODO(), pod, tt.indicatorName)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.wantValue, value)
+		})
+	}
+
+	_, _, err = s.GetSPDIndicator(context.TODO(), &v1.Pod{}, string(workloadapis.TargetIndicatorNameCPI))
+	require.Error(t, err)
+}
+
+func Test_spdManager_CacheMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				consts.PodAnnotationSPDNameKey: "spd-1",
+			},
+		},
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+		&workloadapis.ServiceProfileDescriptor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "spd-1",
+				Namespace: "default",
+				Annotations: map[string]string{
+					pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: "3c7e3ff3f218",
+				},
+			},
+		},
+		&v1alpha1.CustomNodeConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+			Status: v1alpha1.CustomNodeConfigStatus{
+				ServiceProfileConfigList: []v1alpha1.TargetConfig{
+					{
+						ConfigName:      "spd-1",
+						ConfigNamespace: "default",
+						Hash:            "3c7e3ff3f218",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	emitter := &countingMetrics{counts: map[string]int64{}}
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	m, err := NewSPDManager(genericCtx.Client, emitter, cncFetcher, conf)
+	require.NoError(t, err)
+	s := m.(*spdManager)
+
+	ctx := context.TODO()
+	// the first get fetches spd from remote, and the following ones hit cache
+	for i := 0; i < 3; i++ {
+		_, err = s.GetSPD(ctx, pod)
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(1), emitter.getCount(metricsNameRemoteFetch))
+	require.Equal(t, int64(2), emitter.getCount(metricsNameCacheHit))
+
+	s.emitCacheMetrics(ctx)
+	require.Equal(t, int64(1), emitter.getCount(metricsNameCachedSPDCount))
+
+	// the gauge of namespace without any cached spd is cleared
+	gauges := &gaugeMetrics{values: map[string]int64{}}
+	s.emitter = gauges
+	s.emitCacheMetrics(ctx)
+	require.Equal(t, map[string]int64{"default": 1}, gauges.values)
+	require.NoError(t, s.spdCache.DeleteSPD("default/spd-1"))
+	s.emitCacheMetrics(ctx)
+	require.Equal(t, map[string]int64{"default": 0}, gauges.values)
+	require.Empty(t, s.cachedSPDNamespaces)
+}
+
+// gaugeMetrics records the last value of cached spd count keyed by namespace
+type gaugeMetrics struct {
+	metrics.DummyMetrics
+	values map[string]int64
+}
+
+func (g *gaugeMetrics) StoreInt64(key string, val int64, _ metrics.MetricTypeName, tags ...metrics.MetricTag) error {
+	if key == metricsNameCachedSPDCount {
+		for _, tag := range tags {
+			if tag.Key == "spdNamespace" {
+				g.values[tag.Val] = val
+			}
+		}
+	}
+	return nil
+}
+
+func Test_spdManager_GetSPDWithBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				consts.PodAnnotationSPDNameKey: "spd-1",
+			},
+		},
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+		&v1alpha1.CustomNodeConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	emitter := &countingMetrics{counts: map[string]int64{}}
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	m, err := NewSPDManager(genericCtx.Client, emitter, cncFetcher, conf)
+	require.NoError(t, err)
+	s := m.(*spdManager)
+
+	ctx := context.TODO()
+	_, err = s.GetSPD(ctx, pod)
+	require.Error(t, err)
+
+	baselineSPD := &workloadapis.ServiceProfileDescriptor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "baseline",
+			Namespace: "default",
+		},
+	}
+	s.SetBaselineSPDFunc(func(namespace, name string) *workloadapis.ServiceProfileDescriptor {
+		return baselineSPD
+	})
+
+	got, err := s.GetSPD(ctx, pod)
+	require.NoError(t, err)
+	require.Equal(t, baselineSPD, got)
+	// baseline spd is copied, so modification by callers doesn't affect it
+	require.NotSame(t, baselineSPD, got)
+	require.Equal(t, int64(1), emitter.getCount(metricsNameBaselineSPDUsed))
+}
+
+// countingCNCFetcher counts the times of getting cnc
+type countingCNCFetcher struct {
+	cnc.CNCFetcher
+	count atomic.Int64
+}
+
+func (f *countingCNCFetcher) GetCNC(ctx context.Context) (*v1alpha1.CustomNodeConfig, error) {
+	f.count.Inc()
+	return f.CNCFetcher.GetCNC(ctx)
+}
+
+func Test_spdManager_PrefetchSPDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newPod := func(name, spdName string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					consts.PodAnnotationSPDNameKey: spdName,
+				},
+			},
+		}
+	}
+	// pods overlap on spd-1
+	pods := []*v1.Pod{newPod("pod-1", "spd-1"), newPod("pod-2", "spd-1"), newPod("pod-3", "spd-2")}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+		&workloadapis.ServiceProfileDescriptor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "spd-1",
+				Namespace: "default",
+				Annotations: map[string]string{
+					pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: "3c7e3ff3f218",
+				},
+			},
+		},
+		&workloadapis.ServiceProfileDescriptor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "spd-2",
+				Namespace: "default",
+				Annotations: map[string]string{
+					pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: "4d8f4004f329",
+				},
+			},
+		},
+		&v1alpha1.CustomNodeConfig{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node-1",
+			},
+			Status: v1alpha1.CustomNodeConfigStatus{
+				ServiceProfileConfigList: []v1alpha1.TargetConfig{
+					{
+						ConfigName:      "spd-1",
+						ConfigNamespace: "default",
+						Hash:            "3c7e3ff3f218",
+					},
+					{
+						ConfigName:      "spd-2",
+						ConfigNamespace: "default",
+						Hash:            "4d8f4004f329",
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	emitter := &countingMetrics{counts: map[string]int64{}}
+	cncFetcher := &countingCNCFetcher{
+		CNCFetcher: cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs()),
+	}
+	m, err := NewSPDManager(genericCtx.Client, emitter, cncFetcher, conf)
+	require.NoError(t, err)
+	s := m.(*spdManager)
+
+	ctx := context.TODO()
+	require.NoError(t, s.PrefetchSPDs(ctx, pods))
+	require.Equal(t, int64(1), cncFetcher.count.Load())
+	require.Equal(t, int64(2), emitter.getCount(metricsNameRemoteFetch))
+	require.ElementsMatch(t, []string{"default/spd-1", "default/spd-2"}, s.spdCache.ListSPDKeys())
+
+	// prefetch again within cache ttl doesn't fetch remote spd
+	require.NoError(t, s.PrefetchSPDs(ctx, pods))
+	require.Equal(t, int64(2), emitter.getCount(metricsNameRemoteFetch))
+
+	// following get hits cache without fetching remote spd
+	for _, pod := range pods {
+		_, err = s.GetSPD(ctx, pod)
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(2), emitter.getCount(metricsNameRemoteFetch))
+}
+
+func Test_spdManager_CacheTTLJitter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	conf.ServiceProfileCacheTTL = 10 * time.Second
+	conf.ServiceProfileCacheTTLJitter = 0.1
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, nil)
+	require.NoError(t, err)
+
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	m, err := NewSPDManager(genericCtx.Client, metrics.DummyMetrics{}, cncFetcher, conf)
+	require.NoError(t, err)
+	s := m.(*spdManager)
+	s.randFloat64 = rand.New(rand.NewSource(1)).Float64
+
+	// all spd are fetched at the same time, e.g. populating cache at startup
+	start := time.Now()
+	keys := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("default/spd-%d", i)
+		require.True(t, s.acquireRemoteFetch(key, start))
+		keys = append(keys, key)
+	}
+
+	// find the first time each spd is allowed to refresh from remote
+	var refreshTimes []time.Duration
+	for elapsed := time.Duration(0); elapsed <= 12*time.Second && len(keys) > 0; elapsed += 100 * time.Millisecond {
+		var remaining []string
+		for _, key := range keys {
+			if s.acquireRemoteFetch(key, start.Add(elapsed)) {
+				refreshTimes = append(refreshTimes, elapsed)
+			} else {
+				remaining = append(remaining, key)
+			}
+		}
+		keys = remaining
+	}
+	require.Empty(t, keys)
+
+	// refresh times spread across the jitter window instead of all at the ttl
+	sort.Slice(refreshTimes, func(i, j int) bool { return refreshTimes[i] < refreshTimes[j] })
+	require.GreaterOrEqual(t, refreshTimes[0], 9*time.Second)
+	require.LessOrEqual(t, refreshTimes[len(refreshTimes)-1], 11*time.Second)
+	require.Less(t, refreshTimes[0], 9500*time.Millisecond)
+	require.Greater(t, refreshTimes[len(refreshTimes)-1], 10500*time.Millisecond)
+}
+
+func Test_spdManager_Refresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newSPD := func(hash string) *workloadapis.ServiceProfileDescriptor {
+		return &workloadapis.ServiceProfileDescriptor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "spd-1",
+				Namespace: "default",
+				Annotations: map[string]string{
+					pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: hash,
+				},
+			},
+		}
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	conf.ServiceProfileCacheTTL = time.Hour
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{newSPD("hash-1")})
+	require.NoError(t, err)
+
+	emitter := &countingMetrics{counts: map[string]int64{}}
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	m, err := NewSPDManagerWithStore(genericCtx.Client, emitter, cncFetcher, conf, NewMemorySPDCache(time.Minute))
+	require.NoError(t, err)
+	s := m.(*spdManager)
+
+	ctx := context.TODO()
+	key := "default/spd-1"
+	require.NoError(t, s.updateSPDCacheIfNeed(ctx, nil, &v1alpha1.TargetConfig{
+		ConfigNamespace: "default", ConfigName: "spd-1", Hash: "hash-1"}))
+	require.Equal(t, int64(1), emitter.getCount(metricsNameRemoteFetch))
+
+	// the modified spd is not fetched within ttl
+	_, err = genericCtx.Client.InternalClient.WorkloadV1alpha1().ServiceProfileDescriptors("default").
+		Update(ctx, newSPD("hash-2"), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, s.updateSPDCacheIfNeed(ctx, s.spdCache.GetSPD(key), &v1alpha1.TargetConfig{
+		ConfigNamespace: "default", ConfigName: "spd-1", Hash: "hash-2"}))
+	require.Equal(t, int64(1), emitter.getCount(metricsNameRemoteFetch))
+	require.Equal(t, "hash-1", s.spdCache.GetSPD(key).Annotations[pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash])
+
+	// refresh bypasses the ttl gate
+	require.NoError(t, s.Refresh(ctx, "default", "spd-1"))
+	require.Equal(t, int64(2), emitter.getCount(metricsNameRemoteFetch))
+	require.Equal(t, "hash-2", s.spdCache.GetSPD(key).Annotations[pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash])
+
+	// spd is only kept in the memory store without checkpoints
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	// concurrent refresh of the same spd is skipped
+	s.refreshingSPDs.Insert(key)
+	require.NoError(t, s.Refresh(ctx, "default", "spd-1"))
+	require.Equal(t, int64(2), emitter.getCount(metricsNameRemoteFetch))
+	s.refreshingSPDs.Delete(key)
+
+	// the cache is cleared if the spd is deleted remotely
+	require.NoError(t, genericCtx.Client.InternalClient.WorkloadV1alpha1().ServiceProfileDescriptors("default").
+		Delete(ctx, "spd-1", metav1.DeleteOptions{}))
+	require.NoError(t, s.Refresh(ctx, "default", "spd-1"))
+	require.Nil(t, s.spdCache.GetSPD(key))
+}
+
+func Test_spdManager_RejectInvalidSPD(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newSPD := func(hash string, ratio float32) *workloadapis.ServiceProfileDescriptor {
+		return &workloadapis.ServiceProfileDescriptor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "spd-1",
+				Namespace: "default",
+				Annotations: map[string]string{
+					pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: hash,
+				},
+			},
+			Spec: workloadapis.ServiceProfileDescriptorSpec{
+				BaselineRatio: &ratio,
+			},
+		}
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{newSPD("hash-1", 0.5)})
+	require.NoError(t, err)
+
+	emitter := &countingMetrics{counts: map[string]int64{}}
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	m, err := NewSPDManagerWithStore(genericCtx.Client, emitter, cncFetcher, conf, NewMemorySPDCache(time.Minute))
+	require.NoError(t, err)
+	s := m.(*spdManager)
+
+	ctx := context.TODO()
+	key := "default/spd-1"
+	require.NoError(t, s.Refresh(ctx, "default", "spd-1"))
+	require.Equal(t, "hash-1", s.spdCache.GetSPD(key).Annotations[pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash])
+
+	// the invalid spd is rejected and the cached one is kept
+	_, err = genericCtx.Client.InternalClient.WorkloadV1alpha1().ServiceProfileDescriptors("default").
+		Update(ctx, newSPD("hash-2", 1.5), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Error(t, s.Refresh(ctx, "default", "spd-1"))
+	require.Equal(t, int64(1), emitter.getCount(metricsNameInvalidSPD))
+	require.Equal(t, "hash-1", s.spdCache.GetSPD(key).Annotations[pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash])
+}
+
+func Test_spdManager_RejectInvalidSPDWithInformer(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newSPD := func(name, hash string, ratio float32) *workloadapis.ServiceProfileDescriptor {
+		return &workloadapis.ServiceProfileDescriptor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: hash,
+				},
+			},
+			Spec: workloadapis.ServiceProfileDescriptorSpec{
+				BaselineRatio: &ratio,
+			},
+		}
+	}
+	newPod := func(spdName string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod-" + spdName,
+				Namespace: "default",
+				Annotations: map[string]string{
+					consts.PodAnnotationSPDNameKey: spdName,
+				},
+			},
+		}
+	}
+
+	conf := generateTestConfiguration(t, "node-1", dir)
+	conf.ServiceProfileEnableInformer = true
+	genericCtx, err := katalyst_base.GenerateFakeGenericContext(nil, []runtime.Object{
+		newSPD("spd-1", "hash-1", 0.5),
+		newSPD("spd-2", "hash-1", 1.5),
+	})
+	require.NoError(t, err)
+
+	emitter := &countingMetrics{counts: map[string]int64{}}
+	cncFetcher := cnc.NewCachedCNCFetcher(conf.NodeName, conf.CustomNodeConfigCacheTTL, genericCtx.Client.InternalClient.ConfigV1alpha1().CustomNodeConfigs())
+	m, err := NewSPDManagerWithStore(genericCtx.Client, emitter, cncFetcher, conf, NewMemorySPDCache(time.Minute))
+	require.NoError(t, err)
+	s := m.(*spdManager)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	go s.Run(ctx)
+	require.Eventually(t, s.spdInformer.HasSynced, 5*time.Second, 10*time.Millisecond)
+
+	// the invalid spd from informer lister is not cached
+	_, err = s.GetSPD(ctx, newPod("spd-2"))
+	require.Error(t, err)
+	require.Equal(t, int64(1), emitter.getCount(metricsNameInvalidSPD))
+	require.False(t, s.spdCache.HasSPD("default/spd-2"))
+
+	got, err := s.GetSPD(ctx, newPod("spd-1"))
+	require.NoError(t, err)
+	require.Equal(t, "hash-1", got.Annotations[pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash])
+
+	// the invalid spd on event is rejected and the cached one is kept
+	_, err = genericCtx.Client.InternalClient.WorkloadV1alpha1().ServiceProfileDescriptors("default").
+		Update(ctx, newSPD("spd-1", "hash-2", 1.5), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return emitter.getCount(metricsNameInvalidSPD) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	got, err = s.GetSPD(ctx, newPod("spd-1"))
+	require.NoError(t, err)
+	require.Equal(t, "hash-1", got.Annotations[pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash])
+}
@@ -19,6 +19,7 @@ package spd
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -41,25 +42,90 @@ type spdInfo struct {
 	// get spd, which is used for gc spd cache
 	lastGetTime time.Time
 
+	// cacheTTL is the cache ttl override parsed from spd annotation, and
+	// the global one is used if it's zero
+	cacheTTL time.Duration
+	// cacheTTLJitter is the random fraction applied to the cache ttl since the last
+	// attempt to fetch the remote spd, so that spd cached together expire at different time
+	cacheTTLJitter float64
+
 	// spd is target spd
 	spd *workloadapis.ServiceProfileDescriptor
 }
 
+// SPDCacheEntry is a snapshot of a cached spd for debugging
+type SPDCacheEntry struct {
+	// Key is the namespace/name key of spd in cache
+	Key       string `json:"key"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Hash is the config hash of cached spd, which is compared with the one in cnc target config
+	Hash string `json:"hash"`
+	// LastFetchRemoteTime is the timestamp of the last attempt to fetch the remote spd
+	LastFetchRemoteTime time.Time `json:"lastFetchRemoteTime"`
+	// SPD is a deep copy of the cached spd
+	SPD *workloadapis.ServiceProfileDescriptor `json:"spd"`
+}
+
+// SPDStore is the backing store of spd manager, which keeps target spd and the states
+// to limit the rate of getting remote spd, keyed by namespace/name of spd
+type SPDStore interface {
+	// GetSPD gets target spd, and the spd is regarded as used recently
+	GetSPD(key string) *workloadapis.ServiceProfileDescriptor
+	// SetSPD sets target spd, and the config hash of spd is calculated if absent
+	SetSPD(key string, spd *workloadapis.ServiceProfileDescriptor) error
+	// DeleteSPD deletes target spd
+	DeleteSPD(key string) error
+	// HasSPD checks whether target spd is stored without regarding it as used
+	HasSPD(key string) bool
+	// ListSPDKeys lists keys of all stored spd
+	ListSPDKeys() []string
+	// Dump returns snapshots of all stored spd for debugging
+	Dump() []SPDCacheEntry
+
+	// SetLastFetchRemoteTime sets the timestamp of the last attempt to fetch the remote spd
+	SetLastFetchRemoteTime(key string, t time.Time)
+	// GetLastFetchRemoteTime gets the timestamp of the last attempt to fetch the remote spd
+	GetLastFetchRemoteTime(key string) time.Time
+	// GetCacheTTL gets the cache ttl override of target spd, and zero means no override
+	GetCacheTTL(key string) time.Duration
+	// SetCacheTTLJitter sets the jitter fraction applied to the cache ttl of target spd
+	SetCacheTTLJitter(key string, jitter float64)
+	// GetCacheTTLJitter gets the jitter fraction applied to the cache ttl of target spd
+	GetCacheTTLJitter(key string) float64
+
+	// SetSPDNotFound records that the remote spd doesn't exist
+	SetSPDNotFound(key string, t time.Time)
+	// GetSPDNotFoundTime gets the timestamp when the remote spd was found not existing
+	GetSPDNotFoundTime(key string) time.Time
+	// ClearSPDNotFound clears the record that the remote spd doesn't exist
+	ClearSPDNotFound(key string)
+
+	// Run starts the background loops of store, e.g. clearing unused spd
+	Run(ctx context.Context)
+}
+
 // Cache is spd cache stores current
 type Cache struct {
 	sync.RWMutex
 
 	expiredTime time.Duration
 
+	// manager persists spd into checkpoints, and spd is only kept in memory if it's nil
 	manager checkpointmanager.CheckpointManager
 	spdInfo map[string]*spdInfo
+
+	// notFoundTime records the timestamp when remote spd was found not existing,
+	// which is used as a negative cache to avoid repeated remote requests
+	notFoundTime map[string]time.Time
 }
 
 func NewSPDCache(manager checkpointmanager.CheckpointManager, expiredTime time.Duration) *Cache {
 	cache := &Cache{
-		spdInfo:     map[string]*spdInfo{},
-		manager:     manager,
-		expiredTime: expiredTime,
+		spdInfo:      map[string]*spdInfo{},
+		notFoundTime: map[string]time.Time{},
+		manager:      manager,
+		expiredTime:  expiredTime,
 	}
 
 	err := cache.restore()
@@ -71,6 +137,18 @@ func NewSPDCache(manager checkpointmanager.CheckpointManager, expiredTime time.D
 	return cache
 }
 
+// NewMemorySPDCache creates a spd cache without checkpoints, so nothing is restored
+// at startup, which is mainly used for tests
+func NewMemorySPDCache(expiredTime time.Duration) *Cache {
+	return &Cache{
+		spdInfo:      map[string]*spdInfo{},
+		notFoundTime: map[string]time.Time{},
+		expiredTime:  expiredTime,
+	}
+}
+
+var _ SPDStore = &Cache{}
+
 // SetLastFetchRemoteTime set last fetch remote spd timestamp
 func (s *Cache) SetLastFetchRemoteTime(key string, t time.Time) {
 	s.Lock()
@@ -108,15 +186,78 @@ func (s *Cache) SetSPD(key string, spd *workloadapis.ServiceProfileDescriptor) e
 	}
 
 	s.initSPDInfoWithoutLock(key)
-	err := checkpoint.WriteSPD(s.manager, spd)
+	err := s.writeCheckpoint(spd)
 	if err != nil {
 		return err
 	}
 
 	s.spdInfo[key].spd = spd
+	s.spdInfo[key].cacheTTL = getSPDCacheTTL(key, spd)
+	delete(s.notFoundTime, key)
 	return nil
 }
 
+// GetCacheTTL gets the cache ttl override of target spd, and it returns zero
+// if the spd is not cached or has no valid override
+func (s *Cache) GetCacheTTL(key string) time.Duration {
+	s.RLock()
+	defer s.RUnlock()
+
+	info, ok := s.spdInfo[key]
+	if ok && info != nil {
+		return info.cacheTTL
+	}
+
+	return 0
+}
+
+// SetCacheTTLJitter sets the jitter fraction applied to the cache ttl of target spd
+func (s *Cache) SetCacheTTLJitter(key string, jitter float64) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.initSPDInfoWithoutLock(key)
+	s.spdInfo[key].cacheTTLJitter = jitter
+}
+
+// GetCacheTTLJitter gets the jitter fraction applied to the cache ttl of target spd
+func (s *Cache) GetCacheTTLJitter(key string) float64 {
+	s.RLock()
+	defer s.RUnlock()
+
+	info, ok := s.spdInfo[key]
+	if ok && info != nil {
+		return info.cacheTTLJitter
+	}
+
+	return 0
+}
+
+// SetSPDNotFound records that the remote spd doesn't exist
+func (s *Cache) SetSPDNotFound(key string, t time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.notFoundTime[key] = t
+}
+
+// GetSPDNotFoundTime gets the timestamp when the remote spd was found not existing,
+// and it returns zero time if it's not recorded
+func (s *Cache) GetSPDNotFoundTime(key string) time.Time {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.notFoundTime[key]
+}
+
+// ClearSPDNotFound clears the record that the remote spd doesn't exist
+func (s *Cache) ClearSPDNotFound(key string) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.notFoundTime, key)
+}
+
 // DeleteSPD delete target spd by namespace/name key
 func (s *Cache) DeleteSPD(key string) error {
 	s.Lock()
@@ -124,7 +265,7 @@ func (s *Cache) DeleteSPD(key string) error {
 
 	info, ok := s.spdInfo[key]
 	if ok && info != nil {
-		err := checkpoint.DeleteSPD(s.manager, info.spd)
+		err := s.deleteCheckpoint(info.spd)
 		if err != nil {
 			return err
 		}
@@ -151,6 +292,58 @@ func (s *Cache) GetSPD(key string) *workloadapis.ServiceProfileDescriptor {
 	return nil
 }
 
+// HasSPD checks whether target spd is cached without updating its last get time
+func (s *Cache) HasSPD(key string) bool {
+	s.RLock()
+	defer s.RUnlock()
+
+	info, ok := s.spdInfo[key]
+	return ok && info != nil && info.spd != nil
+}
+
+// ListSPDKeys lists namespace/name keys of all cached spd
+func (s *Cache) ListSPDKeys() []string {
+	s.RLock()
+	defer s.RUnlock()
+
+	keys := make([]string, 0, len(s.spdInfo))
+	for key, info := range s.spdInfo {
+		if info != nil && info.spd != nil {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Dump returns snapshots of all cached spd sorted by key, and notice that it may be large
+// since each of them holds a deep copy of the whole spd
+func (s *Cache) Dump() []SPDCacheEntry {
+	s.RLock()
+	defer s.RUnlock()
+
+	entries := make([]SPDCacheEntry, 0, len(s.spdInfo))
+	for key, info := range s.spdInfo {
+		if info == nil || info.spd == nil {
+			continue
+		}
+
+		entries = append(entries, SPDCacheEntry{
+			Key:                 key,
+			Namespace:           info.spd.Namespace,
+			Name:                info.spd.Name,
+			Hash:                util.GetSPDHash(info.spd),
+			LastFetchRemoteTime: info.lastFetchRemoteTime,
+			SPD:                 info.spd.DeepCopy(),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
 // Run to clear local unused spd
 func (s *Cache) Run(ctx context.Context) {
 	go wait.UntilWithContext(ctx, s.clearUnusedSPDs, s.expiredTime)
@@ -161,6 +354,10 @@ func (s *Cache) restore() error {
 	s.Lock()
 	defer s.Unlock()
 
+	if s.manager == nil {
+		return nil
+	}
+
 	spdList, err := checkpoint.LoadSPDs(s.manager)
 	if err != nil {
 		return fmt.Errorf("restore spd failed: %v", err)
@@ -171,6 +368,7 @@ func (s *Cache) restore() error {
 		key := native.GenerateUniqObjectNameKey(spd)
 		s.initSPDInfoWithoutLock(key)
 		s.spdInfo[key].spd = spd
+		s.spdInfo[key].cacheTTL = getSPDCacheTTL(key, spd)
 		s.spdInfo[key].lastGetTime = now
 	}
 
@@ -183,9 +381,15 @@ func (s *Cache) clearUnusedSPDs(_ context.Context) {
 	defer s.Unlock()
 
 	now := time.Now()
+	for key, t := range s.notFoundTime {
+		if t.Add(s.expiredTime).Before(now) {
+			delete(s.notFoundTime, key)
+		}
+	}
+
 	for key, info := range s.spdInfo {
 		if info != nil && info.lastGetTime.Add(s.expiredTime).Before(now) {
-			err := checkpoint.DeleteSPD(s.manager, info.spd)
+			err := s.deleteCheckpoint(info.spd)
 			if err != nil {
 				klog.Errorf("clear unused spd %s failed: %v", key, err)
 				continue
@@ -195,9 +399,35 @@ func (s *Cache) clearUnusedSPDs(_ context.Context) {
 	}
 }
 
+func (s *Cache) writeCheckpoint(spd *workloadapis.ServiceProfileDescriptor) error {
+	if s.manager == nil {
+		return nil
+	}
+	return checkpoint.WriteSPD(s.manager, spd)
+}
+
+func (s *Cache) deleteCheckpoint(spd *workloadapis.ServiceProfileDescriptor) error {
+	if s.manager == nil {
+		return nil
+	}
+	return checkpoint.DeleteSPD(s.manager, spd)
+}
+
 func (s *Cache) initSPDInfoWithoutLock(key string) {
 	info, ok := s.spdInfo[key]
 	if !ok || info == nil {
 		s.spdInfo[key] = &spdInfo{}
 	}
 }
+
+// getSPDCacheTTL parses the cache ttl override of spd, and invalid override is
+// ignored so that the spd itself can still be cached with the global ttl
+func getSPDCacheTTL(key string, spd *workloadapis.ServiceProfileDescriptor) time.Duration {
+	ttl, err := util.GetSPDCacheTTL(spd)
+	if err != nil {
+		klog.Warningf("[spd-manager] spd %s has invalid cache ttl override: %v, use the global one instead", key, err)
+		return 0
+	}
+
+	return ttl
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
+
+	workloadapis "github.com/kubewharf/katalyst-api/pkg/apis/workload/v1alpha1"
+	pkgconsts "github.com/kubewharf/katalyst-core/pkg/consts"
+)
+
+func TestCache_SetSPDWithCacheTTL(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantTTL     time.Duration
+	}{
+		{
+			name:    "override absent",
+			wantTTL: 0,
+		},
+		{
+			name: "override present",
+			annotations: map[string]string{
+				pkgconsts.ServiceProfileDescriptorAnnotationKeyCacheTTL: "30s",
+			},
+			wantTTL: 30 * time.Second,
+		},
+		{
+			name: "override invalid",
+			annotations: map[string]string{
+				pkgconsts.ServiceProfileDescriptorAnnotationKeyCacheTTL: "invalid",
+			},
+			wantTTL: 0,
+		},
+		{
+			name: "override not positive",
+			annotations: map[string]string{
+				pkgconsts.ServiceProfileDescriptorAnnotationKeyCacheTTL: "-1m",
+			},
+			wantTTL: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "checkpoint")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			manager, err := checkpointmanager.NewCheckpointManager(dir)
+			require.NoError(t, err)
+			c := NewSPDCache(manager, time.Minute)
+			require.NotNil(t, c)
+
+			spd := &workloadapis.ServiceProfileDescriptor{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "spd-1",
+					Namespace:   "default",
+					Annotations: tt.annotations,
+				},
+			}
+			// spd with invalid override is still cached with the global ttl
+			require.NoError(t, c.SetSPD("default/spd-1", spd))
+			require.True(t, c.HasSPD("default/spd-1"))
+			require.Equal(t, tt.wantTTL, c.GetCacheTTL("default/spd-1"))
+
+			// override is restored from checkpoint as well
+			restored := NewSPDCache(manager, time.Minute)
+			require.NotNil(t, restored)
+			require.Equal(t, tt.wantTTL, restored.GetCacheTTL("default/spd-1"))
+		})
+	}
+}
+
+func TestCache_Dump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	manager, err := checkpointmanager.NewCheckpointManager(dir)
+	require.NoError(t, err)
+	c := NewSPDCache(manager, time.Minute)
+	require.NotNil(t, c)
+	require.Empty(t, c.Dump())
+
+	fetchTime := time.Now()
+	for _, name := range []string{"spd-2", "spd-1"} {
+		key := "default/" + name
+		require.NoError(t, c.SetSPD(key, &workloadapis.ServiceProfileDescriptor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					pkgconsts.ServiceProfileDescriptorAnnotationKeyConfigHash: "hash-" + name,
+				},
+			},
+		}))
+		c.SetLastFetchRemoteTime(key, fetchTime)
+	}
+	// spd only attempted to fetch is not dumped
+	c.SetLastFetchRemoteTime("default/spd-3", fetchTime)
+
+	entries := c.Dump()
+	require.Len(t, entries, 2)
+	for i, name := range []string{"spd-1", "spd-2"} {
+		require.Equal(t, "default/"+name, entries[i].Key)
+		require.Equal(t, "default", entries[i].Namespace)
+		require.Equal(t, name, entries[i].Name)
+		require.Equal(t, "hash-"+name, entries[i].Hash)
+		require.Equal(t, fetchTime, entries[i].LastFetchRemoteTime)
+	}
+
+	// dumped spd is a deep copy
+	entries[0].SPD.Name = "modified"
+	require.Equal(t, "spd-1", c.GetSPD("default/spd-1").Name)
+
+	// dump is safe to be called concurrently with cache updates
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("default/spd-%v", i)
+			c.SetLastFetchRemoteTime(key, time.Now())
+			_ = c.SetSPD(key, &workloadapis.ServiceProfileDescriptor{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("spd-%v", i),
+					Namespace: "default",
+				},
+			})
+		}(i)
+		go func() {
+			defer wg.Done()
+			_ = c.Dump()
+		}()
+	}
+	wg.Wait()
+	require.Len(t, c.Dump(), 10)
+}
+
+func TestMemorySPDCache(t *testing.T) {
+	c := NewMemorySPDCache(time.Minute)
+	require.Empty(t, c.ListSPDKeys())
+
+	spd := &workloadapis.ServiceProfileDescriptor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "spd-1",
+			Namespace: "default",
+			Annotations: map[string]string{
+				pkgconsts.ServiceProfileDescriptorAnnotationKeyCacheTTL: "30s",
+			},
+		},
+	}
+	require.NoError(t, c.SetSPD("default/spd-1", spd))
+	require.True(t, c.HasSPD("default/spd-1"))
+	require.Equal(t, 30*time.Second, c.GetCacheTTL("default/spd-1"))
+	require.Equal(t, []string{"default/spd-1"}, c.ListSPDKeys())
+
+	fetchTime := time.Now()
+	c.SetLastFetchRemoteTime("default/spd-1", fetchTime)
+	require.Equal(t, fetchTime, c.GetLastFetchRemoteTime("default/spd-1"))
+
+	require.NoError(t, c.DeleteSPD("default/spd-1"))
+	require.False(t, c.HasSPD("default/spd-1"))
+	require.Nil(t, c.GetSPD("default/spd-1"))
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spd
+
+import (
+	"fmt"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	workloadapis "github.com/kubewharf/katalyst-api/pkg/apis/workload/v1alpha1"
+)
+
+// spdValidationRule checks whether spd violates a rule, and returns error if so
+type spdValidationRule func(spd *workloadapis.ServiceProfileDescriptor) error
+
+// spdValidationRules are checked before spd is cached, and new rules should be appended here
+var spdValidationRules = []spdValidationRule{
+	validateSPDBaselineRatio,
+	validateSPDIndicatorNames,
+	validateSPDIndicatorValues,
+}
+
+// validateSPD checks spd against all validation rules, and returns the aggregated errors of violated ones
+func validateSPD(spd *workloadapis.ServiceProfileDescriptor) error {
+	if spd == nil {
+		return fmt.Errorf("spd is nil")
+	}
+
+	var errList []error
+	for _, rule := range spdValidationRules {
+		if err := rule(spd); err != nil {
+			errList = append(errList, err)
+		}
+	}
+	return utilerrors.NewAggregate(errList)
+}
+
+// validateSPDBaselineRatio checks that baseline ratio is within [0, 1] if it's set
+func validateSPDBaselineRatio(spd *workloadapis.ServiceProfileDescriptor) error {
+	ratio := spd.Spec.BaselineRatio
+	if ratio != nil && (*ratio < 0 || *ratio > 1) {
+		return fmt.Errorf("baseline ratio %v is out of [0, 1]", *ratio)
+	}
+	return nil
+}
+
+// validateSPDIndicatorNames checks that each business or system indicator is defined only once
+func validateSPDIndicatorNames(spd *workloadapis.ServiceProfileDescriptor) error {
+	businessNames := sets.NewString()
+	for _, indicator := range spd.Spec.BusinessIndicator {
+		name := string(indicator.Name)
+		if businessNames.Has(name) {
+			return fmt.Errorf("business indicator %v is duplicated", name)
+		}
+		businessNames.Insert(name)
+	}
+
+	systemNames := sets.NewString()
+	for _, indicator := range spd.Spec.SystemIndicator {
+		name := string(indicator.Name)
+		if systemNames.Has(name) {
+			return fmt.Errorf("system indicator %v is duplicated", name)
+		}
+		systemNames.Insert(name)
+	}
+	return nil
+}
+
+// validateSPDIndicatorValues checks that values of each indicator are not negative,
+// each level is defined only once, and the lower bound doesn't exceed the upper bound
+func validateSPDIndicatorValues(spd *workloadapis.ServiceProfileDescriptor) error {
+	for _, indicator := range spd.Spec.BusinessIndicator {
+		if err := validateIndicatorLevels(indicator.Indicators); err != nil {
+			return fmt.Errorf("business indicator %v is invalid: %v", indicator.Name, err)
+		}
+	}
+
+	for _, indicator := range spd.Spec.SystemIndicator {
+		if err := validateIndicatorLevels(indicator.Indicators); err != nil {
+			return fmt.Errorf("system indicator %v is invalid: %v", indicator.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateIndicatorLevels(indicators []workloadapis.Indicator) error {
+	values := make(map[workloadapis.IndicatorLevelName]float32, len(indicators))
+	for _, indicator := range indicators {
+		if indicator.Value < 0 {
+			return fmt.Errorf("level %v has negative value %v", indicator.IndicatorLevel, indicator.Value)
+		} else if _, ok := values[indicator.IndicatorLevel]; ok {
+			return fmt.Errorf("level %v is duplicated", indicator.IndicatorLevel)
+		}
+		values[indicator.IndicatorLevel] = indicator.Value
+	}
+
+	lower, lowerOK := values[workloadapis.IndicatorLevelLowerBound]
+	upper, upperOK := values[workloadapis.IndicatorLevelUpperBound]
+	if lowerOK && upperOK && lower > upper {
+		return fmt.Errorf("lower bound %v is greater than upper bound %v", lower, upper)
+	}
+	return nil
+}
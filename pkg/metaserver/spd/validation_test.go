@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	workloadapis "github.com/kubewharf/katalyst-api/pkg/apis/workload/v1alpha1"
+)
+
+func Test_validateSPD(t *testing.T) {
+	t.Parallel()
+
+	ratio := func(r float32) *float32 { return &r }
+	indicators := func(lower, upper float32) []workloadapis.Indicator {
+		return []workloadapis.Indicator{
+			{IndicatorLevel: workloadapis.IndicatorLevelLowerBound, Value: lower},
+			{IndicatorLevel: workloadapis.IndicatorLevelUpperBound, Value: upper},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		spec    *workloadapis.ServiceProfileDescriptorSpec
+		wantErr bool
+	}{
+		{
+			name:    "nil spd",
+			wantErr: true,
+		},
+		{
+			name: "valid spd",
+			spec: &workloadapis.ServiceProfileDescriptorSpec{
+				BaselineRatio: ratio(0.5),
+				BusinessIndicator: []workloadapis.ServiceBusinessIndicatorSpec{
+					{Name: "rpc_latency", Indicators: indicators(10, 20)},
+				},
+				SystemIndicator: []workloadapis.ServiceSystemIndicatorSpec{
+					{Name: "cpu_sched_wait", Indicators: indicators(400, 800)},
+				},
+			},
+		},
+		{
+			name:    "baseline ratio out of range",
+			spec:    &workloadapis.ServiceProfileDescriptorSpec{BaselineRatio: ratio(1.5)},
+			wantErr: true,
+		},
+		{
+			name: "duplicated business indicator",
+			spec: &workloadapis.ServiceProfileDescriptorSpec{
+				BusinessIndicator: []workloadapis.ServiceBusinessIndicatorSpec{
+					{Name: "rpc_latency", Indicators: indicators(10, 20)},
+					{Name: "rpc_latency", Indicators: indicators(10, 20)},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative indicator value",
+			spec: &workloadapis.ServiceProfileDescriptorSpec{
+				SystemIndicator: []workloadapis.ServiceSystemIndicatorSpec{
+					{Name: "cpu_sched_wait", Indicators: indicators(-1, 800)},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "lower bound greater than upper bound",
+			spec: &workloadapis.ServiceProfileDescriptorSpec{
+				BusinessIndicator: []workloadapis.ServiceBusinessIndicatorSpec{
+					{Name: "rpc_latency", Indicators: indicators(30, 20)},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicated indicator level",
+			spec: &workloadapis.ServiceProfileDescriptorSpec{
+				BusinessIndicator: []workloadapis.ServiceBusinessIndicatorSpec{
+					{Name: "rpc_latency", Indicators: append(indicators(10, 20),
+						workloadapis.Indicator{IndicatorLevel: workloadapis.IndicatorLevelUpperBound, Value: 30})},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var spd *workloadapis.ServiceProfileDescriptor
+			if tt.spec != nil {
+				spd = &workloadapis.ServiceProfileDescriptor{Spec: *tt.spec}
+			}
+			err := validateSPD(spd)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
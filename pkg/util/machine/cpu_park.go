@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RecommendParkCPUs recommends cpus to park (offline) for power saving, so that only targetOnline
+// cpus in available are kept online. to keep numa balance, cpus are parked from the numa node with the
+// most online cpus in turn, and within the node, cpus of partially parked cores are preferred so that
+// whole cores are vacated first. the last online cpu of a numa node is never parked, and it returns
+// error if targetOnline can't be satisfied without vacating whole numa nodes.
+func RecommendParkCPUs(available map[int]CPUSet, targetOnline int, topology *CPUTopology) (CPUSet, error) {
+	if topology == nil {
+		return NewCPUSet(), fmt.Errorf("RecommendParkCPUs got nil topology")
+	}
+
+	online := make(map[int]CPUSet, len(available))
+	totalOnline := 0
+	for numaID, cpus := range available {
+		for _, cpu := range cpus.ToSliceNoSortInt() {
+			if _, ok := topology.CPUDetails[cpu]; !ok {
+				return NewCPUSet(), fmt.Errorf("cpu %d not found in topology", cpu)
+			}
+		}
+
+		if cpus.IsEmpty() {
+			continue
+		}
+		online[numaID] = cpus.Clone()
+		totalOnline += cpus.Size()
+	}
+
+	if targetOnline < len(online) {
+		return NewCPUSet(), fmt.Errorf("target online %d is less than numa node count %d", targetOnline, len(online))
+	}
+
+	park := NewCPUSet()
+	for i := targetOnline; i < totalOnline; i++ {
+		numaID := pickNUMANodeToPark(online)
+		cpu := pickCPUToPark(online[numaID], topology)
+		online[numaID] = online[numaID].Difference(NewCPUSet(cpu))
+		park.Add(cpu)
+	}
+
+	return park, nil
+}
+
+// pickNUMANodeToPark returns the numa node with the most online cpus, and lower id is preferred
+// if multiple numa nodes have the same number of online cpus
+func pickNUMANodeToPark(online map[int]CPUSet) int {
+	numaIDs := make([]int, 0, len(online))
+	for numaID := range online {
+		numaIDs = append(numaIDs, numaID)
+	}
+	sort.Ints(numaIDs)
+
+	target := numaIDs[0]
+	for _, numaID := range numaIDs[1:] {
+		if online[numaID].Size() > online[target].Size() {
+			target = numaID
+		}
+	}
+	return target
+}
+
+// pickCPUToPark returns the cpu to park in the given online cpus, cpus of the core with
+// the fewest online cpus are preferred, and higher core id and cpu id are preferred then
+func pickCPUToPark(online CPUSet, topology *CPUTopology) int {
+	coreCPUs := make(map[int][]int)
+	for _, cpu := range online.ToSliceInt() {
+		coreID := topology.CPUDetails[cpu].CoreID
+		coreCPUs[coreID] = append(coreCPUs[coreID], cpu)
+	}
+
+	targetCore := -1
+	for coreID, cpus := range coreCPUs {
+		if targetCore == -1 || len(cpus) < len(coreCPUs[targetCore]) ||
+			(len(cpus) == len(coreCPUs[targetCore]) && coreID > targetCore) {
+			targetCore = coreID
+		}
+	}
+
+	cpus := coreCPUs[targetCore]
+	return cpus[len(cpus)-1]
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecommendParkCPUs(t *testing.T) {
+	// numa node0 cpu(s): 0-23,48-71
+	// numa node1 cpu(s): 24-47,72-95
+	topology, err := GenerateDummyCPUTopology(96, 2, 2)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		available    map[int]CPUSet
+		targetOnline int
+		want         CPUSet
+		wantErr      bool
+	}{
+		{
+			name: "nothing to park",
+			available: map[int]CPUSet{
+				0: MustParse("0-3"),
+				1: MustParse("24-27"),
+			},
+			targetOnline: 8,
+			want:         NewCPUSet(),
+		},
+		{
+			name: "park from the larger numa node and vacate whole cores",
+			available: map[int]CPUSet{
+				0: MustParse("0-3,48-51"),
+				1: MustParse("24-25"),
+			},
+			targetOnline: 6,
+			want:         MustParse("2-3,50-51"),
+		},
+		{
+			name: "park evenly among numa nodes",
+			available: map[int]CPUSet{
+				0: MustParse("0-1,48-49"),
+				1: MustParse("24-25,72-73"),
+			},
+			targetOnline: 4,
+			want:         MustParse("1,25,49,73"),
+		},
+		{
+			name: "never park the last cpu of a numa node",
+			available: map[int]CPUSet{
+				0: MustParse("0"),
+				1: MustParse("24-27"),
+			},
+			targetOnline: 2,
+			want:         MustParse("25-27"),
+		},
+		{
+			name: "target less than numa node count",
+			available: map[int]CPUSet{
+				0: MustParse("0-3"),
+				1: MustParse("24-27"),
+			},
+			targetOnline: 1,
+			wantErr:      true,
+		},
+		{
+			name: "cpu not in topology",
+			available: map[int]CPUSet{
+				0: MustParse("0-3,96"),
+			},
+			targetOnline: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RecommendParkCPUs(tt.available, tt.targetOnline, topology)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equals(got), "want %v, got %v", tt.want, got)
+		})
+	}
+}
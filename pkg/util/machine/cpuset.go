@@ -78,6 +78,16 @@ func (s CPUSet) MarshalJSON() ([]byte, error) {
 	}
 }
 
+// GobEncode implements gob.GobEncoder, since elems can't be encoded by gob directly
+func (s CPUSet) GobEncode() ([]byte, error) {
+	return s.MarshalJSON()
+}
+
+// GobDecode implements gob.GobDecoder
+func (s *CPUSet) GobDecode(b []byte) error {
+	return s.UnmarshalJSON(b)
+}
+
 // Add adds the supplied elements to the result.
 func (s CPUSet) Add(elems ...int) {
 	for _, elem := range elems {
@@ -343,3 +353,68 @@ func Parse(s string) (CPUSet, error) {
 	}
 	return s2, nil
 }
+
+// ParseWithStride constructs a new CPU set from a Linux CPU list formatted string like Parse,
+// and it also accepts ranges with stride notation like "0-15:2" used by some kernel files.
+// Different from Parse, it returns a descriptive error instead of ignoring malformed elements.
+//
+// See: https://www.kernel.org/doc/html/latest/admin-guide/kernel-parameters.html#cpu-lists
+func ParseWithStride(s string) (CPUSet, error) {
+	s2 := NewCPUSet()
+
+	// kernel files usually end with a newline
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return s2, nil
+	}
+
+	for _, r := range strings.Split(s, ",") {
+		if err := parseRangeWithStride(r, s2); err != nil {
+			return NewCPUSet(), fmt.Errorf("invalid cpu list %q: %v", s, err)
+		}
+	}
+	return s2, nil
+}
+
+// parseRangeWithStride parses a single element like "34", "0-5" or "0-15:2" into s
+func parseRangeWithStride(r string, s CPUSet) error {
+	stride := 1
+	if parts := strings.Split(r, ":"); len(parts) == 2 {
+		var err error
+		stride, err = strconv.Atoi(parts[1])
+		if err != nil || stride <= 0 {
+			return fmt.Errorf("invalid stride in %q", r)
+		}
+		r = parts[0]
+		if !strings.Contains(r, "-") {
+			return fmt.Errorf("stride without range in %q", r)
+		}
+	} else if len(parts) > 2 {
+		return fmt.Errorf("invalid range %q", r)
+	}
+
+	boundaries := strings.Split(r, "-")
+	switch len(boundaries) {
+	case 1:
+		elem, err := strconv.Atoi(boundaries[0])
+		if err != nil || elem < 0 {
+			return fmt.Errorf("invalid cpu %q", r)
+		}
+		s.Add(elem)
+	case 2:
+		start, err := strconv.Atoi(boundaries[0])
+		if err != nil || start < 0 {
+			return fmt.Errorf("invalid range start in %q", r)
+		}
+		end, err := strconv.Atoi(boundaries[1])
+		if err != nil || end < start {
+			return fmt.Errorf("invalid range end in %q", r)
+		}
+		for e := start; e <= end; e += stride {
+			s.Add(e)
+		}
+	default:
+		return fmt.Errorf("invalid range %q", r)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWithStride(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{input: "", want: []int{}},
+		{input: "0-7", want: []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{input: "0-7:2", want: []int{0, 2, 4, 6}},
+		{input: "1,3,5", want: []int{1, 3, 5}},
+		{input: "0-3:3,8,10-11\n", want: []int{0, 3, 8, 10, 11}},
+		{input: "a", wantErr: true},
+		{input: "0-", wantErr: true},
+		{input: "7-0", wantErr: true},
+		{input: "0-7:0", wantErr: true},
+		{input: "0-7:x", wantErr: true},
+		{input: "3:2", wantErr: true},
+		{input: "0-7:2:1", wantErr: true},
+		{input: "0-1-2", wantErr: true},
+		{input: "1,,3", wantErr: true},
+		{input: "-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWithStride(tt.input)
+		if tt.wantErr {
+			assert.Error(t, err, tt.input)
+			continue
+		}
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, got.ToSliceInt(), tt.input)
+	}
+}
@@ -18,6 +18,8 @@ package machine
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	info "github.com/google/cadvisor/info/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -123,6 +125,8 @@ func GenerateDummyMachineInfo(numaNum int, memoryCapacityGB int) (*info.MachineI
 	return machineInfo, nil
 }
 
+// GenerateDummyCPUTopology generates a regular CPUTopology for tests, and GenerateCPUTopologyFromSysfs
+// should be used to build the one of host
 func GenerateDummyCPUTopology(cpuNum, socketNum, numaNum int) (*CPUTopology, error) {
 	if numaNum%socketNum != 0 {
 		return nil, fmt.Errorf("invalid NUMA number: %d and socket number: %d", numaNum, socketNum)
@@ -393,6 +397,123 @@ func GetNumaAwareAssignments(topology *CPUTopology, cset CPUSet) (map[int]CPUSet
 	return topologyAwareAssignments, nil
 }
 
+// NUMANodesForCPUSet returns the sorted numa nodes owning any cpu in the given cpuset,
+// and cpus not found in topology are ignored since the topology is validated when generated
+func NUMANodesForCPUSet(cpus CPUSet, topology *CPUTopology) []int {
+	if topology == nil {
+		return []int{}
+	}
+	return topology.CPUDetails.KeepOnly(cpus).NUMANodes().ToSliceInt()
+}
+
+// IsSingleNUMA returns whether all cpus in the given cpuset belong to one numa node,
+// and it returns false for empty cpuset
+func IsSingleNUMA(cpus CPUSet, topology *CPUTopology) bool {
+	return len(NUMANodesForCPUSet(cpus, topology)) == 1
+}
+
+// ReserveSystemCPUs returns a copy of topology with the reserved cpus (e.g. for kernel or agents)
+// excluded from all numa nodes, so that sizing based on it only counts the schedulable cpus.
+// the number of sockets and numa nodes are kept as the machine hardware.
+func ReserveSystemCPUs(topology *CPUTopology, reserved CPUSet) (*CPUTopology, error) {
+	if topology == nil {
+		return nil, fmt.Errorf("ReserveSystemCPUs got nil topology")
+	}
+
+	allCPUs := topology.CPUDetails.CPUs()
+	if !reserved.IsSubsetOf(allCPUs) {
+		return nil, fmt.Errorf("reserved cpus %s not found in topology", reserved.Difference(allCPUs).String())
+	}
+
+	cpuDetails := topology.CPUDetails.KeepOnly(allCPUs.Difference(reserved))
+	return &CPUTopology{
+		NumCPUs:      len(cpuDetails),
+		NumCores:     cpuDetails.Cores().Size(),
+		NumSockets:   topology.NumSockets,
+		NumNUMANodes: topology.NumNUMANodes,
+		CPUDetails:   cpuDetails,
+	}, nil
+}
+
+// CPUsInSocket returns all logical cpus located in the given socket
+func CPUsInSocket(topology *CPUTopology, socketID int) CPUSet {
+	if topology == nil {
+		return NewCPUSet()
+	}
+	return topology.CPUDetails.CPUsInSockets(socketID)
+}
+
+// SocketForCPU returns the socket where the given cpu is located
+func SocketForCPU(topology *CPUTopology, cpu int) (int, error) {
+	if topology == nil {
+		return 0, fmt.Errorf("SocketForCPU got nil topology")
+	}
+
+	info, ok := topology.CPUDetails[cpu]
+	if !ok {
+		return 0, fmt.Errorf("cpu %d not found in topology", cpu)
+	}
+	return info.SocketID, nil
+}
+
+// CPUSetBySocket returns a mapping from socket id to all logical cpus located in it
+func CPUSetBySocket(topology *CPUTopology) map[int]CPUSet {
+	res := make(map[int]CPUSet)
+	if topology == nil {
+		return res
+	}
+
+	for cpu, info := range topology.CPUDetails {
+		if _, ok := res[info.SocketID]; !ok {
+			res[info.SocketID] = NewCPUSet()
+		}
+		res[info.SocketID].Add(cpu)
+	}
+	return res
+}
+
+// ValidateCPUAssignment checks whether each cpu in the given assignment belongs to
+// the numa node it's assigned to, and it returns error listing all the mismatched cpus
+// and the cpus not found in topology
+func ValidateCPUAssignment(assignment map[int]CPUSet, topology *CPUTopology) error {
+	if topology == nil {
+		return fmt.Errorf("ValidateCPUAssignment got nil topology")
+	}
+
+	numaNodes := make([]int, 0, len(assignment))
+	for numaNode := range assignment {
+		numaNodes = append(numaNodes, numaNode)
+	}
+	sort.Ints(numaNodes)
+
+	var errList []string
+	notFound := NewCPUSet()
+	for _, numaNode := range numaNodes {
+		mismatched := NewCPUSet()
+		for _, cpu := range assignment[numaNode].ToSliceNoSortInt() {
+			info, ok := topology.CPUDetails[cpu]
+			if !ok {
+				notFound.Add(cpu)
+			} else if info.NUMANodeID != numaNode {
+				mismatched.Add(cpu)
+			}
+		}
+
+		if mismatched.Size() > 0 {
+			errList = append(errList, fmt.Sprintf("cpus %s not in numa %d", mismatched.String(), numaNode))
+		}
+	}
+
+	if notFound.Size() > 0 {
+		errList = append(errList, fmt.Sprintf("cpus %s not found in topology", notFound.String()))
+	}
+
+	if len(errList) > 0 {
+		return fmt.Errorf("invalid cpu assignment: %s", strings.Join(errList, "; "))
+	}
+	return nil
+}
+
 // CheckNUMACrossSockets judges whether the given NUMA nodes are located
 // in different sockets
 func CheckNUMACrossSockets(numaNodes []int, cpuTopology *CPUTopology) (bool, error) {
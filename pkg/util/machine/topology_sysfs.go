@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// SysfsCPUPath is the default sysfs directory describing the cpus of host
+const SysfsCPUPath = "/sys/devices/system/cpu"
+
+const (
+	sysfsCPUOnline            = "online"
+	sysfsCPUPhysicalPackageID = "topology/physical_package_id"
+	sysfsCPUThreadSiblings    = "topology/thread_siblings_list"
+)
+
+var (
+	sysfsCPUDirRegExp   = regexp.MustCompile(`^cpu([0-9]+)$`)
+	sysfsNUMANodeRegExp = regexp.MustCompile(`^node([0-9]+)$`)
+)
+
+// GenerateCPUTopologyFromSysfs builds CPUTopology from the sysfs cpu directory, e.g. SysfsCPUPath,
+// by reading the socket, core and numa node of each cpu. offline cpus are excluded from the
+// topology, and cpus without numa node link are regarded in numa node 0 as for non-numa machines.
+func GenerateCPUTopologyFromSysfs(root string) (*CPUTopology, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("read sysfs cpu dir %s failed: %v", root, err)
+	}
+
+	// online file may be absent if cpu hotplug is not supported, and then all cpus are online
+	online, err := readCPUSetFile(filepath.Join(root, sysfsCPUOnline))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read online cpus failed: %v", err)
+	}
+	checkOnline := err == nil
+
+	cpuDetails := CPUDetails{}
+	for _, entry := range entries {
+		match := sysfsCPUDirRegExp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		cpu, _ := strconv.Atoi(match[1])
+		if checkOnline && !online.Contains(cpu) {
+			klog.Infof("skip offline cpu %d", cpu)
+			continue
+		}
+
+		cpuDir := filepath.Join(root, entry.Name())
+		socketID, err := readIntFile(filepath.Join(cpuDir, sysfsCPUPhysicalPackageID))
+		if os.IsNotExist(err) {
+			klog.Warningf("skip cpu %d without topology", cpu)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("read socket of cpu %d failed: %v", cpu, err)
+		}
+
+		siblings, err := readCPUSetFile(filepath.Join(cpuDir, sysfsCPUThreadSiblings))
+		if os.IsNotExist(err) {
+			siblings = NewCPUSet(cpu)
+		} else if err != nil {
+			return nil, fmt.Errorf("read thread siblings of cpu %d failed: %v", cpu, err)
+		}
+		coreID, err := getUniqueCoreID(siblings.ToSliceInt())
+		if err != nil {
+			return nil, fmt.Errorf("get core of cpu %d failed: %v", cpu, err)
+		}
+
+		numaID, err := getNUMANodeOfCPU(cpuDir)
+		if err != nil {
+			return nil, fmt.Errorf("get numa node of cpu %d failed: %v", cpu, err)
+		}
+
+		cpuDetails[cpu] = CPUInfo{
+			NUMANodeID: numaID,
+			SocketID:   socketID,
+			CoreID:     coreID,
+		}
+	}
+
+	if len(cpuDetails) == 0 {
+		return nil, fmt.Errorf("no online cpu found in %s", root)
+	}
+
+	return &CPUTopology{
+		NumCPUs:      len(cpuDetails),
+		NumCores:     cpuDetails.Cores().Size(),
+		NumSockets:   cpuDetails.Sockets().Size(),
+		NumNUMANodes: cpuDetails.NUMANodes().Size(),
+		CPUDetails:   cpuDetails,
+	}, nil
+}
+
+// getNUMANodeOfCPU returns the numa node linked in the cpu dir, and it returns 0 if no link is found
+func getNUMANodeOfCPU(cpuDir string) (int, error) {
+	entries, err := ioutil.ReadDir(cpuDir)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		if match := sysfsNUMANodeRegExp.FindStringSubmatch(entry.Name()); match != nil {
+			return strconv.Atoi(match[1])
+		}
+	}
+	return 0, nil
+}
+
+func readIntFile(path string) (int, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(content)))
+}
+
+func readCPUSetFile(path string) (CPUSet, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return CPUSet{}, err
+	}
+	return ParseWithStride(string(content))
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCPUTopologyFromSysfs(t *testing.T) {
+	// socket0/numa0 cpu(s): 0,1,4,5
+	// socket1/numa1 cpu(s): 2,3,6, and cpu 7 (sibling of cpu 3) is offline
+	topology, err := GenerateCPUTopologyFromSysfs("testdata/sysfs/cpu")
+	require.NoError(t, err)
+	assert.Equal(t, &CPUTopology{
+		NumCPUs:      7,
+		NumCores:     4,
+		NumSockets:   2,
+		NumNUMANodes: 2,
+		CPUDetails: CPUDetails{
+			0: {NUMANodeID: 0, SocketID: 0, CoreID: 0},
+			1: {NUMANodeID: 0, SocketID: 0, CoreID: 1},
+			2: {NUMANodeID: 1, SocketID: 1, CoreID: 2},
+			3: {NUMANodeID: 1, SocketID: 1, CoreID: 3},
+			4: {NUMANodeID: 0, SocketID: 0, CoreID: 0},
+			5: {NUMANodeID: 0, SocketID: 0, CoreID: 1},
+			6: {NUMANodeID: 1, SocketID: 1, CoreID: 2},
+		},
+	}, topology)
+
+	// without online file or numa node links, all cpus are online and in numa node 0
+	topology, err = GenerateCPUTopologyFromSysfs("testdata/sysfs-nonuma/cpu")
+	require.NoError(t, err)
+	assert.Equal(t, &CPUTopology{
+		NumCPUs:      2,
+		NumCores:     2,
+		NumSockets:   1,
+		NumNUMANodes: 1,
+		CPUDetails: CPUDetails{
+			0: {NUMANodeID: 0, SocketID: 0, CoreID: 0},
+			1: {NUMANodeID: 0, SocketID: 0, CoreID: 1},
+		},
+	}, topology)
+
+	_, err = GenerateCPUTopologyFromSysfs("testdata/not-exist")
+	assert.Error(t, err)
+}
+
+func TestReadCPUSetFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cpulist")
+	require.NoError(t, ioutil.WriteFile(path, []byte("0-6:2,9\n"), 0644))
+	cpus, err := readCPUSetFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2, 4, 6, 9}, cpus.ToSliceInt())
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("0-3,x\n"), 0644))
+	_, err = readCPUSetFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Katalyst Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNUMANodesForCPUSet(t *testing.T) {
+	// numa node0 cpu(s): 0-23,48-71
+	// numa node1 cpu(s): 24-47,72-95
+	topology, err := GenerateDummyCPUTopology(96, 2, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{0}, NUMANodesForCPUSet(MustParse("0-3,48-51"), topology))
+	assert.Equal(t, []int{0, 1}, NUMANodesForCPUSet(MustParse("20-30,80"), topology))
+	assert.Equal(t, []int{}, NUMANodesForCPUSet(NewCPUSet(), topology))
+	assert.Equal(t, []int{1}, NUMANodesForCPUSet(MustParse("95-96"), topology))
+	assert.Equal(t, []int{}, NUMANodesForCPUSet(MustParse("0"), nil))
+}
+
+func TestIsSingleNUMA(t *testing.T) {
+	// numa node0 cpu(s): 0-23,48-71
+	// numa node1 cpu(s): 24-47,72-95
+	topology, err := GenerateDummyCPUTopology(96, 2, 2)
+	require.NoError(t, err)
+
+	assert.True(t, IsSingleNUMA(MustParse("0-3,48-51"), topology))
+	assert.True(t, IsSingleNUMA(MustParse("24"), topology))
+	assert.False(t, IsSingleNUMA(MustParse("20-30,80"), topology))
+	assert.False(t, IsSingleNUMA(NewCPUSet(), topology))
+	assert.False(t, IsSingleNUMA(MustParse("0"), nil))
+}
+
+func TestReserveSystemCPUs(t *testing.T) {
+	// numa node0 cpu(s): 0-23,48-71
+	// numa node1 cpu(s): 24-47,72-95
+	topology, err := GenerateDummyCPUTopology(96, 2, 2)
+	require.NoError(t, err)
+
+	reservedTopology, err := ReserveSystemCPUs(topology, MustParse("0"))
+	require.NoError(t, err)
+	assert.Equal(t, 95, reservedTopology.NumCPUs)
+	assert.Equal(t, 48, reservedTopology.NumCores)
+	assert.Equal(t, 2, reservedTopology.NumNUMANodes)
+	assert.Equal(t, MustParse("1-23,48-71"), reservedTopology.CPUDetails.CPUsInNUMANodes(0))
+	assert.Equal(t, MustParse("24-47,72-95"), reservedTopology.CPUDetails.CPUsInNUMANodes(1))
+
+	assignment, err := GetNumaAwareAssignments(reservedTopology, MustParse("0-95"))
+	require.NoError(t, err)
+	assert.False(t, assignment[0].Contains(0))
+
+	// the original topology is untouched
+	assert.Equal(t, 96, topology.NumCPUs)
+	assert.True(t, topology.CPUDetails.CPUsInNUMANodes(0).Contains(0))
+
+	_, err = ReserveSystemCPUs(topology, MustParse("95-96"))
+	assert.Error(t, err)
+	_, err = ReserveSystemCPUs(nil, MustParse("0"))
+	assert.Error(t, err)
+}
+
+func TestValidateCPUAssignment(t *testing.T) {
+	// numa node0 cpu(s): 0-23,48-71
+	// numa node1 cpu(s): 24-47,72-95
+	topology, err := GenerateDummyCPUTopology(96, 2, 2)
+	require.NoError(t, err)
+
+	err = ValidateCPUAssignment(map[int]CPUSet{
+		0: MustParse("0-23,48-71"),
+		1: MustParse("24-30"),
+	}, topology)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ValidateCPUAssignment(map[int]CPUSet{}, topology))
+
+	// corrupt assignment restored from a machine with different topology
+	err = ValidateCPUAssignment(map[int]CPUSet{
+		0: MustParse("22-25,96"),
+		1: MustParse("30,70-71"),
+		2: MustParse("100"),
+	}, topology)
+	assert.EqualError(t, err, "invalid cpu assignment: cpus 24-25 not in numa 0; "+
+		"cpus 70-71 not in numa 1; cpus 96,100 not found in topology")
+
+	assert.Error(t, ValidateCPUAssignment(map[int]CPUSet{}, nil))
+}
+
+func TestSocketHelpers(t *testing.T) {
+	// socket0 cpu(s): 0-23,48-71
+	// socket1 cpu(s): 24-47,72-95
+	topology, err := GenerateDummyCPUTopology(96, 2, 4)
+	require.NoError(t, err)
+
+	assert.Equal(t, MustParse("0-23,48-71"), CPUsInSocket(topology, 0))
+	assert.Equal(t, MustParse("24-47,72-95"), CPUsInSocket(topology, 1))
+	assert.Equal(t, NewCPUSet(), CPUsInSocket(topology, 2))
+
+	socketID, err := SocketForCPU(topology, 48)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, socketID)
+
+	socketID, err = SocketForCPU(topology, 95)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, socketID)
+
+	_, err = SocketForCPU(topology, 96)
+	assert.Error(t, err)
+
+	assert.Equal(t, map[int]CPUSet{
+		0: MustParse("0-23,48-71"),
+		1: MustParse("24-47,72-95"),
+	}, CPUSetBySocket(topology))
+	assert.Equal(t, map[int]CPUSet{}, CPUSetBySocket(nil))
+}
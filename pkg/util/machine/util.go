@@ -17,6 +17,11 @@ limitations under the License.
 package machine
 
 import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager"
 	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager/bitmask"
 )
 
@@ -63,6 +68,18 @@ func GetQuantityMap(csetMap map[string]CPUSet) map[string]int {
 	return ret
 }
 
+// GetMilliQuantityMap is used to generate milli-cpu resource quantity map
+// based on the given CPUSet map
+func GetMilliQuantityMap(csetMap map[string]CPUSet) map[string]resource.Quantity {
+	ret := make(map[string]resource.Quantity)
+
+	for name, cset := range csetMap {
+		ret[name] = *resource.NewQuantity(int64(cset.Size()*1000), resource.DecimalSI)
+	}
+
+	return ret
+}
+
 // DeepcopyCPUAssignment returns a deep-copied assignments for the given one
 func DeepcopyCPUAssignment(assignment map[int]CPUSet) map[int]CPUSet {
 	if assignment == nil {
@@ -76,6 +93,70 @@ func DeepcopyCPUAssignment(assignment map[int]CPUSet) map[int]CPUSet {
 	return copied
 }
 
+// UnionCPUAssignment returns a new assignment with the union of cpus in each numa node,
+// and numa nodes present in only one of the assignments are kept as well
+func UnionCPUAssignment(a, b map[int]CPUSet) map[int]CPUSet {
+	res := DeepcopyCPUAssignment(a)
+	if res == nil {
+		res = make(map[int]CPUSet)
+	}
+
+	for numaNode, cset := range b {
+		if _, ok := res[numaNode]; ok {
+			res[numaNode] = res[numaNode].Union(cset)
+		} else {
+			res[numaNode] = cset.Clone()
+		}
+	}
+	return res
+}
+
+// IntersectCPUAssignment returns a new assignment with the intersection of cpus in each
+// numa node, and numa nodes without any common cpus are dropped
+func IntersectCPUAssignment(a, b map[int]CPUSet) map[int]CPUSet {
+	res := make(map[int]CPUSet)
+	for numaNode, cset := range a {
+		other, ok := b[numaNode]
+		if !ok {
+			continue
+		}
+
+		if common := cset.Intersection(other); common.Size() > 0 {
+			res[numaNode] = common
+		}
+	}
+	return res
+}
+
+// SubtractCPUAssignment returns a new assignment with cpus in b removed from a in each
+// numa node, and numa nodes without any cpus left are dropped
+func SubtractCPUAssignment(a, b map[int]CPUSet) map[int]CPUSet {
+	res := make(map[int]CPUSet)
+	for numaNode, cset := range a {
+		left := cset.Clone()
+		if other, ok := b[numaNode]; ok {
+			left = cset.Difference(other)
+		}
+
+		if left.Size() > 0 {
+			res[numaNode] = left
+		}
+	}
+	return res
+}
+
+// PruneEmptyAssignment returns a new assignment with numa nodes without any cpus dropped,
+// which is useful for assignments not generated by the set operations above
+func PruneEmptyAssignment(assignment map[int]CPUSet) map[int]CPUSet {
+	res := make(map[int]CPUSet)
+	for numaNode, cset := range assignment {
+		if cset.Size() > 0 {
+			res[numaNode] = cset.Clone()
+		}
+	}
+	return res
+}
+
 // MaskToUInt64Array transforms bit mask to uint slices
 func MaskToUInt64Array(mask bitmask.BitMask) []uint64 {
 	maskBits := mask.GetBits()
@@ -87,3 +168,227 @@ func MaskToUInt64Array(mask bitmask.BitMask) []uint64 {
 
 	return maskBitsUint64
 }
+
+// UInt64ArrayToMask transforms uint slices to bit mask, and it returns error
+// if any bit exceeds the capacity of bit mask
+func UInt64ArrayToMask(maskBitsUint64 []uint64) (bitmask.BitMask, error) {
+	maskBits := make([]int, 0, len(maskBitsUint64))
+	for _, bit := range maskBitsUint64 {
+		maskBits = append(maskBits, int(bit))
+	}
+
+	mask, err := bitmask.NewBitMask(maskBits...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mask bits %v: %v", maskBitsUint64, err)
+	}
+	return mask, nil
+}
+
+// MaskToIntArray transforms bit mask to int slices
+func MaskToIntArray(mask bitmask.BitMask) []int {
+	return mask.GetBits()
+}
+
+// GenerateNUMAHints generates topology hints for the given cpuset, the hint of the minimal
+// NUMA nodes spanned by the cpuset is preferred, and hints of wider NUMA nodes containing
+// them are generated as non-preferred alternatives. cpus not in topology are ignored.
+func GenerateNUMAHints(cset CPUSet, topology *CPUTopology) []topologymanager.TopologyHint {
+	if topology == nil || cset.IsEmpty() {
+		return nil
+	}
+
+	spannedNUMAs := topology.CPUDetails.KeepOnly(cset).NUMANodes()
+	if spannedNUMAs.IsEmpty() {
+		return nil
+	}
+
+	spannedMask, err := bitmask.NewBitMask(spannedNUMAs.ToSliceInt()...)
+	if err != nil {
+		return nil
+	}
+
+	var hints []topologymanager.TopologyHint
+	bitmask.IterateBitMasks(topology.CPUDetails.NUMANodes().ToSliceInt(), func(mask bitmask.BitMask) {
+		if !bitmask.And(mask, spannedMask).IsEqual(spannedMask) {
+			return
+		}
+
+		hints = append(hints, topologymanager.TopologyHint{
+			NUMANodeAffinity: mask,
+			Preferred:        mask.IsEqual(spannedMask),
+		})
+	})
+	return hints
+}
+
+// AllocateCPUs allocates num cpus from the available cpuset, and if preferFullCore is set,
+// it takes whole physical cores (all sibling threads available) at first, and then splits
+// the cores already partially available before splitting any whole core.
+func AllocateCPUs(available CPUSet, num int, topology *CPUTopology, preferFullCore bool) (CPUSet, error) {
+	if topology == nil {
+		return NewCPUSet(), fmt.Errorf("AllocateCPUs got nil topology")
+	} else if num < 0 || num > available.Size() {
+		return NewCPUSet(), fmt.Errorf("invalid num: %d with available cpus: %s", num, available.String())
+	}
+
+	result := NewCPUSet()
+	if !preferFullCore {
+		for _, cpu := range available.ToSliceInt() {
+			if result.Size() == num {
+				break
+			}
+			result.Add(cpu)
+		}
+		return result, nil
+	}
+
+	// group available cpus by physical core, and cores are ordered by their smallest cpu
+	var cores []int
+	coreCPUs := make(map[int][]int)
+	for _, cpu := range available.ToSliceInt() {
+		info, ok := topology.CPUDetails[cpu]
+		if !ok {
+			return NewCPUSet(), fmt.Errorf("cpu %d not found in topology", cpu)
+		}
+
+		if _, ok := coreCPUs[info.CoreID]; !ok {
+			cores = append(cores, info.CoreID)
+		}
+		coreCPUs[info.CoreID] = append(coreCPUs[info.CoreID], cpu)
+	}
+
+	var fullCores, partialCores []int
+	for _, core := range cores {
+		if len(coreCPUs[core]) == topology.CPUDetails.CPUsInCores(core).Size() {
+			fullCores = append(fullCores, core)
+		} else {
+			partialCores = append(partialCores, core)
+		}
+	}
+
+	// take whole cores as long as all their threads are needed
+	var splitCores []int
+	for _, core := range fullCores {
+		if num-result.Size() >= len(coreCPUs[core]) {
+			result.Add(coreCPUs[core]...)
+		} else {
+			splitCores = append(splitCores, core)
+		}
+	}
+
+	for _, core := range append(partialCores, splitCores...) {
+		for _, cpu := range coreCPUs[core] {
+			if result.Size() == num {
+				return result, nil
+			}
+			result.Add(cpu)
+		}
+	}
+
+	return result, nil
+}
+
+// AllocateAvoiding allocates num cpus from the available cpuset preferring cpus not in avoid, e.g. the
+// ones heavily used by noisy pools, and it falls back to cpus in avoid only if the others are not enough.
+// cpus are taken by whole physical cores in both phases, and the number of overlapping cpus used can be
+// got by the size of intersection between the result and avoid.
+func AllocateAvoiding(available CPUSet, num int, avoid CPUSet, topology *CPUTopology) (CPUSet, error) {
+	if num < 0 || num > available.Size() {
+		return NewCPUSet(), fmt.Errorf("invalid num: %d with available cpus: %s", num, available.String())
+	}
+
+	clean := available.Difference(avoid)
+	if num <= clean.Size() {
+		return AllocateCPUs(clean, num, topology, true)
+	}
+
+	overlap, err := AllocateCPUs(available.Intersection(avoid), num-clean.Size(), topology, true)
+	if err != nil {
+		return NewCPUSet(), err
+	}
+	return clean.Union(overlap), nil
+}
+
+// DistributeCPUsAcrossNUMA allocates num cpus from the available cpus of each NUMA node as evenly as possible,
+// i.e. the numbers of cpus allocated from NUMA nodes differ by at most one unless some of them don't have enough
+// cpus available, and the remainder goes to NUMA nodes of smaller ids. whole cores are preferred within each NUMA
+// node, and NUMA nodes without cpus allocated are absent in the result.
+func DistributeCPUsAcrossNUMA(num int, topology *CPUTopology, available map[int]CPUSet) (map[int]CPUSet, error) {
+	if topology == nil {
+		return nil, fmt.Errorf("DistributeCPUsAcrossNUMA got nil topology")
+	} else if num < 0 || num > CountCPUAssignmentCPUs(available) {
+		return nil, fmt.Errorf("invalid num: %d with available cpus: %d", num, CountCPUAssignmentCPUs(available))
+	}
+
+	numaNodes := make([]int, 0, len(available))
+	for numaNode := range available {
+		numaNodes = append(numaNodes, numaNode)
+	}
+	sort.Ints(numaNodes)
+
+	// take one cpu from each NUMA node with cpus left in turn
+	quota := make(map[int]int, len(numaNodes))
+	for remaining := num; remaining > 0; {
+		for _, numaNode := range numaNodes {
+			if remaining == 0 {
+				break
+			} else if quota[numaNode] < available[numaNode].Size() {
+				quota[numaNode]++
+				remaining--
+			}
+		}
+	}
+
+	result := make(map[int]CPUSet, len(quota))
+	for numaNode, n := range quota {
+		cpus, err := AllocateCPUs(available[numaNode], n, topology, true)
+		if err != nil {
+			return nil, fmt.Errorf("allocate %d cpus from NUMA %d failed: %v", n, numaNode, err)
+		}
+		result[numaNode] = cpus
+	}
+	return result, nil
+}
+
+// SelectCPUsByNUMADistance selects num cpus from the available numa-aware cpus, it fills
+// from the preferred numa node first, and then spills to other numa nodes in ascending order
+// of their distances to the preferred one, where distances[i][j] is the distance from i to j.
+func SelectCPUsByNUMADistance(available map[int]CPUSet, preferredNUMA int, num int, distances [][]int) (CPUSet, error) {
+	if preferredNUMA < 0 || preferredNUMA >= len(distances) {
+		return NewCPUSet(), fmt.Errorf("preferred numa %d not found in distances", preferredNUMA)
+	} else if num < 0 || num > CountCPUAssignmentCPUs(available) {
+		return NewCPUSet(), fmt.Errorf("invalid num: %d with available cpus: %d", num, CountCPUAssignmentCPUs(available))
+	}
+
+	preferredDistances := distances[preferredNUMA]
+	numaNodes := make([]int, 0, len(available))
+	for numaNode := range available {
+		if numaNode < 0 || numaNode >= len(preferredDistances) {
+			return NewCPUSet(), fmt.Errorf("distance from numa %d to %d not found", preferredNUMA, numaNode)
+		}
+		numaNodes = append(numaNodes, numaNode)
+	}
+
+	sort.Slice(numaNodes, func(i, j int) bool {
+		// the preferred numa node always comes first, even if its distance to itself is not the smallest
+		if numaNodes[i] == preferredNUMA || numaNodes[j] == preferredNUMA {
+			return numaNodes[i] == preferredNUMA
+		}
+		if preferredDistances[numaNodes[i]] != preferredDistances[numaNodes[j]] {
+			return preferredDistances[numaNodes[i]] < preferredDistances[numaNodes[j]]
+		}
+		return numaNodes[i] < numaNodes[j]
+	})
+
+	result := NewCPUSet()
+	for _, numaNode := range numaNodes {
+		for _, cpu := range available[numaNode].ToSliceInt() {
+			if result.Size() == num {
+				return result, nil
+			}
+			result.Add(cpu)
+		}
+	}
+
+	return result, nil
+}
@@ -20,7 +20,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager"
 	"k8s.io/kubernetes/pkg/kubelet/cm/topologymanager/bitmask"
 )
 
@@ -35,6 +38,23 @@ func TestParseCPUAssignmentFormat(t *testing.T) {
 	}, ParseCPUAssignmentFormat(assignment))
 }
 
+func TestGetMilliQuantityMap(t *testing.T) {
+	quantityMap := GetMilliQuantityMap(map[string]CPUSet{
+		"share":   NewCPUSet(1, 2),
+		"reclaim": NewCPUSet(),
+	})
+	assert.Len(t, quantityMap, 2)
+
+	share := quantityMap["share"]
+	assert.Equal(t, 0, share.Cmp(resource.MustParse("2000")))
+	assert.Equal(t, int64(2000), share.Value())
+
+	// empty cpuset is kept compatible with the quantity parsed from "0"
+	reclaim := quantityMap["reclaim"]
+	assert.Equal(t, 0, reclaim.Cmp(resource.MustParse("0")))
+	assert.Equal(t, "0", reclaim.String())
+}
+
 func TestDeepcopyCPUAssignment(t *testing.T) {
 	assignment := map[int]CPUSet{
 		0: NewCPUSet(1, 2),
@@ -43,8 +63,471 @@ func TestDeepcopyCPUAssignment(t *testing.T) {
 	assert.Equal(t, assignment, DeepcopyCPUAssignment(assignment))
 }
 
+func TestCPUAssignmentSetAlgebra(t *testing.T) {
+	a := map[int]CPUSet{
+		0: NewCPUSet(0, 1, 2),
+		1: NewCPUSet(4, 5),
+	}
+	b := map[int]CPUSet{
+		0: NewCPUSet(2, 3),
+		2: NewCPUSet(8, 9),
+	}
+	empty := map[int]CPUSet{}
+
+	tests := []struct {
+		name      string
+		a, b      map[int]CPUSet
+		union     map[int]CPUSet
+		intersect map[int]CPUSet
+		subtract  map[int]CPUSet
+	}{
+		{
+			name: "partially overlapped numa nodes",
+			a:    a,
+			b:    b,
+			union: map[int]CPUSet{
+				0: NewCPUSet(0, 1, 2, 3),
+				1: NewCPUSet(4, 5),
+				2: NewCPUSet(8, 9),
+			},
+			intersect: map[int]CPUSet{
+				0: NewCPUSet(2),
+			},
+			subtract: map[int]CPUSet{
+				0: NewCPUSet(0, 1),
+				1: NewCPUSet(4, 5),
+			},
+		},
+		{
+			name: "disjoint numa nodes",
+			a: map[int]CPUSet{
+				0: NewCPUSet(0, 1),
+			},
+			b: map[int]CPUSet{
+				1: NewCPUSet(0, 1),
+			},
+			union: map[int]CPUSet{
+				0: NewCPUSet(0, 1),
+				1: NewCPUSet(0, 1),
+			},
+			intersect: map[int]CPUSet{},
+			subtract: map[int]CPUSet{
+				0: NewCPUSet(0, 1),
+			},
+		},
+		{
+			name:      "empty assignment",
+			a:         a,
+			b:         empty,
+			union:     a,
+			intersect: map[int]CPUSet{},
+			subtract:  a,
+		},
+		{
+			name:      "nil assignment",
+			a:         nil,
+			b:         a,
+			union:     a,
+			intersect: map[int]CPUSet{},
+			subtract:  map[int]CPUSet{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aCopied, bCopied := DeepcopyCPUAssignment(tt.a), DeepcopyCPUAssignment(tt.b)
+
+			assert.Equal(t, tt.union, UnionCPUAssignment(tt.a, tt.b))
+			assert.Equal(t, tt.intersect, IntersectCPUAssignment(tt.a, tt.b))
+			assert.Equal(t, tt.subtract, SubtractCPUAssignment(tt.a, tt.b))
+
+			// inputs are left untouched
+			assert.Equal(t, aCopied, tt.a)
+			assert.Equal(t, bCopied, tt.b)
+		})
+	}
+}
+
+func TestPruneEmptyAssignment(t *testing.T) {
+	assignment := map[int]CPUSet{
+		0: MustParse("0-3"),
+		1: MustParse("24-27"),
+		2: NewCPUSet(),
+	}
+	// numa node 1 becomes empty after its cpus are removed
+	assignment[1] = assignment[1].Difference(MustParse("24-27"))
+
+	pruned := PruneEmptyAssignment(assignment)
+	assert.Equal(t, map[int]CPUSet{0: MustParse("0-3")}, pruned)
+	assert.Len(t, assignment, 3)
+
+	// the pruned assignment is a copy
+	pruned[0].Add(4)
+	assert.Equal(t, MustParse("0-3"), assignment[0])
+
+	assert.Equal(t, map[int]CPUSet{}, PruneEmptyAssignment(nil))
+}
+
 func TestMaskToUInt64Array(t *testing.T) {
 	mask, err := bitmask.NewBitMask(0, 1, 2, 3)
 	assert.NoError(t, err)
 	assert.Equal(t, []uint64{0, 1, 2, 3}, MaskToUInt64Array(mask))
 }
+
+func TestUInt64ArrayToMask(t *testing.T) {
+	mask, err := UInt64ArrayToMask([]uint64{0, 1, 2, 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3}, MaskToIntArray(mask))
+	assert.Equal(t, []uint64{0, 1, 2, 3}, MaskToUInt64Array(mask))
+
+	// round trip from bit mask
+	origin, err := bitmask.NewBitMask(1, 5, 63)
+	assert.NoError(t, err)
+	mask, err = UInt64ArrayToMask(MaskToUInt64Array(origin))
+	assert.NoError(t, err)
+	assert.True(t, origin.IsEqual(mask))
+
+	mask, err = UInt64ArrayToMask(nil)
+	assert.NoError(t, err)
+	assert.True(t, mask.IsEmpty())
+	assert.Empty(t, MaskToIntArray(mask))
+
+	_, err = UInt64ArrayToMask([]uint64{0, 64})
+	assert.Error(t, err)
+}
+
+func TestGenerateNUMAHints(t *testing.T) {
+	topology, err := GenerateDummyCPUTopology(16, 2, 4)
+	require.NoError(t, err)
+
+	hintsToBits := func(hints []topologymanager.TopologyHint) ([][]int, []bool) {
+		var bits [][]int
+		var preferred []bool
+		for _, hint := range hints {
+			bits = append(bits, hint.NUMANodeAffinity.GetBits())
+			preferred = append(preferred, hint.Preferred)
+		}
+		return bits, preferred
+	}
+
+	// cpuset within a single NUMA node
+	cset := topology.CPUDetails.CPUsInNUMANodes(1)
+	bits, preferred := hintsToBits(GenerateNUMAHints(cset, topology))
+	assert.Equal(t, [][]int{
+		{1},
+		{0, 1}, {1, 2}, {1, 3},
+		{0, 1, 2}, {0, 1, 3}, {1, 2, 3},
+		{0, 1, 2, 3},
+	}, bits)
+	assert.Equal(t, []bool{true, false, false, false, false, false, false, false}, preferred)
+
+	// cpuset across NUMA nodes, and only part of cpus in each NUMA node is used
+	cset = NewCPUSet(topology.CPUDetails.CPUsInNUMANodes(0).ToSliceInt()[0],
+		topology.CPUDetails.CPUsInNUMANodes(2).ToSliceInt()[0])
+	bits, preferred = hintsToBits(GenerateNUMAHints(cset, topology))
+	assert.Equal(t, [][]int{
+		{0, 2},
+		{0, 1, 2}, {0, 2, 3},
+		{0, 1, 2, 3},
+	}, bits)
+	assert.Equal(t, []bool{true, false, false, false}, preferred)
+
+	// cpuset spanning all NUMA nodes
+	bits, preferred = hintsToBits(GenerateNUMAHints(topology.CPUDetails.CPUs(), topology))
+	assert.Equal(t, [][]int{{0, 1, 2, 3}}, bits)
+	assert.Equal(t, []bool{true}, preferred)
+
+	assert.Nil(t, GenerateNUMAHints(NewCPUSet(), topology))
+	assert.Nil(t, GenerateNUMAHints(NewCPUSet(100), topology))
+	assert.Nil(t, GenerateNUMAHints(cset, nil))
+}
+
+func TestAllocateCPUs(t *testing.T) {
+	topology, err := GenerateDummyCPUTopology(96, 2, 2)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		available      CPUSet
+		num            int
+		preferFullCore bool
+		want           CPUSet
+		wantErr        bool
+	}{
+		{
+			name:           "even num with full cores",
+			available:      topology.CPUDetails.CPUs(),
+			num:            4,
+			preferFullCore: true,
+			want:           NewCPUSet(0, 1, 48, 49),
+		},
+		{
+			name:           "odd num with full cores",
+			available:      topology.CPUDetails.CPUs(),
+			num:            3,
+			preferFullCore: true,
+			want:           NewCPUSet(0, 1, 48),
+		},
+		{
+			name:           "odd num splits partial core first",
+			available:      topology.CPUDetails.CPUs().Difference(NewCPUSet(48)),
+			num:            3,
+			preferFullCore: true,
+			want:           NewCPUSet(0, 1, 49),
+		},
+		{
+			name:           "even num skips partial cores",
+			available:      topology.CPUDetails.CPUs().Difference(NewCPUSet(0, 49)),
+			num:            2,
+			preferFullCore: true,
+			want:           NewCPUSet(2, 50),
+		},
+		{
+			name:      "without preferring full core",
+			available: topology.CPUDetails.CPUs(),
+			num:       3,
+			want:      NewCPUSet(0, 1, 2),
+		},
+		{
+			name:      "zero num",
+			available: topology.CPUDetails.CPUs(),
+			num:       0,
+			want:      NewCPUSet(),
+		},
+		{
+			name:           "num exceeds available",
+			available:      NewCPUSet(0, 48),
+			num:            3,
+			preferFullCore: true,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AllocateCPUs(tt.available, tt.num, topology, tt.preferFullCore)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equals(got), "want %s, got %s", tt.want.String(), got.String())
+		})
+	}
+}
+
+func TestAllocateAvoiding(t *testing.T) {
+	topology, err := GenerateDummyCPUTopology(96, 2, 2)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		available   CPUSet
+		num         int
+		avoid       CPUSet
+		want        CPUSet
+		wantOverlap int
+		wantErr     bool
+	}{
+		{
+			name:      "clean cpus are enough",
+			available: MustParse("0-3,48-51"),
+			num:       4,
+			avoid:     MustParse("0-1,48-49"),
+			want:      MustParse("2-3,50-51"),
+		},
+		{
+			name:        "forced to overlap",
+			available:   MustParse("0-3,48-51"),
+			num:         6,
+			avoid:       MustParse("0-1,48-49"),
+			want:        MustParse("0,2-3,48,50-51"),
+			wantOverlap: 2,
+		},
+		{
+			name:      "avoid out of available",
+			available: MustParse("0-1,48-49"),
+			num:       2,
+			avoid:     MustParse("2-3"),
+			want:      MustParse("0,48"),
+		},
+		{
+			name:      "num exceeds available",
+			available: MustParse("0-1"),
+			num:       3,
+			avoid:     MustParse("0"),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AllocateAvoiding(tt.available, tt.num, tt.avoid, topology)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equals(got), "want %s, got %s", tt.want.String(), got.String())
+			assert.Equal(t, tt.wantOverlap, got.Intersection(tt.avoid).Size())
+		})
+	}
+}
+
+func TestDistributeCPUsAcrossNUMA(t *testing.T) {
+	// numa node0 cpu(s): 0-3,8-11
+	// numa node1 cpu(s): 4-7,12-15
+	topology, err := GenerateDummyCPUTopology(16, 1, 2)
+	require.NoError(t, err)
+
+	allAvailable := map[int]CPUSet{
+		0: topology.CPUDetails.CPUsInNUMANodes(0),
+		1: topology.CPUDetails.CPUsInNUMANodes(1),
+	}
+
+	tests := []struct {
+		name      string
+		num       int
+		available map[int]CPUSet
+		want      map[int]CPUSet
+		wantErr   bool
+	}{
+		{
+			name:      "divisible by NUMA count",
+			num:       8,
+			available: allAvailable,
+			want: map[int]CPUSet{
+				0: NewCPUSet(0, 1, 8, 9),
+				1: NewCPUSet(4, 5, 12, 13),
+			},
+		},
+		{
+			name:      "not divisible by NUMA count",
+			num:       5,
+			available: allAvailable,
+			want: map[int]CPUSet{
+				0: NewCPUSet(0, 1, 8),
+				1: NewCPUSet(4, 12),
+			},
+		},
+		{
+			name:      "fewer cpus than NUMA count",
+			num:       1,
+			available: allAvailable,
+			want: map[int]CPUSet{
+				0: NewCPUSet(0),
+			},
+		},
+		{
+			name: "NUMA node without enough cpus",
+			num:  6,
+			available: map[int]CPUSet{
+				0: NewCPUSet(0, 8),
+				1: topology.CPUDetails.CPUsInNUMANodes(1),
+			},
+			want: map[int]CPUSet{
+				0: NewCPUSet(0, 8),
+				1: NewCPUSet(4, 5, 12, 13),
+			},
+		},
+		{
+			name:      "infeasible",
+			num:       17,
+			available: allAvailable,
+			wantErr:   true,
+		},
+		{
+			name:      "negative num",
+			num:       -1,
+			available: allAvailable,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DistributeCPUsAcrossNUMA(tt.num, topology, tt.available)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.want), len(got))
+			for numaNode, cpus := range tt.want {
+				assert.True(t, cpus.Equals(got[numaNode]), "numa %d: want %s, got %s", numaNode, cpus, got[numaNode])
+			}
+			assert.Equal(t, tt.num, CountCPUAssignmentCPUs(got))
+		})
+	}
+
+	_, err = DistributeCPUsAcrossNUMA(1, nil, allAvailable)
+	assert.Error(t, err)
+}
+
+func TestSelectCPUsByNUMADistance(t *testing.T) {
+	available := map[int]CPUSet{
+		0: NewCPUSet(0, 1),
+		1: NewCPUSet(2, 3),
+		2: NewCPUSet(4, 5),
+		3: NewCPUSet(6, 7),
+	}
+	// asymmetric distances, e.g. numa 1 is nearer to numa 0 than numa 0 to numa 1
+	distances := [][]int{
+		{10, 32, 21, 32},
+		{12, 10, 32, 21},
+		{21, 32, 10, 32},
+		{32, 21, 32, 10},
+	}
+
+	tests := []struct {
+		name          string
+		preferredNUMA int
+		num           int
+		want          CPUSet
+		wantErr       bool
+	}{
+		{
+			name:          "fit in preferred numa",
+			preferredNUMA: 0,
+			num:           2,
+			want:          NewCPUSet(0, 1),
+		},
+		{
+			name:          "spill to nearest numa",
+			preferredNUMA: 0,
+			num:           3,
+			want:          NewCPUSet(0, 1, 4),
+		},
+		{
+			name:          "spill by asymmetric distance",
+			preferredNUMA: 1,
+			num:           5,
+			want:          NewCPUSet(0, 1, 2, 3, 6),
+		},
+		{
+			name:          "spill to all numa nodes",
+			preferredNUMA: 3,
+			num:           8,
+			want:          NewCPUSet(0, 1, 2, 3, 4, 5, 6, 7),
+		},
+		{
+			name:          "num exceeds available",
+			preferredNUMA: 0,
+			num:           9,
+			wantErr:       true,
+		},
+		{
+			name:          "preferred numa out of distances",
+			preferredNUMA: 4,
+			num:           1,
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectCPUsByNUMADistance(available, tt.preferredNUMA, tt.num, distances)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equals(got), "want %s, got %s", tt.want.String(), got.String())
+		})
+	}
+}
@@ -19,6 +19,8 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	core "k8s.io/api/core/v1"
@@ -290,6 +292,27 @@ func GetSPDHash(spd *apiworkload.ServiceProfileDescriptor) string {
 	return spd.Annotations[consts.ServiceProfileDescriptorAnnotationKeyConfigHash]
 }
 
+// GetSPDCacheTTL gets the cache ttl override from spd annotation, and it returns
+// zero if the annotation is absent
+func GetSPDCacheTTL(spd *apiworkload.ServiceProfileDescriptor) (time.Duration, error) {
+	if spd == nil || spd.Annotations == nil {
+		return 0, nil
+	}
+
+	value, ok := spd.Annotations[consts.ServiceProfileDescriptorAnnotationKeyCacheTTL]
+	if !ok {
+		return 0, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("parse spd cache ttl %q failed: %v", value, err)
+	} else if ttl <= 0 {
+		return 0, fmt.Errorf("spd cache ttl %q is not positive", value)
+	}
+	return ttl, nil
+}
+
 // SetSPDHash set spd hash to spd annotation
 func SetSPDHash(spd *apiworkload.ServiceProfileDescriptor, hash string) {
 	if spd == nil {
@@ -333,3 +356,55 @@ func GetPodSPDName(pod *core.Pod) (string, error) {
 
 	return spdName, nil
 }
+
+// GetPodSPDNames gets all spd names applied to the pod in priority order, the one from
+// spd name annotation comes first, and extra ones are followed in annotation order
+func GetPodSPDNames(pod *core.Pod) ([]string, error) {
+	spdName, err := GetPodSPDName(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	spdNames := []string{spdName}
+	seen := map[string]bool{spdName: true}
+	for _, name := range strings.Split(pod.GetAnnotations()[consts.PodAnnotationSPDExtraNamesKey], ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		spdNames = append(spdNames, name)
+	}
+
+	return spdNames, nil
+}
+
+// GetSPDIndicatorValue extracts the value of the named indicator from spd, for business
+// indicators it's the current observed value in status, and for system indicators it's
+// the value of upper bound level in spec, which is the target that the indicator should
+// be kept below. ok is false if the indicator is absent in spd.
+func GetSPDIndicatorValue(spd *apiworkload.ServiceProfileDescriptor, indicatorName string) (float64, bool) {
+	if spd == nil {
+		return 0, false
+	}
+
+	for _, status := range spd.Status.BusinessStatus {
+		if string(status.Name) == indicatorName && status.Current != nil {
+			return float64(*status.Current), true
+		}
+	}
+
+	for _, indicator := range spd.Spec.SystemIndicator {
+		if string(indicator.Name) != indicatorName {
+			continue
+		}
+
+		for _, level := range indicator.Indicators {
+			if level.IndicatorLevel == apiworkload.IndicatorLevelUpperBound {
+				return float64(level.Value), true
+			}
+		}
+	}
+
+	return 0, false
+}